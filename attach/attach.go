@@ -0,0 +1,251 @@
+// Package attach 读取本地文件并整理成可以附加到对话中的文本。
+//
+// 目录和通配符（支持 **）会递归展开，展开时遵循 .gitignore；
+// 二进制文件和超过大小限制的文件会被跳过。
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxFileSize 单个文件的大小上限
+	MaxFileSize = 256 * 1024
+	// MaxFiles 一次展开最多包含的文件数
+	MaxFiles = 100
+)
+
+var (
+	// ErrBinary 表示文件不是文本文件
+	ErrBinary = errors.New("二进制文件")
+	// ErrTooLarge 表示文件超过 MaxFileSize
+	ErrTooLarge = fmt.Errorf("超过 %d KB 的大小限制", MaxFileSize/1024)
+	// ErrNoMatch 表示路径不存在或通配符没有匹配到文件
+	ErrNoMatch = errors.New("没有匹配的文件")
+)
+
+// File 是读取到的文本文件
+type File struct {
+	Path    string // 用户输入形式的路径，用于显示
+	Content string
+	Lines   int
+}
+
+// Skipped 是展开时被跳过的文件及原因
+type Skipped struct {
+	Path string
+	Err  error
+}
+
+// Result 是展开一组路径的结果
+type Result struct {
+	Files   []File
+	Skipped []Skipped
+}
+
+// Load 展开并读取 patterns 中的文件、目录和通配符。
+// 直接指定的文件总会被读取；目录和通配符展开时跳过 .gitignore 忽略的文件
+func Load(patterns ...string) (Result, error) {
+	var result Result
+	seen := map[string]bool{}
+	ig := newIgnorer()
+	for _, pattern := range patterns {
+		paths, err := expand(pattern, ig)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", pattern, err)
+		}
+		for _, p := range paths {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			if len(result.Files) >= MaxFiles {
+				result.Skipped = append(result.Skipped, Skipped{Path: p, Err: fmt.Errorf("超过 %d 个文件的数量限制", MaxFiles)})
+				continue
+			}
+			file, err := Read(p)
+			if err != nil {
+				result.Skipped = append(result.Skipped, Skipped{Path: p, Err: err})
+				continue
+			}
+			result.Files = append(result.Files, file)
+		}
+	}
+	return result, nil
+}
+
+// Exists 判断 pattern 是否指向已存在的文件或目录，或者是能匹配到文件的通配符
+func Exists(pattern string) bool {
+	paths, err := expand(pattern, newIgnorer())
+	return err == nil && len(paths) > 0
+}
+
+// expand 将路径展开为文件列表
+func expand(pattern string, ig *ignorer) ([]string, error) {
+	pattern = expandHome(pattern)
+	if !hasMeta(pattern) {
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, ErrNoMatch
+		}
+		if !info.IsDir() {
+			return []string{pattern}, nil
+		}
+		return walk(pattern, "", ig)
+	}
+
+	// 从第一个通配符之前的目录开始遍历，逐个匹配
+	slashed := filepath.ToSlash(pattern)
+	base := "."
+	if i := strings.IndexAny(slashed, "*?["); i >= 0 {
+		if j := strings.LastIndex(slashed[:i], "/"); j >= 0 {
+			base = slashed[:j]
+			if base == "" {
+				base = "/"
+			}
+		}
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(slashed, base), "/")
+	paths, err := walk(filepath.FromSlash(base), rel, ig)
+	if err == nil && len(paths) == 0 {
+		err = ErrNoMatch
+	}
+	return paths, err
+}
+
+// walk 遍历目录中未被忽略的文件，match 非空时只返回相对路径匹配的文件
+func walk(dir, match string, ig *ignorer) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != dir {
+			abs, err := filepath.Abs(p)
+			if err == nil && ig.ignored(abs, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if match != "" {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil || !matchPath(match, filepath.ToSlash(rel)) {
+				return nil
+			}
+		}
+		if len(paths) >= MaxFiles*10 {
+			return filepath.SkipAll
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	return paths, err
+}
+
+// Read 读取单个文本文件
+func Read(p string) (File, error) {
+	p = expandHome(p)
+	info, err := os.Stat(p)
+	if err != nil {
+		return File{}, err
+	}
+	if info.IsDir() {
+		return File{}, fmt.Errorf("%s 是目录", p)
+	}
+	if info.Size() > MaxFileSize {
+		return File{}, ErrTooLarge
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return File{}, err
+	}
+	if isBinary(data) {
+		return File{}, ErrBinary
+	}
+	content := strings.TrimRight(string(data), "\n")
+	return File{
+		Path:    filepath.ToSlash(p),
+		Content: content,
+		Lines:   strings.Count(content, "\n") + 1,
+	}, nil
+}
+
+// isBinary 根据前 8000 字节判断文件是否为二进制：包含 NUL 字节或不是有效的 UTF-8
+func isBinary(data []byte) bool {
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+		// 截断处可能切断了一个多字节字符
+		for i := 0; i < utf8.UTFMax && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	return bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(head)
+}
+
+// Format 将文件整理为带路径和行号的代码块
+func Format(f File) string {
+	var b strings.Builder
+	fence := "```"
+	for strings.Contains(f.Content, fence) {
+		fence += "`"
+	}
+	width := len(fmt.Sprint(f.Lines))
+	fmt.Fprintf(&b, "文件 %s:\n%s%s\n", f.Path, fence, language(f.Path))
+	for i, line := range strings.Split(f.Content, "\n") {
+		fmt.Fprintf(&b, "%s\n", strings.TrimRight(fmt.Sprintf("%*d | %s", width, i+1, line), " "))
+	}
+	b.WriteString(fence)
+	return b.String()
+}
+
+// language 根据扩展名返回代码块的语言标记
+func language(p string) string {
+	switch ext := strings.TrimPrefix(filepath.Ext(p), "."); ext {
+	case "go", "rs", "c", "h", "cpp", "java", "kt", "swift", "rb", "php", "lua", "sql", "toml", "xml", "html", "css", "vue":
+		return ext
+	case "py":
+		return "python"
+	case "js", "mjs", "cjs":
+		return "javascript"
+	case "ts", "tsx":
+		return "typescript"
+	case "sh", "bash", "zsh":
+		return "bash"
+	case "yml", "yaml":
+		return "yaml"
+	case "md":
+		return "markdown"
+	case "json", "jsonl":
+		return "json"
+	}
+	if filepath.Base(p) == "Dockerfile" {
+		return "dockerfile"
+	}
+	return ""
+}
+
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
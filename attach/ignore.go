@@ -0,0 +1,165 @@
+package attach
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule 是 .gitignore 中的一条规则
+type ignoreRule struct {
+	pattern  string
+	negate   bool // 以 ! 开头，重新包含之前被忽略的文件
+	dirOnly  bool // 以 / 结尾，只匹配目录
+	anchored bool // 包含 /，相对于 .gitignore 所在目录匹配
+}
+
+// ignorer 按 git 的规则判断文件是否被忽略：从仓库根目录到文件所在目录，
+// 逐级应用每个目录下的 .gitignore，后出现的规则优先
+type ignorer struct {
+	roots map[string]string       // 目录 -> 所在仓库的根目录，不在仓库中时为空
+	rules map[string][]ignoreRule // 目录 -> 该目录 .gitignore 中的规则
+}
+
+func newIgnorer() *ignorer {
+	return &ignorer{
+		roots: map[string]string{},
+		rules: map[string][]ignoreRule{},
+	}
+}
+
+// ignored 判断 path 是否被忽略，path 必须是绝对路径
+func (ig *ignorer) ignored(file string, isDir bool) bool {
+	if filepath.Base(file) == ".git" {
+		return true
+	}
+	root := ig.root(filepath.Dir(file))
+	if root == "" {
+		return false
+	}
+
+	// 收集从根目录到文件所在目录的各级目录
+	var dirs []string
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range ig.load(dirs[i]) {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.matches(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(rel string) bool {
+	if r.anchored {
+		return matchPath(r.pattern, rel)
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
+
+// root 返回 dir 所在 git 仓库的根目录
+func (ig *ignorer) root(dir string) string {
+	if root, ok := ig.roots[dir]; ok {
+		return root
+	}
+	root := ""
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = ig.root(parent)
+	}
+	ig.roots[dir] = root
+	return root
+}
+
+// load 读取目录下的 .gitignore
+func (ig *ignorer) load(dir string) []ignoreRule {
+	if rules, ok := ig.rules[dir]; ok {
+		return rules
+	}
+	var rules []ignoreRule
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+				rules = append(rules, rule)
+			}
+		}
+		f.Close()
+	}
+	ig.rules[dir] = rules
+	return rules
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	rule.pattern = line
+	return rule, line != ""
+}
+
+// matchPath 用 pattern 匹配以 / 分隔的相对路径，** 匹配任意层目录。
+// 与 git 一致，结尾的 /** 只匹配目录中的内容，不匹配目录本身
+func matchPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
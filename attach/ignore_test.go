@@ -0,0 +1,187 @@
+package attach
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"a.txt", "a.txt", true},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**/*.go", "lib/main.go", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"logs/**", "logs/a.log", true},
+		{"logs/**", "logs/2025/a.log", true},
+		{"logs/**", "logs", false},
+		{"**", "anything/at/all", true},
+		{"doc/?.md", "doc/a.md", true},
+		{"doc/?.md", "doc/ab.md", false},
+		{"[ab].txt", "b.txt", true},
+		{"[ab].txt", "c.txt", false},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want ignoreRule
+		ok   bool
+	}{
+		{"", ignoreRule{}, false},
+		{"# comment", ignoreRule{}, false},
+		{"   ", ignoreRule{}, false},
+		{"*.log", ignoreRule{pattern: "*.log"}, true},
+		{"*.log  \r", ignoreRule{pattern: "*.log"}, true},
+		{"build/", ignoreRule{pattern: "build", dirOnly: true}, true},
+		{"/build", ignoreRule{pattern: "build", anchored: true}, true},
+		{"doc/*.txt", ignoreRule{pattern: "doc/*.txt", anchored: true}, true},
+		{"**/temp", ignoreRule{pattern: "**/temp", anchored: true}, true},
+		{"!keep.log", ignoreRule{pattern: "keep.log", negate: true}, true},
+		{"!/dist/", ignoreRule{pattern: "dist", negate: true, dirOnly: true, anchored: true}, true},
+		{`\#notcomment`, ignoreRule{pattern: "#notcomment"}, true},
+		{`\!important`, ignoreRule{pattern: "!important"}, true},
+		{"/", ignoreRule{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseIgnoreRule(tt.line)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseIgnoreRule(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// writeTree 在 dir 中创建文件，内容为空的路径以 / 结尾时创建目录
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	repo := t.TempDir()
+	writeTree(t, repo, map[string]string{
+		".git/":          "",
+		".gitignore":     "*.log\n!keep.log\nbuild/\n/secret.txt\ndocs/*.tmp\nlogs/**\n!logs/important/\n**/cache\n",
+		"sub/.gitignore": "local.txt\n!*.log\n/only-here\n",
+	})
+
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"main.go", false, false},
+		{".git", true, true},
+		{"debug.log", false, true},
+		{"keep.log", false, false},
+		{"nested/deep/debug.log", false, true},
+		{"build", true, true},
+		{"build", false, false}, // build/ 只匹配目录
+		{"src/build", true, true},
+		{"secret.txt", false, true},
+		{"sub/secret.txt", false, false}, // /secret.txt 只匹配根目录
+		{"docs/a.tmp", false, true},
+		{"docs/x/a.tmp", false, false},
+		{"other/docs/a.tmp", false, false},
+		{"logs", true, false}, // logs/** 不忽略目录本身，以便重新包含其中的文件
+		{"logs/a.txt", false, true},
+		{"logs/important", true, false},
+		{"cache", true, true},
+		{"a/b/cache", true, true},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"sub/debug.log", false, false}, // 子目录的 !*.log 覆盖根目录的 *.log
+		{"sub/x/debug.log", false, false},
+		{"sub/only-here", false, true},
+		{"sub/x/only-here", false, false},
+	}
+	ig := newIgnorer()
+	for _, tt := range tests {
+		file := filepath.Join(repo, filepath.FromSlash(tt.name))
+		if got := ig.ignored(file, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+
+	// 不在 git 仓库中的目录不应用任何规则
+	outside := t.TempDir()
+	writeTree(t, outside, map[string]string{".gitignore": "*.log\n"})
+	if newIgnorer().ignored(filepath.Join(outside, "a.log"), false) {
+		t.Error("a.log outside a repository was ignored")
+	}
+}
+
+func TestExpandSkipsIgnored(t *testing.T) {
+	repo := t.TempDir()
+	writeTree(t, repo, map[string]string{
+		".git/":                   "",
+		".gitignore":              "node_modules/\n*.log\nlogs/**\n!logs/keep.txt\n",
+		"main.go":                 "package main",
+		"debug.log":               "x",
+		"node_modules/a/index.js": "x",
+		"src/util.go":             "package src",
+		"src/util_test.go":        "package src",
+		"logs/keep.txt":           "x",
+		"logs/drop.txt":           "x",
+	})
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{repo, []string{".gitignore", "logs/keep.txt", "main.go", "src/util.go", "src/util_test.go"}},
+		{filepath.Join(repo, "**/*.go"), []string{"main.go", "src/util.go", "src/util_test.go"}},
+		{filepath.Join(repo, "src/*_test.go"), []string{"src/util_test.go"}},
+		{filepath.Join(repo, "*.js"), nil},
+	}
+	for _, tt := range tests {
+		paths, _ := expand(tt.pattern, newIgnorer())
+		var got []string
+		for _, p := range paths {
+			rel, _ := filepath.Rel(repo, p)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("expand(%q) = %q, want %q", tt.pattern, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("expand(%q) = %q, want %q", tt.pattern, got, tt.want)
+				break
+			}
+		}
+	}
+}
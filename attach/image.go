@@ -0,0 +1,52 @@
+package attach
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// MaxImageSize 单张图片的大小上限
+const MaxImageSize = 10 * 1024 * 1024
+
+// ErrNotImage 表示文件不是支持的图片格式
+var ErrNotImage = errors.New("不是支持的图片格式（PNG、JPEG、GIF、WebP、BMP）")
+
+// imageTypes 是模型支持的图片 MIME 类型
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+}
+
+// Image 读取图片并返回 base64 data URI，http(s) 地址原样返回
+func Image(p string) (string, error) {
+	if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+		return p, nil
+	}
+	p = expandHome(p)
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s 是目录", p)
+	}
+	if info.Size() > MaxImageSize {
+		return "", fmt.Errorf("超过 %d MB 的大小限制", MaxImageSize/1024/1024)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	mime := http.DetectContentType(data)
+	if !imageTypes[mime] {
+		return "", ErrNotImage
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -1,55 +1,372 @@
 package client
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+
+	"Qwen-cli/sse"
 )
 
-func Client(apiURL, apiKey string, params []byte, callBack func(data []byte)) error {
-	reader := bytes.NewReader(params)
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
 
-	req, err := http.NewRequest("POST", apiURL, reader)
-	if err != nil {
-		return fmt.Errorf("error creating request: %s", err.Error())
+// Message 表示一条对话消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images 是随消息发送的图片地址，通常为 base64 data URI。
+	// 非空时 content 按 OpenAI 的多段格式编码为文本段和 image_url 段
+	Images []string `json:"-"`
+	// Reasoning 是思考模型（如 Qwen3、QwQ）返回的思考过程，只从响应中解析，
+	// 不随请求发送，避免重复占用上下文
+	Reasoning string `json:"-"`
+}
+
+// ContentPart 是多段格式消息中的一段
+type ContentPart struct {
+	Type     string    `json:"type"` // text 或 image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL 是 image_url 段中的图片地址
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+// Parts 返回消息的多段格式内容
+func (m Message) Parts() []ContentPart {
+	parts := make([]ContentPart, 0, len(m.Images)+1)
+	for _, url := range m.Images {
+		parts = append(parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}})
 	}
+	if m.Content != "" {
+		parts = append(parts, ContentPart{Type: "text", Text: m.Content})
+	}
+	return parts
+}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
+// MarshalJSON 没有图片时 content 为字符串，否则为多段格式
+func (m Message) MarshalJSON() ([]byte, error) {
+	if len(m.Images) == 0 {
+		type plain Message
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}{m.Role, m.Parts()})
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending request: %s", err.Error())
+// UnmarshalJSON 同时接受字符串和多段格式的 content，
+// 思考过程取自 reasoning_content（Ollama 为 thinking）
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role             string          `json:"role"`
+		Content          json.RawMessage `json:"content"`
+		ReasoningContent string          `json:"reasoning_content"`
+		Thinking         string          `json:"thinking"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Message{Role: raw.Role, Reasoning: raw.ReasoningContent + raw.Thinking}
+	if len(raw.Content) == 0 || string(raw.Content) == "null" {
+		return nil
+	}
+	if raw.Content[0] == '"' {
+		return json.Unmarshal(raw.Content, &m.Content)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("error reading error response: %s, status code: %d", err.Error(), resp.StatusCode)
+	var parts []ContentPart
+	if err := json.Unmarshal(raw.Content, &parts); err != nil {
+		return err
+	}
+	var text []string
+	for _, part := range parts {
+		// DashScope 多模态接口的文本段只有 text 字段，没有 type
+		switch {
+		case part.ImageURL != nil:
+			m.Images = append(m.Images, part.ImageURL.URL)
+		case part.Type == "text" || part.Type == "":
+			text = append(text, part.Text)
 		}
-		return fmt.Errorf("API error: %s, status code: %d", string(bodyBytes), resp.StatusCode)
 	}
+	m.Content = strings.Join(text, "\n")
+	return nil
+}
+
+// FinishReason 表示模型停止生成的原因
+type FinishReason string
+
+const (
+	FinishReasonStop          FinishReason = "stop"
+	FinishReasonLength        FinishReason = "length"
+	FinishReasonToolCalls     FinishReason = "tool_calls"
+	FinishReasonContentFilter FinishReason = "content_filter"
+)
+
+// StreamOptions 流式请求的附加选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatRequest 表示一次 chat completions 请求
+type ChatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	EnableSearch  bool           `json:"enable_search,omitempty"`
+
+	// 生成参数，nil 表示使用服务端默认值
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	MaxTokens       int      `json:"max_tokens,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	Stop            []string `json:"stop,omitempty"`
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+
+	// Qwen3 思考模式参数
+	EnableThinking *bool `json:"enable_thinking,omitempty"`
+	ThinkingBudget *int  `json:"thinking_budget,omitempty"`
+}
+
+// Usage 表示一次请求的 token 用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChunkChoice 表示流式响应中的一个候选增量
+type ChunkChoice struct {
+	Index        int          `json:"index"`
+	Delta        Message      `json:"delta"`
+	FinishReason FinishReason `json:"finish_reason"`
+}
+
+// ChatChunk 表示流式响应中的一个数据块
+type ChatChunk struct {
+	ID      string        `json:"id"`
+	Model   string        `json:"model"`
+	Created int64         `json:"created"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// Content 返回第一个候选的增量文本
+func (c ChatChunk) Content() string {
+	if len(c.Choices) == 0 {
+		return ""
+	}
+	return c.Choices[0].Delta.Content
+}
+
+// Reasoning 返回第一个候选的增量思考过程
+func (c ChatChunk) Reasoning() string {
+	if len(c.Choices) == 0 {
+		return ""
+	}
+	return c.Choices[0].Delta.Reasoning
+}
+
+// FinishReason 返回第一个候选的结束原因，未结束时为空
+func (c ChatChunk) FinishReason() FinishReason {
+	if len(c.Choices) == 0 {
+		return ""
+	}
+	return c.Choices[0].FinishReason
+}
+
+// Choice 表示非流式响应中的一个候选回复
+type Choice struct {
+	Index        int          `json:"index"`
+	Message      Message      `json:"message"`
+	FinishReason FinishReason `json:"finish_reason"`
+}
+
+// ChatResponse 表示非流式请求的完整响应
+type ChatResponse struct {
+	ID      string   `json:"id"`
+	Model   string   `json:"model"`
+	Created int64    `json:"created"`
+	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+}
+
+// Content 返回第一个候选的回复文本
+func (r ChatResponse) Content() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message.Content
+}
+
+// Reasoning 返回第一个候选的思考过程
+func (r ChatResponse) Reasoning() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message.Reasoning
+}
+
+// FinishReason 返回第一个候选的结束原因
+func (r ChatResponse) FinishReason() FinishReason {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].FinishReason
+}
+
+// Client 是 OpenAI 兼容 chat completions 接口的客户端，实现 Provider
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+}
+
+// New 创建客户端，baseURL 可以是 API 根地址或完整的 /chat/completions 地址
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
+// Endpoint 返回 chat completions 接口地址
+func (c *Client) Endpoint() string {
+	url := strings.TrimRight(c.BaseURL, "/")
+	if strings.HasSuffix(url, "/chat/completions") {
+		return url
+	}
+	return url + "/chat/completions"
+}
+
+// httpClientOrDefault 在 hc 为空时使用 http.DefaultClient
+func httpClientOrDefault(hc *http.Client) *http.Client {
+	if hc != nil {
+		return hc
+	}
+	return http.DefaultClient
+}
+
+// Stream 发起流式请求，返回的 ChatStream 需要调用方关闭
+func (c *Client) Stream(ctx context.Context, req ChatRequest) (*ChatStream, error) {
+	req.Stream = true
+	if req.StreamOptions == nil {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	resp, err := c.do(ctx, body, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := sse.NewDecoder(resp.Body)
+	return newChatStream(resp.Body, func() (ChatChunk, error) {
+		var chunk ChatChunk
+		for {
+			event, err := decoder.Next()
+			if err == io.EOF {
+				return chunk, io.EOF
+			}
+			if err != nil {
+				return chunk, fmt.Errorf("error reading stream: %w", err)
+			}
 
-	// Handle streaming response
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "data:") {
-			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			data := strings.TrimSpace(event.Data)
 			if data == "[DONE]" {
-				break
+				return chunk, io.EOF
 			}
-			callBack([]byte(data))
+
+			if apiErr := parseStreamError(event.Type, []byte(data)); apiErr != nil {
+				return chunk, apiErr
+			}
+
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return chunk, fmt.Errorf("error parsing chunk: %w", err)
+			}
+			return chunk, nil
 		}
+	}), nil
+}
+
+// Complete 发起非流式请求，返回完整响应
+func (c *Client) Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	req.Stream = false
+	req.StreamOptions = nil
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading stream: %s", err.Error())
+	resp, err := c.do(ctx, body, "application/json")
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	return nil
+	var response ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+	return &response, nil
+}
+
+func (c *Client) do(ctx context.Context, body []byte, accept string) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.APIKey)
+	header.Set("Accept", accept)
+	return postJSON(ctx, httpClientOrDefault(c.HTTPClient), c.Retry, c.Endpoint(), header, body)
+}
+
+// ChatStream 按顺序读取流式响应的数据块
+type ChatStream struct {
+	body io.Closer
+	next func() (ChatChunk, error)
+	done bool
+}
+
+// newChatStream 创建由 next 逐个产生数据块的流，next 在流结束时返回 io.EOF
+func newChatStream(body io.Closer, next func() (ChatChunk, error)) *ChatStream {
+	return &ChatStream{body: body, next: next}
+}
+
+// Recv 返回下一个数据块，流结束时返回 io.EOF。
+// 流中的错误事件以 *APIError 返回
+func (s *ChatStream) Recv() (ChatChunk, error) {
+	if s.done {
+		return ChatChunk{}, io.EOF
+	}
+	chunk, err := s.next()
+	if err != nil {
+		s.done = true
+	}
+	return chunk, err
+}
+
+// Close 关闭底层响应体
+func (s *ChatStream) Close() error {
+	return s.body.Close()
 }
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"Qwen-cli/sse"
+)
+
+// DefaultDashScopeURL 是 DashScope 原生接口的默认地址
+const DefaultDashScopeURL = "https://dashscope.aliyuncs.com"
+
+const (
+	dashScopeGenerationPath = "/api/v1/services/aigc/text-generation/generation"
+	dashScopeMultimodalPath = "/api/v1/services/aigc/multimodal-generation/generation"
+)
+
+// DashScope 是 DashScope 原生接口（/api/v1/services/aigc/...）的客户端，实现 Provider。
+// 流式请求使用 incremental_output 增量输出
+type DashScope struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+}
+
+// NewDashScope 创建 DashScope 原生接口客户端，baseURL 为空时使用 DefaultDashScopeURL
+func NewDashScope(baseURL, apiKey string) *DashScope {
+	if baseURL == "" {
+		baseURL = DefaultDashScopeURL
+	}
+	return &DashScope{
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
+// Endpoint 返回文本生成接口地址，baseURL 已包含服务路径时原样使用
+func (d *DashScope) Endpoint() string {
+	return d.endpoint(false)
+}
+
+// endpoint 返回文本生成或多模态生成接口地址
+func (d *DashScope) endpoint(multimodal bool) string {
+	url := strings.TrimRight(d.BaseURL, "/")
+	if strings.Contains(url, "/api/v1/services/") {
+		return url
+	}
+	if multimodal {
+		return url + dashScopeMultimodalPath
+	}
+	return url + dashScopeGenerationPath
+}
+
+type dashScopeRequest struct {
+	Model string `json:"model"`
+	Input struct {
+		Messages any `json:"messages"`
+	} `json:"input"`
+	Parameters dashScopeParameters `json:"parameters"`
+
+	multimodal bool
+}
+
+// dashScopeMessage 是多模态接口的消息格式，content 由 {"image": ...} 和 {"text": ...} 组成
+type dashScopeMessage struct {
+	Role    string              `json:"role"`
+	Content []map[string]string `json:"content"`
+}
+
+// hasImages 判断对话中是否包含图片
+func hasImages(messages []Message) bool {
+	for _, msg := range messages {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type dashScopeParameters struct {
+	ResultFormat      string   `json:"result_format"`
+	IncrementalOutput bool     `json:"incremental_output,omitempty"`
+	EnableSearch      bool     `json:"enable_search,omitempty"`
+	Temperature       *float64 `json:"temperature,omitempty"`
+	TopP              *float64 `json:"top_p,omitempty"`
+	MaxTokens         int      `json:"max_tokens,omitempty"`
+	Seed              *int     `json:"seed,omitempty"`
+	Stop              []string `json:"stop,omitempty"`
+	PresencePenalty   *float64 `json:"presence_penalty,omitempty"`
+	EnableThinking    *bool    `json:"enable_thinking,omitempty"`
+	ThinkingBudget    *int     `json:"thinking_budget,omitempty"`
+}
+
+type dashScopeResponse struct {
+	RequestID string `json:"request_id"`
+	Output    struct {
+		Choices []struct {
+			Message      Message `json:"message"`
+			FinishReason string  `json:"finish_reason"`
+		} `json:"choices"`
+	} `json:"output"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func (d *DashScope) buildRequest(req ChatRequest, stream bool) dashScopeRequest {
+	var body dashScopeRequest
+	body.Model = req.Model
+	body.Input.Messages = req.Messages
+	if hasImages(req.Messages) {
+		// 包含图片时改用多模态接口，所有消息都转换为多段格式
+		messages := make([]dashScopeMessage, 0, len(req.Messages))
+		for _, msg := range req.Messages {
+			content := make([]map[string]string, 0, len(msg.Images)+1)
+			for _, url := range msg.Images {
+				content = append(content, map[string]string{"image": url})
+			}
+			content = append(content, map[string]string{"text": msg.Content})
+			messages = append(messages, dashScopeMessage{Role: msg.Role, Content: content})
+		}
+		body.Input.Messages = messages
+		body.multimodal = true
+	}
+	body.Parameters = dashScopeParameters{
+		ResultFormat:      "message",
+		IncrementalOutput: stream,
+		EnableSearch:      req.EnableSearch,
+		Temperature:       req.Temperature,
+		TopP:              req.TopP,
+		MaxTokens:         req.MaxTokens,
+		Seed:              req.Seed,
+		Stop:              req.Stop,
+		PresencePenalty:   req.PresencePenalty,
+		EnableThinking:    req.EnableThinking,
+		ThinkingBudget:    req.ThinkingBudget,
+	}
+	return body
+}
+
+// usage 转换为 OpenAI 格式的用量
+func (r dashScopeResponse) usage() *Usage {
+	return &Usage{
+		PromptTokens:     r.Usage.InputTokens,
+		CompletionTokens: r.Usage.OutputTokens,
+		TotalTokens:      r.Usage.TotalTokens,
+	}
+}
+
+// finishReason DashScope 在未结束时返回字符串 "null"
+func dashScopeFinishReason(reason string) FinishReason {
+	if reason == "null" {
+		return ""
+	}
+	return FinishReason(reason)
+}
+
+func (d *DashScope) do(ctx context.Context, body dashScopeRequest, stream bool) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+d.APIKey)
+	if stream {
+		header.Set("Accept", "text/event-stream")
+		header.Set("X-DashScope-SSE", "enable")
+	} else {
+		header.Set("Accept", "application/json")
+	}
+	return postJSON(ctx, httpClientOrDefault(d.HTTPClient), d.Retry, d.endpoint(body.multimodal), header, data)
+}
+
+// Stream 发起流式请求，返回的 ChatStream 需要调用方关闭
+func (d *DashScope) Stream(ctx context.Context, req ChatRequest) (*ChatStream, error) {
+	resp, err := d.do(ctx, d.buildRequest(req, true), true)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := sse.NewDecoder(resp.Body)
+	return newChatStream(resp.Body, func() (ChatChunk, error) {
+		var chunk ChatChunk
+		event, err := decoder.Next()
+		if err == io.EOF {
+			return chunk, io.EOF
+		}
+		if err != nil {
+			return chunk, fmt.Errorf("error reading stream: %w", err)
+		}
+
+		if apiErr := parseStreamError(event.Type, []byte(event.Data)); apiErr != nil {
+			return chunk, apiErr
+		}
+
+		var response dashScopeResponse
+		if err := json.Unmarshal([]byte(event.Data), &response); err != nil {
+			return chunk, fmt.Errorf("error parsing chunk: %w", err)
+		}
+
+		chunk.ID = response.RequestID
+		chunk.Model = req.Model
+		for i, choice := range response.Output.Choices {
+			chunk.Choices = append(chunk.Choices, ChunkChoice{
+				Index:        i,
+				Delta:        choice.Message,
+				FinishReason: dashScopeFinishReason(choice.FinishReason),
+			})
+		}
+		// DashScope 每个数据块都带有累计用量，只在结束时上报
+		if chunk.FinishReason() != "" {
+			chunk.Usage = response.usage()
+		}
+		return chunk, nil
+	}), nil
+}
+
+// Complete 发起非流式请求，返回完整响应
+func (d *DashScope) Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := d.do(ctx, d.buildRequest(req, false), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response dashScopeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	result := &ChatResponse{
+		ID:    response.RequestID,
+		Model: req.Model,
+		Usage: response.usage(),
+	}
+	for i, choice := range response.Output.Choices {
+		result.Choices = append(result.Choices, Choice{
+			Index:        i,
+			Message:      choice.Message,
+			FinishReason: dashScopeFinishReason(choice.FinishReason),
+		})
+	}
+	return result, nil
+}
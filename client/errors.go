@@ -0,0 +1,135 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError 表示接口返回的错误响应
+type APIError struct {
+	StatusCode int    // HTTP 状态码，流中的错误事件为 0
+	Code       string // 服务商错误码，如 "Throttling.RateQuota"
+	Type       string // 错误类型，如 "invalid_request_error"
+	Message    string // 错误描述
+	RequestID  string // 请求 ID，便于向服务商反馈问题
+	Body       string // 无法解析时保留的原始响应体
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString("API error")
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, ": status code: %d", e.StatusCode)
+	} else {
+		b.WriteString(": in-stream error")
+	}
+	if e.Code != "" {
+		fmt.Fprintf(&b, ", code: %s", e.Code)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ", message: %s", e.Message)
+	} else if e.Body != "" {
+		fmt.Fprintf(&b, ", body: %s", e.Body)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, ", request_id: %s", e.RequestID)
+	}
+	return b.String()
+}
+
+// Retryable 报告该错误是否值得重试（限流、超时和服务端错误）
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= 500
+}
+
+// errorPayload 兼容 OpenAI 格式 {"error":{...}}、Ollama 格式 {"error":"..."}
+// 和 DashScope 格式 {"code","message"} 的错误响应
+type errorPayload struct {
+	Error     json.RawMessage `json:"error"`
+	Code      string          `json:"code"`
+	Message   string          `json:"message"`
+	RequestID string          `json:"request_id"`
+}
+
+func (p errorPayload) hasError() bool {
+	return len(p.Error) > 0 && string(p.Error) != "null"
+}
+
+// fill 将错误信息写入 apiErr，返回是否包含错误信息
+func (p errorPayload) fill(apiErr *APIError) bool {
+	if p.hasError() {
+		var detail struct {
+			Message string          `json:"message"`
+			Type    string          `json:"type"`
+			Code    json.RawMessage `json:"code"`
+		}
+		if err := json.Unmarshal(p.Error, &detail); err == nil {
+			apiErr.Message = detail.Message
+			apiErr.Type = detail.Type
+			apiErr.Code = rawCode(detail.Code)
+		} else {
+			var message string
+			if err := json.Unmarshal(p.Error, &message); err == nil {
+				apiErr.Message = message
+			}
+		}
+	} else {
+		apiErr.Code = p.Code
+		apiErr.Message = p.Message
+	}
+	apiErr.RequestID = p.RequestID
+	return apiErr.Message != "" || apiErr.Code != ""
+}
+
+// parseAPIError 解析非 200 响应的错误信息
+func parseAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var payload errorPayload
+	if err := json.Unmarshal(body, &payload); err != nil || !payload.fill(apiErr) {
+		apiErr.Body = strings.TrimSpace(string(body))
+	}
+
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-Id")
+	}
+	return apiErr
+}
+
+// parseStreamError 解析流中的错误事件，数据块不是错误时返回 nil
+func parseStreamError(eventType string, data []byte) *APIError {
+	apiErr := &APIError{}
+
+	var payload errorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		if eventType != "error" {
+			return nil
+		}
+		apiErr.Body = strings.TrimSpace(string(data))
+		return apiErr
+	}
+
+	if !payload.hasError() && eventType != "error" {
+		return nil
+	}
+	if !payload.fill(apiErr) {
+		apiErr.Body = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
+
+// rawCode 兼容字符串和数字两种错误码
+func rawCode(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultOllamaURL 是本地 Ollama 服务的默认地址
+const DefaultOllamaURL = "http://localhost:11434"
+
+// Ollama 是 Ollama /api/chat 接口的客户端，实现 Provider
+type Ollama struct {
+	BaseURL    string
+	APIKey     string // 可选，通过反向代理访问时使用
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+}
+
+// NewOllama 创建 Ollama 客户端，baseURL 为空时使用 DefaultOllamaURL
+func NewOllama(baseURL string) *Ollama {
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	return &Ollama{
+		BaseURL:    baseURL,
+		HTTPClient: http.DefaultClient,
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
+// Endpoint 返回 /api/chat 接口地址
+func (o *Ollama) Endpoint() string {
+	url := strings.TrimRight(o.BaseURL, "/")
+	if strings.HasSuffix(url, "/api/chat") {
+		return url
+	}
+	return url + "/api/chat"
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Think    *bool           `json:"think,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+// ollamaMessage 是 Ollama 的消息格式，图片以不带 data URI 前缀的 base64 放在 images 中
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+func ollamaMessages(messages []Message) []ollamaMessage {
+	converted := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		m := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		for _, url := range msg.Images {
+			if i := strings.Index(url, ";base64,"); i >= 0 && strings.HasPrefix(url, "data:") {
+				url = url[i+len(";base64,"):]
+			}
+			m.Images = append(m.Images, url)
+		}
+		converted = append(converted, m)
+	}
+	return converted
+}
+
+type ollamaResponse struct {
+	Model           string    `json:"model"`
+	CreatedAt       time.Time `json:"created_at"`
+	Message         Message   `json:"message"`
+	Done            bool      `json:"done"`
+	DoneReason      string    `json:"done_reason"`
+	PromptEvalCount int       `json:"prompt_eval_count"`
+	EvalCount       int       `json:"eval_count"`
+	Error           string    `json:"error"`
+}
+
+func (r ollamaResponse) usage() *Usage {
+	return &Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+func (r ollamaResponse) finishReason() FinishReason {
+	if !r.Done {
+		return ""
+	}
+	if r.DoneReason == "" {
+		return FinishReasonStop
+	}
+	return FinishReason(r.DoneReason)
+}
+
+// ollamaOptions 将生成参数转换为 Ollama 的 options
+func ollamaOptions(req ChatRequest) map[string]any {
+	options := map[string]any{}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		options["top_p"] = *req.TopP
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if req.Seed != nil {
+		options["seed"] = *req.Seed
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	if req.PresencePenalty != nil {
+		options["presence_penalty"] = *req.PresencePenalty
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+func (o *Ollama) do(ctx context.Context, req ChatRequest, stream bool) (*http.Response, error) {
+	body := ollamaRequest{
+		Model:    req.Model,
+		Messages: ollamaMessages(req.Messages),
+		Stream:   stream,
+		Think:    req.EnableThinking,
+		Options:  ollamaOptions(req),
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	header := http.Header{}
+	if o.APIKey != "" {
+		header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+	return postJSON(ctx, httpClientOrDefault(o.HTTPClient), o.Retry, o.Endpoint(), header, data)
+}
+
+// Stream 发起流式请求，Ollama 以每行一个 JSON 对象的形式返回增量
+func (o *Ollama) Stream(ctx context.Context, req ChatRequest) (*ChatStream, error) {
+	resp, err := o.do(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	return newChatStream(resp.Body, func() (ChatChunk, error) {
+		var chunk ChatChunk
+		var response ollamaResponse
+		if err := decoder.Decode(&response); err != nil {
+			if err == io.EOF {
+				return chunk, io.EOF
+			}
+			return chunk, fmt.Errorf("error reading stream: %w", err)
+		}
+		if response.Error != "" {
+			return chunk, &APIError{Message: response.Error}
+		}
+
+		chunk.Model = response.Model
+		chunk.Created = response.CreatedAt.Unix()
+		chunk.Choices = []ChunkChoice{{
+			Delta:        response.Message,
+			FinishReason: response.finishReason(),
+		}}
+		if response.Done {
+			chunk.Usage = response.usage()
+		}
+		return chunk, nil
+	}), nil
+}
+
+// Complete 发起非流式请求，返回完整响应
+func (o *Ollama) Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := o.do(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+	if response.Error != "" {
+		return nil, &APIError{Message: response.Error}
+	}
+
+	return &ChatResponse{
+		Model:   response.Model,
+		Created: response.CreatedAt.Unix(),
+		Choices: []Choice{{
+			Message:      response.Message,
+			FinishReason: response.finishReason(),
+		}},
+		Usage: response.usage(),
+	}, nil
+}
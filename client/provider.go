@@ -0,0 +1,116 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Provider 是聊天后端的统一接口
+type Provider interface {
+	// Endpoint 返回请求发送到的地址
+	Endpoint() string
+	// Stream 发起流式请求，返回的 ChatStream 需要调用方关闭
+	Stream(ctx context.Context, req ChatRequest) (*ChatStream, error)
+	// Complete 发起非流式请求，返回完整响应
+	Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+}
+
+var (
+	_ Provider = (*Client)(nil)
+	_ Provider = (*DashScope)(nil)
+	_ Provider = (*Ollama)(nil)
+)
+
+// 支持的 Provider 名称
+const (
+	ProviderOpenAI    = "openai"    // OpenAI 兼容接口，包括 DashScope compatible-mode
+	ProviderDashScope = "dashscope" // DashScope 原生接口
+	ProviderOllama    = "ollama"    // Ollama /api/chat 接口
+)
+
+// NewProvider 按名称创建 Provider，name 为空时使用 OpenAI 兼容接口
+func NewProvider(name, baseURL, apiKey string, retry RetryPolicy) (Provider, error) {
+	switch name {
+	case "", ProviderOpenAI:
+		c := New(baseURL, apiKey)
+		c.Retry = retry
+		return c, nil
+	case ProviderDashScope:
+		d := NewDashScope(baseURL, apiKey)
+		d.Retry = retry
+		return d, nil
+	case ProviderOllama:
+		o := NewOllama(baseURL)
+		o.APIKey = apiKey
+		o.Retry = retry
+		return o, nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+}
+
+// postJSON 发送 JSON 请求并返回状态码为 200 的响应，对网络错误、429 和 5xx 按重试策略重试。
+// 非 200 响应以 *APIError 返回
+func postJSON(ctx context.Context, hc *http.Client, retry RetryPolicy, url string, header http.Header, body []byte) (*http.Response, error) {
+	attempts := retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	var retryAfter time.Duration
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			delay, ok := retry.backoff(attempt-1, retryAfter)
+			if !ok {
+				// 服务端要求等待的时间过长，直接返回原始错误
+				return nil, lastErr
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		for key, values := range header {
+			httpReq.Header[key] = values
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		resp, err := hc.Do(httpReq)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = fmt.Errorf("error sending request: %w", err)
+			retryAfter = 0
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading error response: %w, status code: %d", err, resp.StatusCode)
+		}
+
+		apiErr := parseAPIError(resp, bodyBytes)
+		if !apiErr.Retryable() {
+			return nil, apiErr
+		}
+		lastErr = apiErr
+		retryAfter = parseRetryAfter(resp)
+	}
+
+	return nil, lastErr
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 控制请求失败时的重试行为。
+// 只有在尚未收到任何流式数据时才会重试
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（包含首次请求），小于等于 1 表示不重试
+	BaseDelay   time.Duration // 首次重试的等待时间，之后按指数增长
+	MaxDelay    time.Duration // 单次等待时间上限，Retry-After 超过上限时不再重试
+	Jitter      float64       // 随机抖动比例，取值 0~1
+}
+
+// maxRetryAfter 是未设置 MaxDelay 时 Retry-After 的上限，避免异常的服务端或代理让请求长时间挂起
+const maxRetryAfter = time.Minute
+
+// DefaultRetryPolicy 返回默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// backoff 返回第 attempt 次重试（从 1 开始）前的等待时间，优先使用服务端的 Retry-After。
+// Retry-After 超过 MaxDelay（未设置时为 maxRetryAfter）时 ok 为 false，表示不应再重试
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) (delay time.Duration, ok bool) {
+	if retryAfter > 0 {
+		limit := p.MaxDelay
+		if limit <= 0 {
+			limit = maxRetryAfter
+		}
+		return retryAfter, retryAfter <= limit
+	}
+
+	delay = p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			delay = p.MaxDelay
+			break
+		}
+	}
+
+	if p.Jitter > 0 {
+		delta := float64(delay) * p.Jitter
+		delay += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+// parseRetryAfter 解析以秒数或 HTTP 日期表示的 Retry-After 头
+func parseRetryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleepContext 等待 d，ctx 被取消时提前返回 ctx.Err()
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
 
 	"github.com/spf13/cobra"
 
@@ -24,6 +21,11 @@ func main() {
 	rootCmd.AddCommand(commands.InitCommand())
 	rootCmd.AddCommand(commands.VersionCommand())
 	rootCmd.AddCommand(commands.UpdateCommand())
+	rootCmd.AddCommand(commands.SessionsCommand())
+	rootCmd.AddCommand(commands.ShellInitCommand())
+
+	// 全局 --output 标志
+	commands.AddOutputFlag(rootCmd)
 
 	// 移除 completion 和 help
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
@@ -32,31 +34,27 @@ func main() {
 	// 尝试加载配置并添加需要配置的命令
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		// 如果配置加载失败，只显示提示信息
-		fmt.Printf("⚠️  配置文件未找到或加载失败: %s\n", err)
-		fmt.Println("💡 请运行 'ask init' 初始化配置文件")
-		fmt.Println()
+		// 如果配置加载失败，只显示提示信息。写到标准错误，避免污染管道输出
+		fmt.Fprintf(os.Stderr, "⚠️  配置文件未找到或加载失败: %s\n", err)
+		fmt.Fprintln(os.Stderr, "💡 请运行 'ask init' 初始化配置文件")
+		fmt.Fprintln(os.Stderr)
 	} else {
 		// 配置加载成功，添加需要配置的命令
 		rootCmd.AddCommand(commands.ChatCommand(cfg))
 		rootCmd.AddCommand(commands.CmdCommand(cfg))
 		rootCmd.AddCommand(commands.TestCommand(cfg))
+
+		// 根命令直接提问时以单次问答模式运行
+		commands.SetupOneShot(rootCmd, cfg)
 	}
 
-	// Handle SIGINT signal to pause the conversation
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGINT)
+	// Ctrl-C 由各个交互命令自行处理：生成中断当前回复，空闲时退出
 
-	go func() {
-		for range signalChan {
-			fmt.Println("\n对话已暂停，按回车键继续...")
-			// 使用 bufio.Reader 读取单个回车即可继续，不需要输入内容
-			bufio.NewReader(os.Stdin).ReadString('\n')
-		}
-	}()
+	// 参数和标志错误以退出码 2 退出，与单次问答模式的其他参数错误一致
+	commands.MarkUsageErrors(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(commands.ExitCode(err))
 	}
 }
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"regexp"
+	"strings"
+
+	"Qwen-cli/attach"
+	"Qwen-cli/client"
+	"Qwen-cli/tokens"
+)
+
+// attachmentWarnRatio 附件占可用上下文的比例超过该值时给出提示
+const attachmentWarnRatio = 0.5
+
+// mentionPattern 匹配消息中的 @路径
+var mentionPattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// loadFiles 读取文件并显示每个文件的行数和 token 估算，被跳过的文件给出原因
+func loadFiles(patterns []string) ([]attach.File, error) {
+	result, err := attach.Load(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range result.Files {
+		statusf("📎 %s（%d 行，约 %d tokens）\n", f.Path, f.Lines, tokens.Count(attach.Format(f)))
+	}
+	for _, s := range result.Skipped {
+		statusf("⚠️  跳过 %s: %s\n", s.Path, s.Err)
+	}
+	return result.Files, nil
+}
+
+// mentions 返回消息中指向已存在文件、目录或能匹配到文件的通配符的 @路径。
+// 其余的 @（如邮箱、用户名）保持原样
+func mentions(text string) []string {
+	var patterns []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		p := m[2]
+		if !attach.Exists(p) {
+			// 路径可能紧跟着标点，如 "看看 @main.go，"
+			p = strings.TrimRight(p, ",.;:!?)]}'\"，。；：！？）】")
+			if p == "" || !attach.Exists(p) {
+				continue
+			}
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// compose 生成要发送的用户消息：/file 附加的文件和消息中 @ 提到的文件追加到消息末尾，
+// /image 附加的图片随消息发送。附件占用过多上下文时给出提示
+func (st *replState) compose(text string) client.Message {
+	msg := client.Message{Role: client.RoleUser, Content: text, Images: st.images}
+	st.images = nil
+	if len(msg.Images) > 0 && !st.model.Vision {
+		statusf("⚠️  模型 %s 不支持图片输入，已附加的图片不会发送\n", st.model.Name)
+		msg.Images = nil
+	}
+
+	files := st.attachments
+	st.attachments = nil
+	if patterns := mentions(text); len(patterns) > 0 {
+		mentioned, err := loadFiles(patterns)
+		if err != nil {
+			statusf("⚠️  %s\n", err)
+		}
+		files = append(files, mentioned...)
+	}
+	if len(files) == 0 {
+		return msg
+	}
+
+	parts := []string{text}
+	for _, f := range files {
+		parts = append(parts, attach.Format(f))
+	}
+	msg.Content = strings.Join(parts, "\n\n")
+	st.warnAttachmentSize(tokens.Count(msg.Content) - tokens.Count(text))
+	return msg
+}
+
+// warnAttachmentSize 附件超出或接近上下文窗口时提示
+func (st *replState) warnAttachmentSize(size int) {
+	budget, _ := contextBudget(st.model, st.params())
+	history := tokens.CountMessages(st.conversation)
+	switch {
+	case size+history > budget:
+		statusf("⚠️  附件约 %d tokens，加上对话历史（约 %d tokens）超出了可用的上下文（%d），较早的对话会被省略，附件过大时请求可能失败\n", size, history, budget)
+	case float64(size) > attachmentWarnRatio*float64(budget):
+		statusf("⚠️  附件约 %d tokens，占可用上下文的 %.0f%%\n", size, 100*float64(size)/float64(budget))
+	}
+}
+
+// runFileCommand 处理 /file，附加的文件随下一条消息发送；不带参数时列出待发送的文件
+func runFileCommand(st *replState, args string) error {
+	if args == "" {
+		if len(st.attachments) == 0 {
+			statusf("📎 没有待发送的文件，用法：/file <路径> [路径...]，支持目录和 ** 通配符\n")
+			return nil
+		}
+		statusf("📎 待发送的文件：\n")
+		for _, f := range st.attachments {
+			statusf("  %s（%d 行）\n", f.Path, f.Lines)
+		}
+		return nil
+	}
+
+	files, err := loadFiles(strings.Fields(args))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	st.attachments = append(st.attachments, files...)
+	size := 0
+	for _, f := range st.attachments {
+		size += tokens.Count(attach.Format(f))
+	}
+	statusf("📎 %d 个文件将随下一条消息发送（约 %d tokens）\n", len(st.attachments), size)
+	st.warnAttachmentSize(size)
+	return nil
+}
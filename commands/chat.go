@@ -2,36 +2,46 @@ package commands
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"Qwen-cli/client"
 	"Qwen-cli/config"
+	"Qwen-cli/session"
 	"Qwen-cli/utils"
 )
 
 func ChatCommand(cfg config.Config) *cobra.Command {
+	var generationFlags func() config.GenerationConfig
+	var resumeID string
+	var continueLast bool
+
 	chatCmd := &cobra.Command{
 		Use:   "chat",
 		Short: "与AI进行对话",
+		Long: `与AI进行多轮对话。
+
+会话会保存到配置目录的 sessions 子目录中，可以随时恢复：
+	 ask chat --continue        # 继续最近一次会话
+	 ask chat --resume          # 从列表中选择要恢复的会话
+	 ask chat --resume <id>     # 恢复指定会话（支持 ID 前缀）`,
 		Run: func(cmd *cobra.Command, args []string) {
 			reader := bufio.NewReader(cmd.InOrStdin())
-			fmt.Printf("\n🤖 欢迎使用通义千问聊天！输入 'exit' 结束对话。\n")
+			statusf("\n🤖 欢迎使用通义千问聊天！输入 'exit' 结束对话。\n")
 
 			// 获取环境信息
 			envInfo := utils.GetEnvironmentInfo()
 
 			// Initialize conversation history
-			conversation := []struct {
-				Role    string `json:"role"`
-				Content string `json:"content"`
-			}{
+			conversation := []client.Message{
 				{
 					Role: "system",
 					Content: fmt.Sprintf(`\{纯文本输出,清晰明了,纯文本输出,指明自己是 {role: Fromsko 定制的智能助手, 能够协助你解决各种问题.}列出访问的指令, 没有指令则默认为对话.}
@@ -56,116 +66,140 @@ func ChatCommand(cfg config.Config) *cobra.Command {
 				},
 			}
 
-			currentModel := cfg.Models["default"].Name
+			// 新建或恢复会话
+			store := session.DefaultStore()
+			var chatSession *session.Session
+			var err error
+			switch {
+			case continueLast:
+				chatSession, err = store.Latest()
+			case cmd.Flags().Changed("resume"):
+				// --resume 的值可选，"--resume <id>" 形式的 ID 会被解析为位置参数
+				if strings.TrimSpace(resumeID) == "" && len(args) > 0 {
+					resumeID = args[0]
+				}
+				chatSession, err = pickSession(store, reader, resumeID)
+			}
+			if err != nil {
+				statusf("❌ 无法恢复会话: %s\n", err)
+				return
+			}
+
+			activeModel := cfg.Models["default"]
+			if chatSession != nil {
+				activeModel = findModel(cfg, chatSession.Model)
+				conversation = chatSession.Conversation()
+				statusf("📂 已恢复会话 %s: %s（%d 条消息）\n", chatSession.ID, chatSession.Title, len(chatSession.Path()))
+			} else {
+				chatSession = session.New(activeModel.Name, "", conversation[0].Content)
+			}
+			provider, err := newProvider(cfg, activeModel)
+			if err != nil {
+				statusf("❌ 无法创建模型客户端: %s\n", err)
+				return
+			}
+			st := &replState{
+				cfg:          cfg,
+				reader:       reader,
+				conversation: conversation,
+				session:      chatSession,
+				role:         chatSession.Role,
+				model:        activeModel,
+				provider:     provider,
+				modelParams:  activeModel.GenerationConfig,
+				flagParams:   generationFlags(),
+			}
+			// persistMu 串行化会话和自动保存文件的写入。主循环在每次修改会话后保存，
+			// Ctrl-C 退出时只需等待正在进行的写入完成，不会写出不完整的文件
+			var persistMu sync.Mutex
+			saveSession := func() {
+				persistMu.Lock()
+				defer persistMu.Unlock()
+				if err := store.Save(st.session); err != nil {
+					statusf("⚠️  无法保存会话: %s\n", err)
+				}
+			}
+			st.save = saveSession
 			enableSearch := false
 
 			// 创建自动对话记录文件
 			var autoSaveFilePath string
 			configDir := config.GetConfigDir()
-			timestamp := time.Now().Format("20060102_150405")
+			autoSaveStarted := time.Now()
+			timestamp := autoSaveStarted.Format("20060102_150405")
 			autoSaveFileName := fmt.Sprintf("chat_auto_%s.md", timestamp)
 			autoSaveFilePath = filepath.Join(configDir, autoSaveFileName)
-			
+
 			// 确保配置目录存在
-			err := os.MkdirAll(configDir, 0755)
+			err = os.MkdirAll(configDir, 0755)
 			if err != nil {
-				fmt.Printf("⚠️  无法创建配置目录: %s\n", err)
+				statusf("⚠️  无法创建配置目录: %s\n", err)
 				autoSaveFilePath = "" // 设置为空，表示不进行自动保存
 			} else {
 				// 创建自动保存文件并写入头部信息
 				autoSaveFile, err := os.Create(autoSaveFilePath)
 				if err != nil {
-					fmt.Printf("⚠️  无法创建自动保存文件: %s\n", err)
+					statusf("⚠️  无法创建自动保存文件: %s\n", err)
 					autoSaveFilePath = "" // 设置为空，表示不进行自动保存
 				} else {
-					autoSaveFile.WriteString(fmt.Sprintf("# 通义千问对话记录\n\n开始时间: %s\n模型: %s\n\n---\n\n",
-						time.Now().Format("2006-01-02 15:04:05"), currentModel))
+					autoSaveFile.WriteString(autoSaveHeader(autoSaveStarted, st.model.Name))
 					autoSaveFile.Close()
-					fmt.Printf("📝 对话将自动记录到: %s\n", autoSaveFilePath)
+					// 自动记录与会话内容重复，记在会话中，搜索时不再单独索引
+					st.session.AddTranscript(autoSaveFilePath)
+					statusf("📝 对话将自动记录到: %s\n", autoSaveFilePath)
 				}
 			}
+			// rewriteTranscript 按当前分支重写自动保存文件
+			rewriteTranscript := func() {
+				persistMu.Lock()
+				defer persistMu.Unlock()
+				rewriteAutoSave(autoSaveFilePath, autoSaveStarted, st.model.Name, st.session.Path())
+			}
 
-			for {
-				fmt.Print("👤 > ")
-				text, _ := reader.ReadString('\n')
-				text = strings.TrimSpace(text)
-
-				// fmt.Printf("Debug: Received input: %s\n", text) // Debug print
-
-				if text == "exit" {
-					// 在退出前记录结束时间
-					if autoSaveFilePath != "" {
-						autoSaveFile, err := os.OpenFile(autoSaveFilePath, os.O_APPEND|os.O_WRONLY, 0644)
-						if err == nil {
-							autoSaveFile.WriteString(fmt.Sprintf("\n---\n\n结束时间: %s\n",
-								time.Now().Format("2006-01-02 15:04:05")))
-							autoSaveFile.Close()
-							fmt.Printf("📝 对话记录已保存到: %s\n", autoSaveFilePath)
-						}
-					}
-					break
+			// 生成过程中 Ctrl-C 只中断当前回复，空闲时 Ctrl-C 结束对话
+			interrupts := newInterrupter(func() {
+				// 会话已由主循环保存，这里不读取可能正在被修改的 st.session，
+				// 持有锁直到退出，之后的写入不会开始
+				persistMu.Lock()
+				finishAutoSave(autoSaveFilePath)
+			})
+			defer interrupts.stop()
+
+			// compact 将早期对话压缩为摘要，auto 为 true 时没有可压缩内容不提示
+			compact := func(auto bool) {
+				ctx := interrupts.begin()
+				compacted, err := compactSession(ctx, st.provider, st.model, st.params(), st.session)
+				interrupts.end()
+				if compacted > 0 {
+					before := st.conversation
+					st.conversation = st.session.Conversation()
+					reportCompaction(compacted, before, st.conversation)
+					saveSession()
 				}
+				switch {
+				case auto && errors.Is(err, errNothingToCompact):
+				case errors.Is(err, context.Canceled):
+					statusf("❌ 已取消压缩\n")
+				case err != nil:
+					statusf("❌ 压缩失败: %s\n", err)
+				}
+			}
 
-				// Add user message to conversation history if it's not a command
-				if !strings.HasPrefix(text, "/") {
-					conversation = append(conversation, struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					}{
-						Role:    "user",
-						Content: text,
-					})
-				} else {
-					switch {
-					case strings.HasPrefix(text, "/model"):
-						fmt.Println("🤖 切换模型：")
-						models := []string{}
-						for _, model := range cfg.Models {
-							models = append(models, model.Name)
-							fmt.Printf("  %d. %s\n", len(models), model.Name)
-						}
-						fmt.Print("👉 请选择模型编号：")
-						modelChoice, _ := reader.ReadString('\n')
-						modelChoice = strings.TrimSpace(modelChoice)
-						modelIndex := 0
-						fmt.Sscanf(modelChoice, "%d", &modelIndex)
-						if modelIndex > 0 && modelIndex <= len(models) {
-							currentModel = models[modelIndex-1]
-							fmt.Printf("已切换到模型：%s\n", currentModel)
-						} else {
-							fmt.Println("❌ 无效的模型编号，未进行变更。")
-						}
-						continue
-					case strings.HasPrefix(text, "/prompt"):
-						fmt.Println("🎭 可用的角色提示词：")
-						prompts := []string{}
-						for role := range cfg.Roles {
-							prompts = append(prompts, role)
-							fmt.Printf("  %d. %s\n", len(prompts), role)
-						}
-						fmt.Print("👉 请选择角色提示词编号：")
-						promptChoice, _ := reader.ReadString('\n')
-						promptChoice = strings.TrimSpace(promptChoice)
-						promptIndex := 0
-						fmt.Sscanf(promptChoice, "%d", &promptIndex)
-						if promptIndex > 0 && promptIndex <= len(prompts) {
-							newPrompt := prompts[promptIndex-1]
-							conversation[0] = struct {
-								Role    string `json:"role"`
-								Content string `json:"content"`
-							}{
-								Role:    "system",
-								Content: cfg.Roles[newPrompt],
-							}
-							fmt.Printf("已切换到角色提示词：%s\n", newPrompt)
-						} else {
-							fmt.Println("❌ 无效的角色提示词编号，未进行变更。")
-						}
-						continue
-					case strings.HasPrefix(text, "/online"):
+			// 本轮使用的模型，/retry --model 可以临时换用其他模型
+			var turnModel config.ModelConfig
+			var turnProvider client.Provider
+			// 撤销或重新生成后历史已改变，回复后需要重写自动保存文件
+			historyChanged := false
+
+			commands := newCommandRegistry()
+			commands.add(
+				slashCommand{
+					Name: "/online",
+					Help: "开启/关闭联网搜索",
+					Run: func(st *replState, args string) error {
 						if enableSearch {
 							fmt.Println("🌐 联网搜索已开启。是否关闭？(y/n)")
-							choice, _ := reader.ReadString('\n')
+							choice, _ := st.reader.ReadString('\n')
 							choice = strings.TrimSpace(choice)
 							if choice == "y" || choice == "Y" {
 								enableSearch = false
@@ -177,100 +211,278 @@ func ChatCommand(cfg config.Config) *cobra.Command {
 							enableSearch = true
 							fmt.Println("🌐 联网搜索已开启。")
 						}
-						continue
-					case strings.Contains(text, "/save -all"):
-						saveFullConversation(conversation)
-						continue
-					case strings.Contains(text, "/save"):
-						// fmt.Println("调试: 正在尝试保存最后一次回复...") // 调试信息
-						saveLastResponse(conversation)
-						continue
-					default:
-						// fmt.Printf("未知命令: %s\n", text) // Debug print
-						continue
-					}
-				}
+						return nil
+					},
+				},
+				slashCommand{
+					Name: "/retry",
+					Args: "[--model 名称]",
+					Help: "重新生成上一条回复，可临时换用其他模型",
+					Run: func(st *replState, args string) error {
+						last := st.session.LastUser()
+						if last < 0 {
+							return errors.New("没有可以重新生成的问题")
+						}
+						if name := retryModelName("/retry " + args); name != "" {
+							model, ok := lookupModel(cfg, name)
+							if !ok {
+								return fmt.Errorf("未找到模型: %s", name)
+							}
+							switched, err := newProvider(cfg, model)
+							if err != nil {
+								return fmt.Errorf("无法切换到模型 %s: %w", model.Name, err)
+							}
+							turnModel, turnProvider = model, switched
+						}
+						st.session.Truncate(last + 1)
+						st.conversation = st.session.Conversation()
+						historyChanged, st.reply = true, true
+						statusf("🔁 正在使用 %s 重新生成...\n", turnModel.Name)
+						return nil
+					},
+				},
+				slashCommand{
+					Name: "/edit",
+					Help: "在 $EDITOR 中修改上一个问题并重新发送",
+					Run: func(st *replState, args string) error {
+						last := st.session.LastUser()
+						if last < 0 {
+							return errors.New("没有可以编辑的问题")
+						}
+						// 编辑器运行期间 Ctrl-C 交给编辑器处理，不退出对话
+						interrupts.begin()
+						edited, err := editText(st.session.Path()[last].Content, "ask-edit-*.md")
+						interrupts.end()
+						if err != nil {
+							return err
+						}
+						if edited == "" {
+							return errors.New("内容为空，已取消编辑")
+						}
+						st.session.Truncate(last)
+						st.session.Append(session.Message{Role: client.RoleUser, Content: edited})
+						st.conversation = st.session.Conversation()
+						historyChanged, st.reply = true, true
+						fmt.Printf("👤 > %s\n", edited)
+						return nil
+					},
+				},
+				slashCommand{
+					Name: "/undo",
+					Help: "撤销上一轮问答",
+					Run: func(st *replState, args string) error {
+						last := st.session.LastUser()
+						if last < 0 {
+							return errors.New("没有可撤销的对话")
+						}
+						removed := len(st.session.Path()) - last
+						st.session.Truncate(last)
+						st.conversation = st.session.Conversation()
+						saveSession()
+						rewriteTranscript()
+						fmt.Printf("↩️  已撤销上一轮对话（%d 条消息）\n", removed)
+						return nil
+					},
+				},
+				slashCommand{
+					Name: "/branch",
+					Args: "[n]",
+					Help: "从当前分支的第 n 条消息分叉出新分支，原分支保持不变",
+					Run: func(st *replState, args string) error {
+						n := 0
+						if args != "" {
+							fmt.Sscanf(args, "%d", &n)
+						} else {
+							n = pickMessage(st.session, st.reader)
+						}
+						b, err := st.session.Fork(n)
+						if err != nil {
+							return err
+						}
+						st.conversation = st.session.Conversation()
+						saveSession()
+						rewriteTranscript()
+						fmt.Printf("🌿 已从第 %d 条消息创建分支 %s 并切换过去，原分支保持不变\n", n, b.ID)
+						return nil
+					},
+				},
+				slashCommand{
+					Name: "/branches",
+					Help: "列出所有分支",
+					Run: func(st *replState, args string) error {
+						printBranches(st.session)
+						return nil
+					},
+				},
+				slashCommand{
+					Name: "/checkout",
+					Args: "[id]",
+					Help: "切换到指定分支",
+					Run: func(st *replState, args string) error {
+						id := args
+						if id == "" {
+							printBranches(st.session)
+							fmt.Print("👉 请输入分支 ID：")
+							id, _ = st.reader.ReadString('\n')
+							id = strings.TrimSpace(id)
+						}
+						if err := st.session.Checkout(id); err != nil {
+							return err
+						}
+						st.conversation = st.session.Conversation()
+						saveSession()
+						rewriteTranscript()
+						fmt.Printf("🌿 已切换到分支 %s\n", id)
+						return nil
+					},
+				},
+				slashCommand{
+					Name: "/compact",
+					Help: "让模型将早期对话压缩为摘要，保留最近两轮原文",
+					Run: func(st *replState, args string) error {
+						statusf("🗜️  正在压缩早期对话...\n")
+						compact(false)
+						return nil
+					},
+				},
+			)
 
-				params := struct {
-					Model    string `json:"model"`
-					Messages []struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					} `json:"messages"`
-					Stream       bool `json:"stream"`
-					EnableSearch bool `json:"enable_search,omitempty"`
-				}{
-					Model:        currentModel,
-					Messages:     conversation,
-					Stream:       true,
-					EnableSearch: enableSearch,
-				}
+			// 支持多行输入、历史记录和 Tab 补全
+			input := newInputEditor(cfg, reader, cmd.InOrStdin(), commands.names())
 
-				jsonParams, _ := json.Marshal(params)
+			for {
+				text, err := input.ReadLine(inputPrompt())
+				text = strings.TrimSpace(text)
+				// 输入结束（如管道关闭）或在空行上按 Ctrl-C 时按 exit 处理
+				if err != nil && text == "" {
+					text = "exit"
+				}
+				if text == "" {
+					continue
+				}
 
-				var fullResponse strings.Builder
+				turnModel, turnProvider = st.model, st.provider
+				historyChanged = false
+				st.reply, st.quit = false, false
 
-				err := client.Client(cfg.APIURL, cfg.APIKey, jsonParams, func(data []byte) {
-					var response struct {
-						Choices []struct {
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-						} `json:"choices"`
-					}
+				handled, err := commands.dispatch(st, text)
+				if err != nil {
+					fmt.Printf("❌ %s\n", err)
+					continue
+				}
+				if st.quit {
+					saveSession()
+					persistMu.Lock()
+					finishAutoSave(autoSaveFilePath)
+					persistMu.Unlock()
+					statusf("💾 会话已保存，使用 'ask chat --resume %s' 继续\n", st.session.ID)
+					break
+				}
+				if !handled {
+					dropUnanswered(st)
+					// 附加 /file、/image 和 @路径 提到的文件
+					msg := st.compose(text)
+					st.conversation = append(st.conversation, msg)
+					st.session.Append(session.Message{Role: client.RoleUser, Content: msg.Content, Images: msg.Images})
+				} else if !st.reply {
+					continue
+				}
 
-					err := json.Unmarshal(data, &response)
-					if err != nil {
-						fmt.Printf("Error parsing response: %s\n", err)
-						return
-					}
+				params := st.params()
+				if turnModel.Name != st.model.Name {
+					params = turnModel.GenerationConfig.Merge(st.flagParams).Merge(st.sessionParams)
+				}
+				req := client.ChatRequest{
+					Model:        turnModel.Name,
+					Messages:     fitContext(st.conversation, turnModel, params),
+					EnableSearch: enableSearch,
+				}
+				applyGeneration(&req, params)
 
-					if len(response.Choices) > 0 {
-						content := response.Choices[0].Delta.Content
-						fullResponse.WriteString(content)
-						utils.TypewriterEffect(content, false)
-					}
+				printer := newReplyPrinter(os.Stdout, turnModel.Name, os.Stdout, func(content string) {
+					utils.TypewriterEffect(content, false)
 				})
-
-				if err != nil {
-					fmt.Printf("Error: %s\n", err)
+				ctx := interrupts.begin()
+				result, err := streamReply(ctx, turnProvider, req, printer.delta, printer.reasoning)
+				interrupts.end()
+
+				fullResponse := result.Content
+				interrupted := errors.Is(err, context.Canceled)
+				if interrupted {
+					// 保留已生成的部分回复，并标记为已中断
+					fullResponse += interruptedMarker
+				}
+				printer.finish(result, fullResponse, interrupted, err)
+				if err != nil && !interrupted {
+					if !machineOutput() {
+						fmt.Printf("Error: %s\n", err)
+					}
+					// 问题保留在历史末尾，/retry 可以重新发送，输入新问题时被丢弃
+					statusf("💡 输入 /retry 重新发送这个问题\n")
 					continue
 				}
 
 				// Print the final newline if needed
-				utils.TypewriterEffect("", true)
+				if !machineOutput() {
+					utils.TypewriterEffect("", true)
+				}
 
 				// Add assistant message to conversation history
-				conversation = append(conversation, struct {
-					Role    string `json:"role"`
-					Content string `json:"content"`
-				}{
+				// 思考过程不加入历史，避免下一轮重复发送
+				st.conversation = append(st.conversation, client.Message{
 					Role:    "assistant",
-					Content: fullResponse.String(),
+					Content: fullResponse,
 				})
+				st.lastReasoning = result.Reasoning
+
+				reasoning := ""
+				if cfg.SaveThinking {
+					reasoning = result.Reasoning
+				}
+				st.session.Append(session.Message{
+					Role:        client.RoleAssistant,
+					Content:     fullResponse,
+					Model:       turnModel.Name,
+					Reasoning:   reasoning,
+					Interrupted: interrupted,
+				})
+				st.session.AddUsage(result.Usage)
+				if result.Usage != nil {
+					st.lastUsage = result.Usage
+				}
+				saveSession()
 
 				// 自动追加对话到文件
-				if autoSaveFilePath != "" {
+				if historyChanged {
+					rewriteTranscript()
+				} else if autoSaveFilePath != "" {
 					// 获取用户最后一条消息
 					lastUserMessage := ""
-					if len(conversation) >= 2 {
-						for i := len(conversation) - 2; i >= 0; i-- {
-							if conversation[i].Role == "user" {
-								lastUserMessage = conversation[i].Content
+					if len(st.conversation) >= 2 {
+						for i := len(st.conversation) - 2; i >= 0; i-- {
+							if st.conversation[i].Role == "user" {
+								lastUserMessage = st.conversation[i].Content
 								break
 							}
 						}
 					}
-					
+
 					// 追加用户和AI的对话到文件
+					persistMu.Lock()
 					autoSaveFile, err := os.OpenFile(autoSaveFilePath, os.O_APPEND|os.O_WRONLY, 0644)
 					if err == nil {
 						if lastUserMessage != "" {
 							autoSaveFile.WriteString(fmt.Sprintf("## 👤 用户\n%s\n\n", lastUserMessage))
 						}
-						autoSaveFile.WriteString(fmt.Sprintf("## 🤖 AI助手\n%s\n\n---\n\n", fullResponse.String()))
+						autoSaveFile.WriteString(transcriptReply(fullResponse, reasoning))
 						autoSaveFile.Close()
 					}
+					persistMu.Unlock()
+				}
+
+				if needsCompaction(cfg, st.conversation, st.model, params) {
+					statusf("🗜️  对话接近上下文上限，正在自动压缩早期内容...\n")
+					compact(true)
 				}
 			}
 		},
@@ -287,23 +499,194 @@ func ChatCommand(cfg config.Config) *cobra.Command {
 	// 	return completions, cobra.ShellCompDirectiveNoFileComp
 	// }
 
+	generationFlags = addGenerationFlags(chatCmd)
+	chatCmd.Flags().StringVar(&resumeID, "resume", "", "恢复已保存的会话，不指定 ID 时从列表中选择")
+	chatCmd.Flags().Lookup("resume").NoOptDefVal = " "
+	chatCmd.Flags().BoolVarP(&continueLast, "continue", "c", false, "继续最近一次会话")
+	chatCmd.MarkFlagsMutuallyExclusive("resume", "continue")
+
 	return chatCmd
 }
 
-// Function to save the last AI response
-func saveLastResponse(conversation []struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}) {
+// dropUnanswered 丢弃当前分支末尾没有回复的问题（上一轮请求失败时留下），
+// 避免向模型发送连续两条用户消息
+func dropUnanswered(st *replState) {
+	path := st.session.Path()
+	if n := len(path); n > 1 && path[n-1].Role == client.RoleUser {
+		st.session.Truncate(n - 1)
+		st.conversation = st.session.Conversation()
+	}
+}
+
+// findModel 按模型名称查找配置，找不到时沿用默认模型的配置
+func findModel(cfg config.Config, name string) config.ModelConfig {
+	for _, key := range sortedModelKeys(cfg.Models) {
+		if cfg.Models[key].Name == name {
+			return cfg.Models[key]
+		}
+	}
+	model := cfg.Models["default"]
+	if name != "" {
+		model.Name = name
+	}
+	return model
+}
+
+// pickSession 按 ID 加载会话，id 为空时列出最近的会话供用户选择
+func pickSession(store *session.Store, reader *bufio.Reader, id string) (*session.Session, error) {
+	if id = strings.TrimSpace(id); id != "" {
+		return store.Load(id)
+	}
+
+	sessions, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, session.ErrNotFound
+	}
+	if len(sessions) > 20 {
+		sessions = sessions[:20]
+	}
+
+	fmt.Println("📂 最近的会话：")
+	for i, s := range sessions {
+		fmt.Printf("  %d. %s  %s  %s\n", i+1, s.UpdatedAt.Format("2006-01-02 15:04"), s.ID, s.Title)
+	}
+	fmt.Print("👉 请选择会话编号：")
+	choice, _ := reader.ReadString('\n')
+	index := 0
+	fmt.Sscanf(strings.TrimSpace(choice), "%d", &index)
+	if index < 1 || index > len(sessions) {
+		return nil, errors.New("无效的会话编号")
+	}
+	return sessions[index-1], nil
+}
+
+// printBranches 列出会话的所有分支
+func printBranches(s *session.Session) {
+	fmt.Println("🌿 分支：")
+	for _, b := range s.Branches {
+		marker := " "
+		if b.ID == s.Current {
+			marker = "*"
+		}
+		path := s.BranchPath(b)
+		if len(path) > 0 && path[0].Role == client.RoleSystem {
+			path = path[1:]
+		}
+		fork := ""
+		for i, msg := range path {
+			if msg.ID == b.Fork {
+				fork = fmt.Sprintf("，从第 %d 条分叉", i+1)
+			}
+		}
+		last := ""
+		if len(path) > 0 {
+			last = summarize(path[len(path)-1].Content, 30)
+		}
+		fmt.Printf("  %s %-6s %3d 条消息%s  %s\n", marker, b.ID, len(path), fork, last)
+	}
+}
+
+// pickMessage 列出当前分支的消息供用户选择分叉位置，返回消息编号
+func pickMessage(s *session.Session, reader *bufio.Reader) int {
+	path := s.Path()
+	if len(path) > 0 && path[0].Role == client.RoleSystem {
+		path = path[1:]
+	}
+	fmt.Println("🌿 当前分支的消息：")
+	for i, msg := range path {
+		icon := "👤"
+		if msg.Role == client.RoleAssistant {
+			icon = "🤖"
+		}
+		fmt.Printf("  %d. %s %s\n", i+1, icon, summarize(msg.Content, 50))
+	}
+	fmt.Print("👉 请选择分叉位置（新分支包含该条消息）：")
+	choice, _ := reader.ReadString('\n')
+	n := 0
+	fmt.Sscanf(strings.TrimSpace(choice), "%d", &n)
+	return n
+}
+
+// retryModelName 解析 /retry --model <名称> 中的模型名称
+func retryModelName(text string) string {
+	fields := strings.Fields(text)
+	for i := 1; i < len(fields); i++ {
+		switch {
+		case (fields[i] == "--model" || fields[i] == "-m") && i+1 < len(fields):
+			return fields[i+1]
+		case strings.HasPrefix(fields[i], "--model="):
+			return strings.TrimPrefix(fields[i], "--model=")
+		}
+	}
+	return ""
+}
+
+// autoSaveHeader 返回自动保存文件的头部信息
+func autoSaveHeader(started time.Time, model string) string {
+	return fmt.Sprintf("# 通义千问对话记录\n\n开始时间: %s\n模型: %s\n\n---\n\n",
+		started.Format("2006-01-02 15:04:05"), model)
+}
+
+// rewriteAutoSave 按会话当前的消息重写自动保存文件，用于撤销、编辑或重新生成之后
+func rewriteAutoSave(autoSaveFilePath string, started time.Time, model string, messages []session.Message) {
+	if autoSaveFilePath == "" {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(autoSaveHeader(started, model))
+	for _, msg := range messages {
+		switch msg.Role {
+		case client.RoleUser:
+			b.WriteString(fmt.Sprintf("## 👤 用户\n%s\n\n", msg.Content))
+		case client.RoleAssistant:
+			b.WriteString(transcriptReply(msg.Content, msg.Reasoning))
+		}
+	}
+	if err := os.WriteFile(autoSaveFilePath, []byte(b.String()), 0644); err != nil {
+		statusf("⚠️  无法更新自动保存文件: %s\n", err)
+	}
+}
+
+// transcriptReply 返回自动保存文件中的一条回复，思考过程放在可折叠的 <details> 中
+func transcriptReply(content, reasoning string) string {
+	var b strings.Builder
+	b.WriteString("## 🤖 AI助手\n")
+	if reasoning != "" {
+		b.WriteString(fmt.Sprintf("<details>\n<summary>💭 思考过程</summary>\n\n%s\n\n</details>\n\n", reasoning))
+	}
+	b.WriteString(fmt.Sprintf("%s\n\n---\n\n", content))
+	return b.String()
+}
+
+// finishAutoSave 在自动保存文件末尾记录结束时间
+func finishAutoSave(autoSaveFilePath string) {
+	if autoSaveFilePath == "" {
+		return
+	}
+	autoSaveFile, err := os.OpenFile(autoSaveFilePath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	autoSaveFile.WriteString(fmt.Sprintf("\n---\n\n结束时间: %s\n",
+		time.Now().Format("2006-01-02 15:04:05")))
+	autoSaveFile.Close()
+	statusf("📝 对话记录已保存到: %s\n", autoSaveFilePath)
+}
+
+// Function to save the last AI response, returns the file name or "" on failure
+func saveLastResponse(conversation []client.Message) string {
 	if len(conversation) == 0 {
 		fmt.Println("没有可保存的消息。")
-		return
+		return ""
 	}
 
 	lastMessage := conversation[len(conversation)-1]
 	if lastMessage.Role != "assistant" {
 		fmt.Println("最后一个消息不是AI回复。")
-		return
+		return ""
 	}
 
 	timestamp := time.Now().Format("20060102150405")
@@ -311,28 +694,26 @@ func saveLastResponse(conversation []struct {
 	file, err := os.Create(fileName)
 	if err != nil {
 		fmt.Printf("创建文件失败: %s\n", err)
-		return
+		return ""
 	}
 	defer file.Close()
 
 	_, err = file.WriteString(fmt.Sprintf("# 最后一次AI回复\n%s\n", lastMessage.Content))
 	if err != nil {
 		fmt.Printf("写入文件失败: %s\n", err)
-		return
+		return ""
 	}
 
 	fmt.Printf("已保存最后一次AI回复到 %s\n", fileName)
 	// fmt.Printf("调试信息: 文件路径 - %s\n", fileName) // Debug print
+	return fileName
 }
 
-// Function to save the full conversation
-func saveFullConversation(conversation []struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}) {
+// Function to save the full conversation, returns the file name or "" on failure
+func saveFullConversation(conversation []client.Message) string {
 	if len(conversation) == 0 {
 		fmt.Println("没有可保存的消息。")
-		return
+		return ""
 	}
 
 	timestamp := time.Now().Format("20060102150405")
@@ -340,7 +721,7 @@ func saveFullConversation(conversation []struct {
 	file, err := os.Create(fileName)
 	if err != nil {
 		fmt.Printf("创建文件失败: %s\n", err)
-		return
+		return ""
 	}
 	defer file.Close()
 
@@ -348,11 +729,11 @@ func saveFullConversation(conversation []struct {
 		_, err := file.WriteString(fmt.Sprintf("## %s\n%s\n\n", msg.Role, msg.Content))
 		if err != nil {
 			fmt.Printf("写入文件失败: %s\n", err)
-			return
+			return ""
 		}
 	}
 
 	fmt.Printf("已保存完整对话到 %s\n", fileName)
 	// fmt.Printf("调试信息: 文件路径 - %s\n", fileName) // Debug print
+	return fileName
 }
-
@@ -2,12 +2,12 @@ package commands
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
-	"runtime"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -16,313 +16,272 @@ import (
 	"Qwen-cli/utils"
 )
 
-func CmdCommand(cfg config.Config) *cobra.Command {
-	cmdCmd := &cobra.Command{
-		Use:   "cmd",
-		Short: "AI助手 - 支持普通聊天和命令执行",
-		Long: `AI助手支持普通聊天和系统命令生成执行两种模式。
-两种模式共享对话上下文，可以无缝切换。
-
-使用方法：
-	 ask cmd                    # 启动AI助手
-	 ask cmd "描述您的需求"       # 直接描述需求，AI会生成命令
-
-功能特性：
-	 - 普通聊天：直接输入文本进行对话
-	 - 命令模式：使用 /cmd 前缀生成并执行系统命令
-	 - 上下文共享：两种模式共享对话历史`,
-		Run: func(cmd *cobra.Command, args []string) {
-			reader := bufio.NewReader(cmd.InOrStdin())
-			
-			// 初始化对话历史
-			conversation := []struct {
-				Role    string `json:"role"`
-				Content string `json:"content"`
-			}{
-				{
-					Role: "system",
-					Content: `你是一个专业的系统命令助手。你的唯一任务是根据用户的需求生成合适的系统命令。
+// commandSystemPrompt 是命令模式的系统提示词，要求模型以 shell.Proposal 的 JSON 格式回复
+const commandSystemPrompt = `你是一个专业的系统命令助手。你的唯一任务是根据用户的需求生成合适的系统命令。
 
 重要规则：
-1. 只输出可执行的命令，不要任何解释、描述或回答
+1. 只回复一个 JSON 对象，不要使用代码块，也不要在 JSON 之外输出任何文字，格式为：
+   {"command": "要执行的命令", "explanation": "一句话说明命令的作用", "risk": "low"}
+   risk 是命令的风险等级：low（只读或容易撤销）、medium（修改或删除少量文件、改变配置）、high（删除大量数据、提权或影响整个系统）
 2. 用户已经通过 /cmd 前缀明确表示需要命令，所以所有输入都是命令请求
 3. 确保命令安全，避免破坏性操作
 4. 如果需要多个步骤，请使用 && 或 ; 连接
 5. 根据操作系统选择合适的命令语法（Windows使用cmd或PowerShell，macOS/Linux使用bash）
 6. 优先使用跨平台的命令
-7. 如果用户需求不明确，请询问具体细节
+7. 如果用户需求不明确，command 留空，在 explanation 中询问具体细节
 
 示例：
 用户：查看当前目录的文件
-输出：ls -la
+输出：{"command": "ls -la", "explanation": "列出当前目录的所有文件及详细信息", "risk": "low"}
 
 用户：创建一个名为test的目录
-输出：mkdir test
+输出：{"command": "mkdir test", "explanation": "创建 test 目录", "risk": "low"}
 
 用户：查看系统信息
-输出：uname -a
+输出：{"command": "uname -a", "explanation": "显示内核和系统信息", "risk": "low"}
 
 用户：查看docker容器
-输出：docker ps -a
+输出：{"command": "docker ps -a", "explanation": "列出所有容器，包括已停止的", "risk": "low"}
 
 用户：查看端口8080是否被占用
-输出：lsof -i :8080
+输出：{"command": "lsof -i :8080", "explanation": "查看占用 8080 端口的进程", "risk": "low"}
 
 项目信息：
-如果用户询问项目相关信息，请提供以下信息：
+如果用户询问项目相关信息，command 留空，在 explanation 中提供以下信息：
 - 项目地址：https://github.com/oAo-lab/Qwen-cli
 - 项目名称：Qwen-cli
-- 项目描述：通义千问命令行客户端，支持多模型对话和角色切换`,
+- 项目描述：通义千问命令行客户端，支持多模型对话和角色切换`
+
+func CmdCommand(cfg config.Config) *cobra.Command {
+	var generationFlags func() config.GenerationConfig
+	var timeout time.Duration
+
+	cmdCmd := &cobra.Command{
+		Use:   "cmd",
+		Short: "AI助手 - 支持普通聊天和命令执行",
+		Long: `AI助手支持普通聊天和系统命令生成执行两种模式。
+两种模式共享对话上下文，可以无缝切换。
+
+使用方法：
+	 ask cmd                    # 启动AI助手
+	 ask cmd "描述您的需求"       # 直接描述需求，AI会生成命令
+
+功能特性：
+	 - 普通聊天：直接输入文本进行对话
+	 - 命令模式：使用 /cmd 前缀生成并执行系统命令
+	 - 上下文共享：两种模式共享对话历史`,
+		Run: func(cmd *cobra.Command, args []string) {
+			reader := bufio.NewReader(cmd.InOrStdin())
+
+			policy, err := commandPolicy(cfg)
+			if err != nil {
+				statusf("❌ 配置中的 command_policy 无效: %s\n", err)
+				return
+			}
+
+			// 生成或执行过程中 Ctrl-C 只中断当前轮次，空闲时 Ctrl-C 退出
+			interrupts := newInterrupter(func() {
+				statusf("👋 再见！\n")
+			})
+			defer interrupts.stop()
+
+			// 初始化对话历史
+			conversation := []client.Message{
+				{
+					Role:    "system",
+					Content: commandSystemPrompt,
 				},
 			}
 
-			currentModel := cfg.Models["default"].Name
-			
+			activeModel := cfg.Models["default"]
+			provider, err := newProvider(cfg, activeModel)
+			if err != nil {
+				statusf("❌ 无法创建模型客户端: %s\n", err)
+				return
+			}
+			st := &replState{
+				cfg:          cfg,
+				reader:       reader,
+				conversation: conversation,
+				model:        activeModel,
+				provider:     provider,
+				modelParams:  activeModel.GenerationConfig,
+				flagParams:   generationFlags(),
+			}
+
+			// 执行命令前的确认，可以编辑、解释、复制或重新生成命令
+			review := &commandReview{st: st, policy: policy, interrupts: interrupts}
+
 			// 获取环境信息
 			osInfo := utils.GetEnvironmentInfo()
 
 			// 如果有参数，直接使用作为用户请求（非交互模式）
 			if len(args) > 0 {
 				userRequest := strings.Join(args, " ")
-				
+
 				// 添加用户请求到对话历史
-				conversation = append(conversation, struct {
-					Role    string `json:"role"`
-					Content string `json:"content"`
-				}{
+				st.conversation = append(st.conversation, client.Message{
 					Role:    "user",
 					Content: userRequest,
 				})
 
 				// 准备API请求
-				params := struct {
-					Model    string `json:"model"`
-					Messages []struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					} `json:"messages"`
-					Stream bool `json:"stream"`
-				}{
-					Model:    currentModel,
-					Messages: conversation,
-					Stream:   true,
+				req := client.ChatRequest{
+					Model:    st.model.Name,
+					Messages: st.conversation,
 				}
+				applyGeneration(&req, st.params())
 
-				jsonParams, _ := json.Marshal(params)
-
-				fmt.Printf("\n🤔 AI正在思考...\n")
-
-				var fullResponse strings.Builder
-
-				// 调用AI生成命令
-				err := client.Client(cfg.APIURL, cfg.APIKey, jsonParams, func(data []byte) {
-					var response struct {
-						Choices []struct {
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-						} `json:"choices"`
-					}
-
-					err := json.Unmarshal(data, &response)
-					if err != nil {
-						fmt.Printf("Error parsing response: %s\n", err)
-						return
-					}
+				statusf("\n🤔 AI正在思考...\n")
 
-					if len(response.Choices) > 0 {
-						content := response.Choices[0].Delta.Content
-						fullResponse.WriteString(content)
-						// 流式显示AI生成的命令
-						fmt.Print(content)
-					}
+				// 调用AI生成命令，流式显示AI生成的命令
+				printer := newReplyPrinter(os.Stdout, st.model.Name, os.Stdout, func(content string) {
+					fmt.Print(content)
 				})
-
+				ctx := interrupts.begin()
+				fullResponse, err := streamReply(ctx, st.provider, req, printer.delta, printer.reasoning)
+				interrupts.end()
+				printer.finish(fullResponse, fullResponse.Content, errors.Is(err, context.Canceled), err)
+				st.lastReasoning = fullResponse.Reasoning
+
+				if errors.Is(err, context.Canceled) {
+					statusf("❌ 已取消生成\n")
+					return
+				}
 				if err != nil {
-					fmt.Printf("❌ 错误: %s\n", err)
+					statusf("❌ 错误: %s\n", err)
 					return
 				}
 
-				// 显示生成的命令
-				generatedCmd := strings.TrimSpace(fullResponse.String())
-				fmt.Printf("\n\n💡 AI生成的命令：\n\n")
-				fmt.Printf("```bash\n%s\n```\n\n", generatedCmd)
-
-				// 检查是否是有效的命令
-				if strings.Contains(generatedCmd, "请描述您想要执行的操作") ||
-				   strings.HasPrefix(generatedCmd, "请") ||
-				   len(generatedCmd) == 0 {
-					fmt.Println("💡 这不是一个有效的命令，请重新描述您的需求。")
+				// 从回复中取出命令，格式不正确时要求模型重新回复一次
+				proposal := review.propose(st.conversation, fullResponse.Content)
+				if proposal.Command == "" {
+					printNoCommand(proposal)
 					return
 				}
+				showProposal("💡 AI生成的命令：", proposal)
 
-				// 确认执行
-				fmt.Printf("⚠️  请确认是否执行此命令？(y/N): ")
-				confirm, _ := reader.ReadString('\n')
-				confirm = strings.TrimSpace(strings.ToLower(confirm))
-
-				if confirm != "y" && confirm != "yes" {
-					fmt.Println("❌ 已取消执行")
+				// 按风险和命令策略确认执行
+				proposal, choice := review.run(proposal)
+				if choice == choiceCopy {
+					return
+				}
+				if choice != choiceRun {
+					statusf("❌ 已取消执行\n")
 					return
 				}
 
 				// 执行命令并捕获输出
-				fmt.Printf("\n🚀 正在执行命令...\n\n")
-				
-				// 根据操作系统选择合适的shell执行命令
-				var execCmd *exec.Cmd
-				if runtime.GOOS == "windows" {
-					// Windows系统使用cmd
-					execCmd = exec.Command("cmd", "/C", generatedCmd)
-				} else {
-					// Unix/Linux系统使用sh
-					execCmd = exec.Command("sh", "-c", generatedCmd)
-				}
-				
-				var out bytes.Buffer
-				var stderr bytes.Buffer
-				execCmd.Stdout = &out
-				execCmd.Stderr = &stderr
-				
-				err = execCmd.Run()
-				
-				// 获取命令输出
-				commandOutput := out.String()
-				commandError := stderr.String()
-				
-				// 显示命令输出（流式显示）
-				if commandOutput != "" {
-					fmt.Print(commandOutput)
-				}
-				if commandError != "" {
-					fmt.Print(commandError)
-				}
-				
+				statusf("\n🚀 正在执行命令...\n\n")
+
+				// 在系统 shell 中执行命令，输出实时显示，超时或 Ctrl-C 会终止命令
+				ctx = interrupts.begin()
+				_, err = executeCommand(ctx, proposal.Command, timeout)
+				interrupts.end()
+
 				if err != nil {
-					fmt.Printf("\n❌ 命令执行失败: %s\n", err)
+					statusf("\n❌ 命令执行失败: %s\n", err)
 				} else {
-					fmt.Printf("\n✅ 命令执行完成\n")
+					statusf("\n✅ 命令执行完成\n")
 				}
 				return
 			}
 
 			// 交互模式
-			fmt.Printf("\n🤖 欢迎使用AI助手！\n")
-			fmt.Printf("💡 提示：输入 'exit' 退出，输入 'help' 查看示例\n")
-			fmt.Printf("💡 支持两种模式：\n")
-			fmt.Printf("   - 普通聊天：直接输入文本进行对话\n")
-			fmt.Printf("   - 命令模式：使用 '/cmd 命令描述' 生成并执行系统命令\n")
-			fmt.Printf("💡 两种模式共享对话上下文，可以无缝切换\n\n")
-			
+			statusf("\n🤖 欢迎使用AI助手！\n")
+			statusf("💡 提示：输入 'exit' 退出，输入 'help' 查看示例\n")
+			statusf("💡 支持两种模式：\n")
+			statusf("   - 普通聊天：直接输入文本进行对话\n")
+			statusf("   - 命令模式：使用 '/cmd 命令描述' 生成并执行系统命令\n")
+			statusf("💡 两种模式共享对话上下文，可以无缝切换\n\n")
+
+			// 命令请求的描述，由 /cmd 设置
+			var commandRequest string
+			commands := newCommandRegistry()
+			commands.add(slashCommand{
+				Name: "/cmd",
+				Args: "<命令描述>",
+				Help: "生成并执行系统命令",
+				Run: func(st *replState, args string) error {
+					if args == "" {
+						return errors.New("请在 /cmd 后描述您想要执行的命令")
+					}
+					commandRequest, st.reply = args, true
+					return nil
+				},
+			})
+			commands.usage = `💡 特性：
+  - 普通聊天：直接输入文本，AI会回答您的问题
+  - 命令模式：/cmd 命令描述，AI会生成并执行系统命令
+  - 两种模式共享对话上下文，可以无缝切换，AI会记住之前的对话内容
+
+📚 命令示例：
+  /cmd 查看当前目录的文件
+  /cmd 创建一个名为test的目录
+  /cmd 查看系统信息
+  /cmd 查看端口8080是否被占用
+  /cmd 查看磁盘使用情况
+  /cmd 安装npm包
+
+📚 聊天示例：
+  你好
+  解释一下什么是Docker
+  如何学习Go语言
+`
+
 			// 交互模式循环
+			// 支持多行输入、历史记录和 Tab 补全
+			input := newInputEditor(cfg, reader, cmd.InOrStdin(), commands.names())
+
 			for {
-				fmt.Print("👤 > ")
-				text, _ := reader.ReadString('\n')
+				text, err := input.ReadLine(inputPrompt())
 				text = strings.TrimSpace(text)
-
-				if text == "exit" {
-					fmt.Println("👋 再见！")
-					return
+				// 输入结束（如管道关闭）或在空行上按 Ctrl-C 时按 exit 处理
+				if err != nil && text == "" {
+					text = "exit"
 				}
-				
-				if text == "help" {
-					fmt.Println("\n📚 使用方法：")
-					fmt.Println("  普通聊天：直接输入文本，AI会回答您的问题")
-					fmt.Println("  命令模式：/cmd 命令描述，AI会生成并执行系统命令")
-					fmt.Println()
-					fmt.Println("💡 特性：")
-					fmt.Println("  - 两种模式共享对话上下文")
-					fmt.Println("  - 可以在聊天和命令模式之间无缝切换")
-					fmt.Println("  - AI会记住之前的对话内容")
-					fmt.Println()
-					fmt.Println("📚 命令示例：")
-					fmt.Println("  /cmd 查看当前目录的文件")
-					fmt.Println("  /cmd 创建一个名为test的目录")
-					fmt.Println("  /cmd 查看系统信息")
-					fmt.Println("  /cmd 查看端口8080是否被占用")
-					fmt.Println("  /cmd 查看磁盘使用情况")
-					fmt.Println("  /cmd 安装npm包")
-					fmt.Println()
-					fmt.Println("📚 聊天示例：")
-					fmt.Println("  你好")
-					fmt.Println("  解释一下什么是Docker")
-					fmt.Println("  如何学习Go语言")
-					fmt.Println()
+				if text == "" {
+					statusf("❌ 请输入内容\n")
 					continue
 				}
-				
-				if text == "" {
-					fmt.Println("❌ 请输入内容")
+
+				commandRequest = ""
+				st.reply, st.quit = false, false
+				handled, err := commands.dispatch(st, text)
+				if err != nil {
+					statusf("❌ %s\n", err)
 					continue
 				}
-				
+				if st.quit {
+					statusf("👋 再见！\n")
+					return
+				}
+				if handled && !st.reply {
+					continue
+				}
+
 				// 检查是否是命令请求
-				isCommandRequest := strings.HasPrefix(text, "/cmd ")
-				var userRequest string
-				
+				isCommandRequest := commandRequest != ""
+				userRequest := text
 				if isCommandRequest {
-					userRequest = strings.TrimSpace(strings.TrimPrefix(text, "/cmd "))
-					if userRequest == "" {
-						fmt.Println("❌ 请在 /cmd 后描述您想要执行的命令")
-						continue
-					}
-				} else {
-					// 普通聊天，使用用户输入作为请求
-					userRequest = text
+					userRequest = commandRequest
 				}
-				
+				// 附加 /file、/image 和 @路径 提到的文件
+				userMessage := st.compose(userRequest)
+
 				// 添加用户请求到对话历史
-				conversation = append(conversation, struct {
-					Role    string `json:"role"`
-					Content string `json:"content"`
-				}{
-					Role:    "user",
-					Content: userRequest,
-				})
+				st.conversation = append(st.conversation, userMessage)
 
 				// 更新系统提示词，包含环境信息
 				if isCommandRequest {
 					// 命令模式下的系统提示词
-					conversation[0].Content = fmt.Sprintf(`你是一个专业的系统命令助手。你的任务是根据用户的需求生成合适的系统命令。
-
-环境信息：
-%s
-
-当前模型：%s
-
-重要规则：
-1. 只输出可执行的命令，不要任何解释、描述或回答
-2. 用户已经通过 /cmd 前缀明确表示需要命令，所以这个请求是命令请求
-3. 确保命令安全，避免破坏性操作
-4. 如果需要多个步骤，请使用 && 或 ; 连接
-5. 根据操作系统选择合适的命令语法（Windows使用cmd或PowerShell，macOS/Linux使用bash）
-6. 优先使用跨平台的命令
-7. 如果用户需求不明确，请询问具体细节
-
-示例：
-用户：查看当前目录的文件
-输出：ls -la
-
-用户：创建一个名为test的目录
-输出：mkdir test
-
-用户：查看系统信息
-输出：uname -a
-
-用户：查看docker容器
-输出：docker ps -a
-
-用户：查看端口8080是否被占用
-输出：lsof -i :8080
-
-项目信息：
-如果用户询问项目相关信息，请提供以下信息：
-- 项目地址：https://github.com/oAo-lab/Qwen-cli
-- 项目名称：Qwen-cli
-- 项目描述：通义千问命令行客户端，支持多模型对话和角色切换`, osInfo, currentModel)
+					st.conversation[0].Content = fmt.Sprintf("%s\n\n环境信息：\n%s\n\n当前模型：%s", commandSystemPrompt, osInfo, st.model.Name)
+				} else if st.role != "" {
+					// 通过 /prompt 选择了角色时使用角色提示词
+					st.conversation[0].Content = fmt.Sprintf("%s\n\n环境信息：\n%s", cfg.Roles[st.role], osInfo)
 				} else {
 					// 普通聊天模式下的系统提示词
-					conversation[0].Content = fmt.Sprintf(`你是一个智能助手，可以帮助用户解答问题和执行系统命令。
+					st.conversation[0].Content = fmt.Sprintf(`你是一个智能助手，可以帮助用户解答问题和执行系统命令。
 
 环境信息：
 %s
@@ -341,147 +300,100 @@ func CmdCommand(cfg config.Config) *cobra.Command {
 - 项目名称：Qwen-cli
 - 项目描述：通义千问命令行客户端，支持多模型对话和角色切换
 
-请以友好、专业的方式与用户交流。`, osInfo, currentModel)
+请以友好、专业的方式与用户交流。`, osInfo, st.model.Name)
 				}
 
 				// 准备API请求
-				params := struct {
-					Model    string `json:"model"`
-					Messages []struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					} `json:"messages"`
-					Stream bool `json:"stream"`
-				}{
-					Model:    currentModel,
-					Messages: conversation,
-					Stream:   true,
+				params := st.params()
+				req := client.ChatRequest{
+					Model:    st.model.Name,
+					Messages: fitContext(st.conversation, st.model, params),
 				}
+				applyGeneration(&req, params)
 
-				jsonParams, _ := json.Marshal(params)
-
-				fmt.Printf("\n🤔 AI正在思考...\n")
-
-				var fullResponse strings.Builder
+				statusf("\n🤔 AI正在思考...\n")
 
-				// 调用AI生成命令
-				err := client.Client(cfg.APIURL, cfg.APIKey, jsonParams, func(data []byte) {
-					var response struct {
-						Choices []struct {
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-						} `json:"choices"`
-					}
-
-					err := json.Unmarshal(data, &response)
-					if err != nil {
-						fmt.Printf("Error parsing response: %s\n", err)
-						return
-					}
-
-					if len(response.Choices) > 0 {
-						content := response.Choices[0].Delta.Content
-						fullResponse.WriteString(content)
-						// 流式显示AI响应
-						fmt.Print(content)
-					}
+				// 调用AI生成命令，流式显示AI响应
+				printer := newReplyPrinter(os.Stdout, st.model.Name, os.Stdout, func(content string) {
+					fmt.Print(content)
 				})
+				ctx := interrupts.begin()
+				fullResponse, err := streamReply(ctx, st.provider, req, printer.delta, printer.reasoning)
+				interrupts.end()
+				printer.finish(fullResponse, fullResponse.Content, errors.Is(err, context.Canceled), err)
+				st.lastReasoning = fullResponse.Reasoning
+				if fullResponse.Usage != nil {
+					st.lastUsage = fullResponse.Usage
+				}
 
+				if errors.Is(err, context.Canceled) {
+					// 保留已生成的部分回复，并标记为已中断，不执行被中断的命令
+					st.conversation = append(st.conversation, client.Message{
+						Role:    "assistant",
+						Content: fullResponse.Content + interruptedMarker,
+					})
+					continue
+				}
 				if err != nil {
-					fmt.Printf("❌ 错误: %s\n", err)
+					statusf("❌ 错误: %s\n", err)
 					continue
 				}
 
 				// 获取AI响应
-				aiResponse := strings.TrimSpace(fullResponse.String())
-				
+				aiResponse := strings.TrimSpace(fullResponse.Content)
+
 				if isCommandRequest {
-					// 命令模式处理
-					fmt.Printf("\n\n💡 AI生成的命令：\n\n")
-					fmt.Printf("```bash\n%s\n```\n\n", aiResponse)
-
-					// 检查是否是有效的命令
-					if strings.Contains(aiResponse, "请描述您想要执行的操作") ||
-					   strings.HasPrefix(aiResponse, "请") ||
-					   len(aiResponse) == 0 {
-						fmt.Println("💡 这不是一个有效的命令，请重新描述您的需求。")
+					// 命令模式处理：从回复中取出命令，格式不正确时要求模型重新回复一次
+					proposal := review.propose(st.conversation, fullResponse.Content)
+					if proposal.Command == "" {
+						printNoCommand(proposal)
 						// 添加AI响应到对话历史
-						conversation = append(conversation, struct {
-							Role    string `json:"role"`
-							Content string `json:"content"`
-						}{
+						st.conversation = append(st.conversation, client.Message{
 							Role:    "assistant",
 							Content: aiResponse,
 						})
 						continue
 					}
 
-					// 确认执行
-					fmt.Printf("⚠️  请确认是否执行此命令？(y/N): ")
-					confirm, _ := reader.ReadString('\n')
-					confirm = strings.TrimSpace(strings.ToLower(confirm))
+					showProposal("💡 AI生成的命令：", proposal)
 
-					if confirm != "y" && confirm != "yes" {
-						fmt.Println("❌ 已取消执行")
+					// 按风险和命令策略确认执行，历史中以 JSON 记录用户编辑或重新生成后的命令
+					proposal, choice := review.run(proposal)
+					if choice != choiceRun {
+						if choice != choiceCopy {
+							statusf("❌ 已取消执行\n")
+						}
 						// 添加AI响应到对话历史，即使没有执行
-						conversation = append(conversation, struct {
-							Role    string `json:"role"`
-							Content string `json:"content"`
-						}{
+						st.conversation = append(st.conversation, client.Message{
 							Role:    "assistant",
-							Content: aiResponse,
+							Content: proposal.JSON(),
 						})
 						continue
 					}
 
 					// 执行命令并捕获输出
-					fmt.Printf("\n🚀 正在执行命令...\n\n")
-					
-					// 根据操作系统选择合适的shell执行命令
-					var execCmd *exec.Cmd
-					if runtime.GOOS == "windows" {
-						// Windows系统使用cmd
-						execCmd = exec.Command("cmd", "/C", aiResponse)
-					} else {
-						// Unix/Linux系统使用sh
-						execCmd = exec.Command("sh", "-c", aiResponse)
-					}
-					
-					var out bytes.Buffer
-					var stderr bytes.Buffer
-					execCmd.Stdout = &out
-					execCmd.Stderr = &stderr
-					
-					err = execCmd.Run()
-					
-					// 获取命令输出
-					commandOutput := out.String()
-					commandError := stderr.String()
-					
-					// 显示命令输出（流式显示）
-					if commandOutput != "" {
-						fmt.Print(commandOutput)
-					}
-					if commandError != "" {
-						fmt.Print(commandError)
-					}
-					
+					statusf("\n🚀 正在执行命令...\n\n")
+
+					// 在系统 shell 中执行命令，输出实时显示，同时保存输出加入对话历史，超时或 Ctrl-C 会终止命令
+					ctx = interrupts.begin()
+					result, err := executeCommand(ctx, proposal.Command, timeout)
+					interrupts.end()
+					// 过长的输出只保留开头和结尾，避免占满上下文
+					commandOutput := limitOutput(result.Output, cfg.OutputLimit())
+					commandError := limitOutput(result.Stderr, cfg.OutputLimit())
+
 					if err != nil {
-						fmt.Printf("\n❌ 命令执行失败: %s\n", err)
+						statusf("\n❌ 命令执行失败: %s\n", err)
 					} else {
-						fmt.Printf("\n✅ 命令执行完成\n")
+						statusf("\n✅ 命令执行完成\n")
 					}
 
 					// 将命令和结果添加到对话历史中
-					conversation = append(conversation, struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					}{
+					st.conversation = append(st.conversation, client.Message{
 						Role:    "assistant",
-						Content: aiResponse,
+						Content: proposal.JSON(),
 					})
-					
+
 					// 添加命令执行结果到对话历史
 					resultText := commandOutput
 					if commandError != "" {
@@ -496,31 +408,25 @@ func CmdCommand(cfg config.Config) *cobra.Command {
 						}
 						resultText += "执行错误: " + err.Error()
 					}
-					
-					conversation = append(conversation, struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					}{
+
+					st.conversation = append(st.conversation, client.Message{
 						Role:    "user",
 						Content: "命令执行结果:\n" + resultText,
 					})
 
-					fmt.Printf("\n🔄 是否继续使用命令助手？(y/N): ")
+					statusf("\n🔄 是否继续使用命令助手？(y/N): ")
 					continueConfirm, _ := reader.ReadString('\n')
 					continueConfirm = strings.TrimSpace(strings.ToLower(continueConfirm))
-					
+
 					if continueConfirm != "y" && continueConfirm != "yes" {
 						return
 					}
 				} else {
 					// 普通聊天模式处理
-					fmt.Printf("\n") // 只添加换行，因为内容已经在流式显示中输出过了
-					
+					statusf("\n") // 只添加换行，因为内容已经在流式显示中输出过了
+
 					// 添加AI响应到对话历史
-					conversation = append(conversation, struct {
-						Role    string `json:"role"`
-						Content string `json:"content"`
-					}{
+					st.conversation = append(st.conversation, client.Message{
 						Role:    "assistant",
 						Content: aiResponse,
 					})
@@ -529,5 +435,8 @@ func CmdCommand(cfg config.Config) *cobra.Command {
 		},
 	}
 
+	generationFlags = addGenerationFlags(cmdCmd)
+	cmdCmd.Flags().DurationVar(&timeout, "timeout", cfg.CommandTimeoutDuration(), "每条命令的超时时间，如 30s、5m，0 表示不限制")
+
 	return cmdCmd
 }
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"Qwen-cli/client"
+	"Qwen-cli/config"
+	"Qwen-cli/session"
+	"Qwen-cli/tokens"
+)
+
+// compactKeepTurns 压缩时原样保留的最近对话轮数
+const compactKeepTurns = 2
+
+// errNothingToCompact 表示没有足够早的对话可以压缩
+var errNothingToCompact = errors.New("对话太短，没有可压缩的内容")
+
+const compactPrompt = `你负责压缩一段较长的对话，以便后续对话在有限的上下文中继续。
+请将下面的对话整理成简洁的摘要，要求：
+1. 保留关键事实、结论、用户的偏好和已做出的决定
+2. 原样保留重要的代码片段、命令、文件路径、配置项和报错信息
+3. 列出尚未解决的问题和接下来要做的事
+4. 使用与对话相同的语言，只输出摘要本身，不要添加开场白`
+
+// compactSession 请求模型将会话中较早的对话压缩为摘要，保留最近 compactKeepTurns 轮原文。
+// 待压缩的对话超出上下文窗口时分段压缩，每段与之前的摘要合并，直到覆盖全部早期对话。
+// 返回本次新压缩的消息数；中途失败时已完成的分段仍会保留
+func compactSession(ctx context.Context, provider client.Provider, model config.ModelConfig, params config.GenerationConfig, s *session.Session) (int, error) {
+	path := s.Path()
+	from := 0
+	if len(path) > 0 && path[0].Role == client.RoleSystem {
+		from = 1
+	}
+	previous, covered := s.Compacted()
+	if covered > from {
+		from = covered
+	}
+
+	// 从后往前找到第 compactKeepTurns 条用户消息，之前的对话都会被压缩
+	cut, turns := len(path), 0
+	for i := len(path) - 1; i >= from && turns < compactKeepTurns; i-- {
+		if path[i].Role == client.RoleUser {
+			cut, turns = i, turns+1
+		}
+	}
+	if turns < compactKeepTurns || cut <= from {
+		return 0, errNothingToCompact
+	}
+
+	budget, _ := contextBudget(model, params)
+	budget -= tokens.Count(compactPrompt) + 16
+	compacted := 0
+	for from < cut {
+		var summary string
+		if previous != nil {
+			summary = previous.Summary
+		}
+		end, err := compactChunk(ctx, provider, model, params, s, summary, path[from:cut], budget)
+		if err != nil {
+			return compacted, err
+		}
+		compacted += end
+		from += end
+		previous, _ = s.Compacted()
+	}
+	return compacted, nil
+}
+
+// compactChunk 将之前的摘要与 messages 开头能放进 budget 的部分合并为新摘要，返回本段压缩的消息数
+func compactChunk(ctx context.Context, provider client.Provider, model config.ModelConfig, params config.GenerationConfig, s *session.Session, previous string, messages []session.Message, budget int) (int, error) {
+	var parts []string
+	if previous != "" {
+		text := "之前的摘要:\n" + previous
+		parts = append(parts, text)
+		budget -= tokens.Count(text)
+	}
+	end := 0
+	for end < len(messages) {
+		cost := tokens.Count(messages[end].Content) + 4
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		end++
+	}
+	if end == 0 {
+		return 0, errors.New("单条消息超出了模型的上下文窗口，无法压缩")
+	}
+	for _, msg := range messages[:end] {
+		speaker := "用户"
+		if msg.Role == client.RoleAssistant {
+			speaker = "助手"
+		}
+		parts = append(parts, speaker+": "+msg.Content)
+	}
+
+	req := client.ChatRequest{
+		Model: model.Name,
+		Messages: []client.Message{
+			{Role: client.RoleSystem, Content: compactPrompt},
+			{Role: client.RoleUser, Content: strings.Join(parts, "\n\n")},
+		},
+	}
+	applyGeneration(&req, params)
+	// 摘要不需要思考过程，也不应受对话中 stop 序列影响
+	req.EnableThinking, req.ThinkingBudget, req.Stop = nil, nil, nil
+
+	resp, err := provider.Complete(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	summary := strings.TrimSpace(resp.Content())
+	if summary == "" {
+		return 0, errors.New("模型返回了空摘要")
+	}
+
+	s.Compact(summary, messages[end-1].ID, model.Name)
+	s.AddUsage(resp.Usage)
+	return end, nil
+}
+
+// needsCompaction 判断对话是否超过自动压缩阈值
+func needsCompaction(cfg config.Config, conversation []client.Message, model config.ModelConfig, params config.GenerationConfig) bool {
+	ratio := cfg.CompactRatio()
+	if ratio <= 0 {
+		return false
+	}
+	budget, _ := contextBudget(model, params)
+	return float64(tokens.CountMessages(conversation)) > ratio*float64(budget)
+}
+
+// reportCompaction 显示压缩结果
+func reportCompaction(compacted int, before, after []client.Message) {
+	statusf("🗜️  已将 %d 条早期消息压缩为摘要（约 %d → %d tokens），完整记录仍保存在会话文件中\n",
+		compacted, tokens.CountMessages(before), tokens.CountMessages(after))
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"Qwen-cli/client"
+	"Qwen-cli/config"
+	"Qwen-cli/session"
+	"Qwen-cli/tokens"
+)
+
+// summaryProvider 记录收到的压缩请求，并按顺序返回摘要
+type summaryProvider struct {
+	requests []string
+}
+
+func (p *summaryProvider) Endpoint() string { return "test" }
+
+func (p *summaryProvider) Stream(context.Context, client.ChatRequest) (*client.ChatStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *summaryProvider) Complete(_ context.Context, req client.ChatRequest) (*client.ChatResponse, error) {
+	p.requests = append(p.requests, req.Messages[len(req.Messages)-1].Content)
+	summary := fmt.Sprintf("摘要%d", len(p.requests))
+	return &client.ChatResponse{Choices: []client.Choice{{Message: client.Message{Role: client.RoleAssistant, Content: summary}}}}, nil
+}
+
+// compactTestModel 返回上下文窗口刚好能压缩 perChunk 条 words 个单词的消息的模型
+func compactTestModel(perChunk, words int) config.ModelConfig {
+	cost := tokens.Count(strings.Repeat("word ", words)) + 4
+	overhead := tokens.Count(compactPrompt) + 16 + tokens.Count("之前的摘要:\n摘要1")
+	// 预留 1/4 窗口给回复
+	window := (overhead + perChunk*cost) * 4 / 3
+	for {
+		budget, _ := contextBudget(config.ModelConfig{ContextWindow: window}, config.GenerationConfig{})
+		if budget >= overhead+perChunk*cost {
+			break
+		}
+		window++
+	}
+	return config.ModelConfig{Name: "test", ContextWindow: window}
+}
+
+func newCompactTestSession(turns, words int) *session.Session {
+	s := session.New("test", "", "system")
+	for i := range turns {
+		s.Append(session.Message{Role: client.RoleUser, Content: fmt.Sprintf("q%d %s", i, strings.Repeat("word ", words))})
+		s.Append(session.Message{Role: client.RoleAssistant, Content: fmt.Sprintf("a%d %s", i, strings.Repeat("word ", words))})
+	}
+	return s
+}
+
+func TestCompactSessionChunks(t *testing.T) {
+	const turns, words = 6, 30
+	s := newCompactTestSession(turns, words)
+	p := &summaryProvider{}
+	compacted, err := compactSession(context.Background(), p, compactTestModel(3, words+1), config.GenerationConfig{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 保留最近 2 轮（4 条），其余 8 条都应被压缩，每段最多 3 条
+	if want := (turns - compactKeepTurns) * 2; compacted != want {
+		t.Errorf("compacted = %d, want %d", compacted, want)
+	}
+	if len(p.requests) < 3 {
+		t.Fatalf("requests = %d, want at least 3 chunks", len(p.requests))
+	}
+	var all string
+	for i, req := range p.requests {
+		if i > 0 && !strings.Contains(req, fmt.Sprintf("摘要%d", i)) {
+			t.Errorf("request %d does not fold in the previous summary: %q", i, req)
+		}
+		all += req
+	}
+	for i := range turns - compactKeepTurns {
+		for _, prefix := range []string{"q", "a"} {
+			if !strings.Contains(all, fmt.Sprintf("%s%d ", prefix, i)) {
+				t.Errorf("message %s%d was never summarized", prefix, i)
+			}
+		}
+	}
+
+	conversation := s.Conversation()
+	if got := len(conversation); got != 2+compactKeepTurns*2 {
+		t.Errorf("conversation has %d messages, want system + summary + %d", got, compactKeepTurns*2)
+	}
+	if want := session.SummaryPrefix + fmt.Sprintf("摘要%d", len(p.requests)); conversation[1].Content != want {
+		t.Errorf("summary message = %q, want %q", conversation[1].Content, want)
+	}
+}
+
+func TestCompactSessionMessageTooLarge(t *testing.T) {
+	s := newCompactTestSession(4, 200)
+	p := &summaryProvider{}
+	compacted, err := compactSession(context.Background(), p, compactTestModel(1, 20), config.GenerationConfig{}, s)
+	if err == nil || compacted != 0 {
+		t.Fatalf("compactSession = %d, %v; want an error", compacted, err)
+	}
+	if len(p.requests) != 0 {
+		t.Errorf("sent %d requests, want none", len(p.requests))
+	}
+	if c, _ := s.Compacted(); c != nil {
+		t.Errorf("session recorded a compaction: %+v", c)
+	}
+}
+
+func TestCompactSessionTooShort(t *testing.T) {
+	s := newCompactTestSession(compactKeepTurns, 5)
+	if _, err := compactSession(context.Background(), &summaryProvider{}, config.ModelConfig{}, config.GenerationConfig{}, s); !errors.Is(err, errNothingToCompact) {
+		t.Errorf("err = %v, want errNothingToCompact", err)
+	}
+}
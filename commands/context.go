@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+
+	"Qwen-cli/client"
+	"Qwen-cli/config"
+	"Qwen-cli/tokens"
+)
+
+// maxReplyReserve 未设置 max_tokens 时为回复预留的 token 上限
+const maxReplyReserve = 4096
+
+// contextBudget 返回可用于发送历史消息的 token 数：上下文窗口减去为回复预留的部分
+func contextBudget(model config.ModelConfig, params config.GenerationConfig) (budget, reserve int) {
+	window := model.Window()
+	reserve = min(window/4, maxReplyReserve)
+	if params.MaxTokens != nil && *params.MaxTokens > 0 {
+		reserve = min(*params.MaxTokens, window/2)
+	}
+	return window - reserve, reserve
+}
+
+// fitContext 裁剪将要发送的对话使其不超出模型的上下文窗口，原切片保持不变
+func fitContext(messages []client.Message, model config.ModelConfig, params config.GenerationConfig) []client.Message {
+	if !model.Vision {
+		messages = withoutImages(messages)
+	}
+	budget, _ := contextBudget(model, params)
+	trimmed, dropped := tokens.Trim(messages, budget)
+	if dropped > 0 {
+		statusf("✂️  对话超出上下文窗口，本轮省略了最早的 %d 条消息\n", dropped)
+	}
+	return trimmed
+}
+
+// withoutImages 去掉对话中的图片，用于切换到不支持图片的模型之后
+func withoutImages(messages []client.Message) []client.Message {
+	stripped := make([]client.Message, len(messages))
+	for i, msg := range messages {
+		msg.Images = nil
+		stripped[i] = msg
+	}
+	return stripped
+}
+
+// printTokenUsage 显示当前对话的 token 估算和上下文窗口占用，last 为上一轮接口返回的实际用量
+func printTokenUsage(messages []client.Message, model config.ModelConfig, params config.GenerationConfig, last *client.Usage) {
+	budget, reserve := contextBudget(model, params)
+	total := tokens.CountMessages(messages)
+	system := 0
+	if len(messages) > 0 && messages[0].Role == client.RoleSystem {
+		system = tokens.CountMessage(messages[0])
+	}
+
+	fmt.Println("📊 上下文用量（估算）：")
+	fmt.Printf("  消息: %d 条，约 %d tokens（系统提示词 %d）\n", len(messages), total, system)
+	fmt.Printf("  上下文窗口: %d（为回复预留 %d，可用 %d）\n", model.Window(), reserve, budget)
+	fmt.Printf("  已使用: %.1f%%\n", float64(total)*100/float64(budget))
+	if last != nil {
+		fmt.Printf("  上一轮实际用量: 输入 %d / 输出 %d tokens\n", last.PromptTokens, last.CompletionTokens)
+	}
+	if total > budget {
+		_, dropped := tokens.Trim(messages, budget)
+		fmt.Printf("⚠️  已超出可用窗口，下一轮将省略最早的 %d 条消息\n", dropped)
+	}
+}
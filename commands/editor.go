@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// editorCommand 返回用户配置的编辑器，依次读取 VISUAL、EDITOR，都未设置时使用系统默认编辑器
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// editText 在编辑器中打开 initial，返回编辑后的文本（去除首尾空白）。
+// pattern 是临时文件名的模式，扩展名决定编辑器的语法高亮
+func editText(initial, pattern string) (string, error) {
+	tmp, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(initial); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	tmp.Close()
+
+	// 编辑器命令可能带参数，如 "code --wait"
+	fields := strings.Fields(editorCommand())
+	editor := exec.Command(fields[0], append(fields[1:], tmp.Name())...)
+	editor.Stdin = os.Stdin
+	editor.Stdout = os.Stdout
+	editor.Stderr = os.Stderr
+	if err := editor.Run(); err != nil {
+		return "", fmt.Errorf("编辑器 %s 运行失败: %w", fields[0], err)
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read temp file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
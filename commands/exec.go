@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"Qwen-cli/shell"
+)
+
+// executeCommand 执行生成的命令并实时显示输出。标准输入和输出都是终端时在伪终端中运行，
+// 交互式命令可以直接读取键盘输入；机器可读模式下不显示，输出包含在执行结果中。
+// timeout 不为 0 时命令超时后被终止
+func executeCommand(ctx context.Context, command string, timeout time.Duration) (shell.Result, error) {
+	opts := shell.Options{Timeout: timeout}
+	if !machineOutput() {
+		opts.Stdout, opts.Stderr = os.Stdout, os.Stderr
+		opts.Terminal = isTerminal(os.Stdin) && isTerminal(os.Stdout)
+	}
+	// 标准输入是管道时由 REPL 读取，不交给命令
+	if isTerminal(os.Stdin) {
+		opts.Stdin = os.Stdin
+	}
+	result, err := shell.Run(ctx, command, opts)
+	printExecResult(command, result.Output, result.Stderr, err, result.Duration)
+	return result, err
+}
+
+// limitOutput 将超过 limit 字节的命令输出截断后再加入对话上下文，并提示省略的字节数
+func limitOutput(output string, limit int) string {
+	truncated := shell.Truncate(output, limit)
+	if len(truncated) < len(output) {
+		statusf("\n✂️  输出共 %d 字节，加入对话上下文时只保留开头和结尾\n", len(output))
+	}
+	return truncated
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"Qwen-cli/client"
+	"Qwen-cli/config"
+)
+
+// addGenerationFlags 为命令添加生成参数标志，返回的函数读取用户显式设置的标志。
+// 标志的取值与 /set 一样由 GenerationConfig.Set 校验，超出范围时命令行解析失败
+func addGenerationFlags(cmd *cobra.Command) func() config.GenerationConfig {
+	var g config.GenerationConfig
+
+	flags := cmd.Flags()
+	add := func(name, key, typ, usage string) *pflag.Flag {
+		flags.Var(&generationFlag{g: &g, key: key, typ: typ}, name, usage)
+		return flags.Lookup(name)
+	}
+	add("temperature", "temperature", "float64", "采样温度 (0~2)")
+	add("top-p", "top_p", "float64", "核采样概率阈值 (0~1)")
+	add("max-tokens", "max_tokens", "int", "单次回复的最大 token 数")
+	add("seed", "seed", "int", "随机种子")
+	add("stop", "stop", "strings", "停止词，可重复或以逗号分隔")
+	add("presence-penalty", "presence_penalty", "float64", "话题重复惩罚 (-2~2)")
+	add("enable-thinking", "enable_thinking", "bool", "开启 Qwen3 思考模式").NoOptDefVal = "true"
+	add("thinking-budget", "thinking_budget", "int", "思考过程的最大 token 数")
+
+	return func() config.GenerationConfig {
+		return g
+	}
+}
+
+// generationFlag 是一个生成参数标志，解析时写入 GenerationConfig 的对应字段
+type generationFlag struct {
+	g   *config.GenerationConfig
+	key string // GenerationConfig.Set 使用的参数名
+	typ string
+}
+
+func (f *generationFlag) Set(value string) error {
+	// --stop 可以重复，多次出现时追加
+	if f.key == "stop" && f.g.Stop != nil {
+		value = strings.Join(f.g.Stop, ",") + "," + value
+	}
+	return f.g.Set(f.key, value)
+}
+
+func (f *generationFlag) String() string {
+	return f.g.Values()[f.key]
+}
+
+func (f *generationFlag) Type() string {
+	return f.typ
+}
+
+// applyGeneration 将生成参数写入请求
+func applyGeneration(req *client.ChatRequest, g config.GenerationConfig) {
+	req.Temperature = g.Temperature
+	req.TopP = g.TopP
+	if g.MaxTokens != nil {
+		req.MaxTokens = *g.MaxTokens
+	}
+	req.Seed = g.Seed
+	req.Stop = g.Stop
+	req.PresencePenalty = g.PresencePenalty
+	req.EnableThinking = g.EnableThinking
+	req.ThinkingBudget = g.ThinkingBudget
+}
+
+// handleSetCommand 处理 /set 命令：
+//
+//	/set                  显示当前生效的参数
+//	/set <参数> <值>       设置会话参数
+//	/set <参数>            清除会话参数，恢复模型配置或命令行标志的值
+func handleSetCommand(text string, overrides *config.GenerationConfig, effective config.GenerationConfig) {
+	fields := strings.Fields(strings.TrimPrefix(text, "/set"))
+	switch len(fields) {
+	case 0:
+		fmt.Printf("⚙️  当前参数: %s\n", effective)
+		fmt.Printf("💡 用法: /set <参数> <值>，/set <参数> 恢复默认。可用参数: %s\n", strings.Join(config.GenerationKeys, ", "))
+	case 1:
+		if err := overrides.Unset(fields[0]); err != nil {
+			fmt.Printf("❌ %s\n", err)
+			return
+		}
+		fmt.Printf("⚙️  已恢复 %s 的默认值\n", fields[0])
+	default:
+		value := strings.Join(fields[1:], " ")
+		if err := overrides.Set(fields[0], value); err != nil {
+			fmt.Printf("❌ %s\n", err)
+			return
+		}
+		fmt.Printf("⚙️  已设置 %s = %s\n", fields[0], value)
+	}
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"Qwen-cli/attach"
+	"Qwen-cli/config"
+)
+
+// loadImages 读取图片，模型不支持图片输入时直接报错，避免请求发出后才失败
+func loadImages(model config.ModelConfig, paths []string) ([]string, error) {
+	if !model.Vision {
+		return nil, fmt.Errorf("模型 %s 不支持图片输入，请换用支持图片的模型（如 qwen-vl-plus），并在配置中为它设置 \"vision\": true", model.Name)
+	}
+	images := make([]string, 0, len(paths))
+	for _, p := range paths {
+		image, err := attach.Image(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// runImageCommand 处理 /image，附加的图片随下一条消息发送；不带参数时显示待发送的图片数
+func runImageCommand(st *replState, args string) error {
+	if args == "" {
+		statusf("🖼️  待发送的图片: %d 张，用法：/image <路径...>\n", len(st.images))
+		return nil
+	}
+	images, err := loadImages(st.model, strings.Fields(args))
+	if err != nil {
+		return err
+	}
+	st.images = append(st.images, images...)
+	statusf("🖼️  %d 张图片将随下一条消息发送\n", len(st.images))
+	return nil
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"Qwen-cli/config"
+	"Qwen-cli/lineedit"
+)
+
+// inputPrompt 是 REPL 的输入提示符，机器可读模式下不显示
+func inputPrompt() string {
+	if machineOutput() {
+		return ""
+	}
+	return "👤 > "
+}
+
+// newInputEditor 创建 REPL 使用的行编辑器，历史记录保存在配置目录的 history 文件中。
+// commands 是可补全的命令，"/model "、"/retry --model " 后补全模型，"/prompt " 后补全角色，
+// "/think " 后补全 on、off、show
+func newInputEditor(cfg config.Config, reader *bufio.Reader, in io.Reader, commands []string) *lineedit.Editor {
+	editor := lineedit.New(reader, in, filepath.Join(config.GetConfigDir(), "history"))
+	editor.Complete = func(line string) []string {
+		switch {
+		case strings.HasPrefix(line, "/model "):
+			return completeArgument("/model ", modelNames(cfg), line)
+		case strings.HasPrefix(line, "/retry --model "):
+			return completeArgument("/retry --model ", modelNames(cfg), line)
+		case strings.HasPrefix(line, "/prompt "):
+			roles := make([]string, 0, len(cfg.Roles))
+			for role := range cfg.Roles {
+				roles = append(roles, role)
+			}
+			sort.Strings(roles)
+			return completeArgument("/prompt ", roles, line)
+		case strings.HasPrefix(line, "/think "):
+			return completeArgument("/think ", []string{"on", "off", "show"}, line)
+		case strings.ContainsAny(line, " \n"):
+			return nil
+		}
+		return completeArgument("", commands, line)
+	}
+	return editor
+}
+
+// completeArgument 返回 prefix 之后以已输入内容开头的候选，每个候选都是完整的一行
+func completeArgument(prefix string, values []string, line string) []string {
+	typed := strings.TrimPrefix(line, prefix)
+	var candidates []string
+	for _, value := range values {
+		if strings.HasPrefix(value, typed) {
+			candidates = append(candidates, prefix+value)
+		}
+	}
+	return candidates
+}
+
+// modelNames 返回配置中的模型键和模型名称，去除重复
+func modelNames(cfg config.Config) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, key := range sortedModelKeys(cfg.Models) {
+		for _, name := range []string{key, cfg.Models[key].Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// interruptedMarker 追加在被中断的回复末尾，保留在对话历史中
+const interruptedMarker = "\n\n[回复已中断]"
+
+// interrupter 处理 REPL 中的 Ctrl-C：
+// 生成或执行过程中第一次 Ctrl-C 只取消当前轮次，空闲时 Ctrl-C 调用 onExit 后退出程序。
+// onExit 在信号 goroutine 中运行，此时主循环可能正在修改会话，onExit 不能读写 REPL 的状态
+type interrupter struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	onExit func()
+	sigs   chan os.Signal
+}
+
+func newInterrupter(onExit func()) *interrupter {
+	in := &interrupter{
+		onExit: onExit,
+		sigs:   make(chan os.Signal, 1),
+	}
+	signal.Notify(in.sigs, os.Interrupt)
+	go in.loop()
+	return in
+}
+
+func (in *interrupter) loop() {
+	for range in.sigs {
+		in.mu.Lock()
+		cancel := in.cancel
+		in.cancel = nil
+		in.mu.Unlock()
+
+		if cancel != nil {
+			statusf("\n⏸️  已中断，再次按 Ctrl-C 退出\n")
+			cancel()
+			continue
+		}
+
+		statusf("\n")
+		if in.onExit != nil {
+			in.onExit()
+		}
+		os.Exit(130)
+	}
+}
+
+// begin 开始一个可被 Ctrl-C 取消的轮次，结束后必须调用 end
+func (in *interrupter) begin() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	in.mu.Lock()
+	in.cancel = cancel
+	in.mu.Unlock()
+	return ctx
+}
+
+// end 结束当前轮次，之后的 Ctrl-C 将退出程序
+func (in *interrupter) end() {
+	in.mu.Lock()
+	cancel := in.cancel
+	in.cancel = nil
+	in.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// stop 恢复默认的信号处理
+func (in *interrupter) stop() {
+	signal.Stop(in.sigs)
+	close(in.sigs)
+}
@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"Qwen-cli/client"
+	"Qwen-cli/config"
+)
+
+// 单次问答模式的退出码
+const (
+	exitError       = 1   // 请求失败
+	exitUsage       = 2   // 参数或输入错误
+	exitInterrupted = 130 // 被 Ctrl-C 中断
+)
+
+// UsageError 表示命令行参数或标志错误，进程以 exitUsage 退出
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// ExitCode 返回命令执行失败时的退出码：参数和标志错误为 2，其余为 1
+func ExitCode(err error) int {
+	var usage *UsageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+	return exitError
+}
+
+// MarkUsageErrors 将 cmd 及其所有子命令的标志解析错误和参数校验错误包装为 UsageError，
+// 在添加完所有子命令之后调用
+func MarkUsageErrors(cmd *cobra.Command) {
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return &UsageError{Err: err}
+	})
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(c *cobra.Command, args []string) error {
+			if err := validate(c, args); err != nil {
+				return &UsageError{Err: err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		MarkUsageErrors(sub)
+	}
+}
+
+// SetupOneShot 让根命令支持单次问答：
+//
+//	ask "解释一下这段代码"
+//	git diff | ask "写一条提交信息"
+//	ask -f prompt.txt
+//
+// 回答以纯文本流式写到标准输出，错误信息写到标准错误，便于在脚本和管道中使用
+func SetupOneShot(rootCmd *cobra.Command, cfg config.Config) {
+	var promptFile, modelName, roleName string
+	var imagePaths []string
+	var enableSearch bool
+
+	rootCmd.Use = "ask [问题]"
+	rootCmd.Args = oneShotArgs
+	rootCmd.Long += `
+
+直接提问时以单次问答模式运行，回答输出到标准输出：
+	 ask "解释一下什么是 Docker"
+	 git diff | ask "写一条提交信息"    # 管道输入作为上下文
+	 ask -f prompt.txt                 # 从文件读取问题
+	 ask -i screenshot.png "哪里出错了"  # 附加图片（需要支持图片的模型）
+	 ask -- chta 是什么                # 问题以接近子命令名的单词开头时用 -- 分隔
+
+退出码：0 成功，1 请求失败，2 参数错误，130 被 Ctrl-C 中断`
+
+	generationFlags := addGenerationFlags(rootCmd)
+	rootCmd.Flags().StringVarP(&promptFile, "file", "f", "", "从文件读取问题")
+	rootCmd.Flags().StringVarP(&modelName, "model", "m", "", "使用的模型（配置中的键或模型名称）")
+	rootCmd.Flags().StringVarP(&roleName, "role", "r", "default", "使用的角色提示词")
+	rootCmd.Flags().StringArrayVarP(&imagePaths, "image", "i", nil, "附加图片，可重复指定（需要模型配置 vision: true）")
+	rootCmd.Flags().BoolVar(&enableSearch, "online", false, "开启联网搜索")
+
+	rootCmd.Run = func(cmd *cobra.Command, args []string) {
+		stderr := cmd.ErrOrStderr()
+		fail := func(code int, format string, a ...any) {
+			fmt.Fprintf(stderr, "ask: "+format+"\n", a...)
+			os.Exit(code)
+		}
+
+		prompt := strings.Join(args, " ")
+		if promptFile != "" {
+			data, err := os.ReadFile(promptFile)
+			if err != nil {
+				fail(exitUsage, "无法读取问题文件: %s", err)
+			}
+			prompt = joinNonEmpty(prompt, strings.TrimSpace(string(data)))
+		}
+
+		// 管道或重定向的文件输入作为问题的上下文
+		if hasPipedInput(cmd.InOrStdin()) {
+			data, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				fail(exitUsage, "无法读取标准输入: %s", err)
+			}
+			prompt = joinNonEmpty(prompt, strings.TrimRight(string(data), "\n"))
+		}
+
+		if strings.TrimSpace(prompt) == "" && len(imagePaths) == 0 {
+			if len(args) == 0 && promptFile == "" {
+				cmd.Help()
+				return
+			}
+			fail(exitUsage, "问题为空")
+		}
+
+		model, ok := lookupModel(cfg, modelName)
+		if !ok {
+			fail(exitUsage, "未找到模型: %s", modelName)
+		}
+		system, ok := cfg.Roles[roleName]
+		if !ok && roleName != "default" {
+			fail(exitUsage, "未找到角色: %s", roleName)
+		}
+
+		var images []string
+		if len(imagePaths) > 0 {
+			var err error
+			if images, err = loadImages(model, imagePaths); err != nil {
+				fail(exitUsage, "%s", err)
+			}
+		}
+
+		provider, err := newProvider(cfg, model)
+		if err != nil {
+			fail(exitError, "无法创建模型客户端: %s", err)
+		}
+
+		var conversation []client.Message
+		if system != "" {
+			conversation = append(conversation, client.Message{Role: client.RoleSystem, Content: system})
+		}
+		conversation = append(conversation, client.Message{Role: client.RoleUser, Content: prompt, Images: images})
+
+		params := model.GenerationConfig.Merge(generationFlags())
+		req := client.ChatRequest{
+			Model:        model.Name,
+			Messages:     fitContext(conversation, model, params),
+			EnableSearch: enableSearch,
+		}
+		applyGeneration(&req, params)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		out := cmd.OutOrStdout()
+		// 思考过程只在标准错误是终端时显示，不混入标准输出的结果
+		var thinking *os.File
+		if isTerminal(os.Stderr) {
+			thinking = os.Stderr
+		}
+		printer := newReplyPrinter(out, model.Name, thinking, func(content string) {
+			fmt.Fprint(out, content)
+		})
+		result, err := streamReply(ctx, provider, req, printer.delta, printer.reasoning)
+		interrupted := errors.Is(err, context.Canceled)
+		printer.finish(result, result.Content, interrupted, err)
+		if !machineOutput() && result.Content != "" && !strings.HasSuffix(result.Content, "\n") {
+			fmt.Fprintln(out)
+		}
+
+		if interrupted {
+			stop()
+			os.Exit(exitInterrupted)
+		}
+		if err != nil {
+			fail(exitError, "%s", err)
+		}
+		if result.FinishReason == client.FinishReasonLength {
+			fmt.Fprintln(stderr, "ask: 回复达到 max_tokens 上限，已被截断")
+		}
+	}
+}
+
+// hasPipedInput 判断输入是否来自管道或重定向的文件，终端和 /dev/null 等设备不读取
+func hasPipedInput(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
+
+// lookupModel 按配置键或模型名称查找模型，name 为空时返回默认模型
+func lookupModel(cfg config.Config, name string) (config.ModelConfig, bool) {
+	if name == "" {
+		return cfg.Models["default"], true
+	}
+	if model, ok := cfg.Models[name]; ok {
+		return model, true
+	}
+	for _, key := range sortedModelKeys(cfg.Models) {
+		if cfg.Models[key].Name == name {
+			return cfg.Models[key], true
+		}
+	}
+	return config.ModelConfig{}, false
+}
+
+// oneShotArgs 校验单次问答的参数。第一个参数是与子命令相近的单词（如 sesions）时
+// 按拼错的子命令报错，避免把它作为问题发给模型；-- 之后的参数总是作为问题
+func oneShotArgs(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 || cmd.ArgsLenAtDash() == 0 || strings.ContainsAny(args[0], " \t\n") {
+		return nil
+	}
+	if cmd.SuggestionsMinimumDistance <= 0 {
+		cmd.SuggestionsMinimumDistance = 2 // 与 cobra 提示未知子命令时的默认值一致
+	}
+	suggestions := cmd.SuggestionsFor(args[0])
+	if len(suggestions) == 0 {
+		return nil
+	}
+	return fmt.Errorf("unknown command %q for %q\n\nDid you mean this?\n\t%s\n\n如果是要提问，请用 -- 分隔：%s -- %s",
+		args[0], cmd.CommandPath(), strings.Join(suggestions, "\n\t"), cmd.CommandPath(), strings.Join(args, " "))
+}
+
+// joinNonEmpty 用空行连接非空的文本片段
+func joinNonEmpty(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newExitCodeTestRoot 创建带有单次问答参数校验、子命令和 --seed 标志的根命令
+func newExitCodeTestRoot() *cobra.Command {
+	root := &cobra.Command{Use: "ask", Args: oneShotArgs, Run: func(*cobra.Command, []string) {}}
+	root.Flags().Int("seed", 0, "")
+	root.AddCommand(&cobra.Command{Use: "sessions", Args: cobra.NoArgs, Run: func(*cobra.Command, []string) {}})
+	root.AddCommand(&cobra.Command{Use: "fail", RunE: func(*cobra.Command, []string) error {
+		return errors.New("request failed")
+	}})
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	MarkUsageErrors(root)
+	return root
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"what", "is", "docker"}, 0},
+		{[]string{"sesions"}, exitUsage},
+		{[]string{"--", "sesions"}, 0},
+		{[]string{"sesions 是什么"}, 0},
+		{[]string{"--seed", "abc", "hi"}, exitUsage},
+		{[]string{"--nope", "hi"}, exitUsage},
+		{[]string{"sessions", "extra"}, exitUsage},
+		{[]string{"sessions", "--seed", "1"}, exitUsage},
+		{[]string{"fail"}, exitError},
+	}
+	for _, tt := range tests {
+		root := newExitCodeTestRoot()
+		root.SetArgs(tt.args)
+		err := root.Execute()
+		got := 0
+		if err != nil {
+			got = ExitCode(err)
+		}
+		if got != tt.want {
+			t.Errorf("ask %q: exit code %d (%v), want %d", tt.args, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"Qwen-cli/client"
+)
+
+// 输出格式
+const (
+	outputText  = "text"  // 面向人阅读的文本
+	outputJSON  = "json"  // 每个结果一个 JSON 对象
+	outputJSONL = "jsonl" // 流式增量，每行一个 JSON 对象
+)
+
+// outputFormat 是全局 --output 标志的值
+var outputFormat = outputText
+
+// AddOutputFlag 为根命令添加全局 --output 标志
+func AddOutputFlag(rootCmd *cobra.Command) {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "输出格式: text、json 或 jsonl")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		switch outputFormat {
+		case outputText, outputJSON, outputJSONL:
+			return nil
+		}
+		return &UsageError{Err: fmt.Errorf("无效的输出格式 %q，可选 text、json、jsonl", outputFormat)}
+	}
+}
+
+// machineOutput 判断是否输出机器可读格式
+func machineOutput() bool {
+	return outputFormat != outputText
+}
+
+// statusf 输出提示信息：text 模式写到标准输出，机器可读模式写到标准错误，避免混入结果
+func statusf(format string, a ...any) {
+	w := io.Writer(os.Stdout)
+	if machineOutput() {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format, a...)
+}
+
+// printJSON 将 v 编码为单行 JSON 写入 w
+func printJSON(w io.Writer, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ask: 无法编码输出: %s\n", err)
+		return
+	}
+	fmt.Fprintf(w, "%s\n", data)
+}
+
+// deltaEvent 是 jsonl 模式下的一段流式增量
+type deltaEvent struct {
+	Type    string `json:"type"` // delta，思考过程为 reasoning
+	Content string `json:"content"`
+}
+
+// replyEvent 是 json/jsonl 模式下的一条完整回复
+type replyEvent struct {
+	Type         string              `json:"type"` // reply
+	Content      string              `json:"content"`
+	Reasoning    string              `json:"reasoning,omitempty"`
+	Model        string              `json:"model,omitempty"`
+	FinishReason client.FinishReason `json:"finish_reason,omitempty"`
+	Usage        *client.Usage       `json:"usage,omitempty"`
+	LatencyMs    int64               `json:"latency_ms"`
+	Interrupted  bool                `json:"interrupted,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+// replyPrinter 按输出格式显示一次流式回复：text 模式交给 onText 显示，思考过程显示在
+// thinking 中，jsonl 模式逐个输出增量，json 和 jsonl 模式在结束时输出完整回复
+type replyPrinter struct {
+	w        io.Writer
+	model    string
+	start    time.Time
+	onText   func(content string)
+	thinking *thinkingView // 为 nil 时不显示思考过程
+}
+
+// newReplyPrinter 创建 replyPrinter，thinking 为显示思考过程的终端，为 nil 时不显示
+func newReplyPrinter(w io.Writer, model string, thinking *os.File, onText func(content string)) *replyPrinter {
+	p := &replyPrinter{w: w, model: model, start: time.Now(), onText: onText}
+	if thinking != nil {
+		p.thinking = newThinkingView(thinking)
+	}
+	return p
+}
+
+// reasoning 显示一段思考过程
+func (p *replyPrinter) reasoning(text string) {
+	switch outputFormat {
+	case outputText:
+		if p.thinking != nil {
+			p.thinking.write(text)
+		}
+	case outputJSONL:
+		printJSON(p.w, deltaEvent{Type: "reasoning", Content: text})
+	}
+}
+
+// delta 显示一段增量内容
+func (p *replyPrinter) delta(content string) {
+	switch outputFormat {
+	case outputText:
+		if p.thinking != nil {
+			p.thinking.close()
+		}
+		p.onText(content)
+	case outputJSONL:
+		printJSON(p.w, deltaEvent{Type: "delta", Content: content})
+	}
+}
+
+// finish 在机器可读模式下输出完整回复，content 为最终写入历史的内容
+func (p *replyPrinter) finish(result reply, content string, interrupted bool, err error) {
+	if p.thinking != nil {
+		p.thinking.close()
+	}
+	if !machineOutput() {
+		return
+	}
+	event := replyEvent{
+		Type:         "reply",
+		Content:      content,
+		Reasoning:    result.Reasoning,
+		Model:        p.model,
+		FinishReason: result.FinishReason,
+		Usage:        result.Usage,
+		LatencyMs:    time.Since(p.start).Milliseconds(),
+		Interrupted:  interrupted,
+	}
+	if err != nil && !interrupted {
+		event.Error = err.Error()
+	}
+	printJSON(p.w, event)
+}
+
+// execEvent 是 json/jsonl 模式下的一次命令执行结果
+type execEvent struct {
+	Type       string `json:"type"` // exec
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exit_code"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// printExecResult 在机器可读模式下输出命令执行结果
+func printExecResult(command, stdout, stderr string, err error, elapsed time.Duration) {
+	if !machineOutput() {
+		return
+	}
+	event := execEvent{
+		Type:       "exec",
+		Command:    command,
+		Stdout:     stdout,
+		Stderr:     stderr,
+		DurationMs: elapsed.Milliseconds(),
+	}
+	if err != nil {
+		event.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			event.ExitCode = exitErr.ExitCode()
+		}
+		event.Error = err.Error()
+	}
+	printJSON(os.Stdout, event)
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"Qwen-cli/config"
+	"Qwen-cli/safety"
+)
+
+// 终端颜色控制序列
+const (
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// commandPolicy 根据配置生成命令检查策略
+func commandPolicy(cfg config.Config) (safety.Policy, error) {
+	policy := safety.DefaultPolicy()
+	p := cfg.CommandPolicy
+	if p == nil {
+		return policy, nil
+	}
+	policy.Allow, policy.Confirm, policy.Deny = p.Allow, p.Confirm, p.Deny
+	for name, value := range p.Risk {
+		level, err := safety.ParseLevel(name)
+		if err != nil {
+			return policy, err
+		}
+		action, err := safety.ParseAction(value)
+		if err != nil {
+			return policy, fmt.Errorf("risk.%s: %w", name, err)
+		}
+		policy.Levels[level] = action
+	}
+	return policy, nil
+}
+
+// printRisk 显示命令的风险和原因，高风险用红色、中风险用黄色标出
+func printRisk(a safety.Analysis) {
+	if a.Level == safety.Low {
+		return
+	}
+	title, color := "⚠️  中风险命令：", colorYellow
+	if a.Level == safety.High {
+		title, color = "🛑 高风险命令：", colorRed
+	}
+	if machineOutput() || !isTerminal(os.Stdout) {
+		color = ""
+	}
+
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	for _, level := range []safety.Level{safety.High, safety.Medium} {
+		for _, f := range a.Findings {
+			if f.Level == level {
+				b.WriteString("  - " + f.Reason + "\n")
+			}
+		}
+	}
+	if color != "" {
+		statusf("%s%s%s", color, b.String(), colorReset)
+		return
+	}
+	statusf("%s", b.String())
+}
+
+// levelNames 是风险等级的中文名称
+var levelNames = map[safety.Level]string{
+	safety.Low:    "低风险",
+	safety.Medium: "中风险",
+	safety.High:   "高风险",
+}
+
+// confirmChoice 是用户在确认提示中的选择
+type confirmChoice int
+
+const (
+	choiceCancel     confirmChoice = iota // 不执行
+	choiceRun                             // 执行
+	choiceEdit                            // e：编辑后再确认
+	choiceExplain                         // x：解释命令，不执行
+	choiceCopy                            // c：放到 shell 的下一个提示符，不执行
+	choiceRegenerate                      // r：重新生成
+)
+
+// choiceKeys 是除执行和取消之外的选项
+var choiceKeys = map[string]confirmChoice{
+	"e": choiceEdit,
+	"x": choiceExplain,
+	"c": choiceCopy,
+	"r": choiceRegenerate,
+}
+
+// choiceHint 是确认提示中列出的其他选项
+const choiceHint = "e 编辑 / x 解释 / c 复制到提示符 / r 重新生成"
+
+// confirmCommand 分析命令的风险，按策略询问用户，返回用户的选择。
+// declared 是模型自己评估的风险等级，高于静态分析的结果时以它为准。
+// 同一条命令再次询问时 quiet 为 true，不再重复显示风险和策略说明
+func confirmCommand(policy safety.Policy, reader *bufio.Reader, command string, declared safety.Level, quiet bool) confirmChoice {
+	analysis := safety.Analyze(command)
+	if declared > analysis.Level {
+		analysis.Level = declared
+		analysis.Findings = append(analysis.Findings, safety.Finding{
+			Level:  declared,
+			Reason: "模型评估此命令为" + levelNames[declared],
+		})
+	}
+	if !quiet {
+		printRisk(analysis)
+	}
+
+	decision := policy.Decide(analysis)
+	switch decision.Action {
+	case safety.Deny:
+		statusf("🚫 命令策略禁止执行此命令（%s）\n", decision.Reason)
+		return choiceCancel
+	case safety.Allow:
+		statusf("✅ 命令策略允许直接执行（%s）\n", decision.Reason)
+		return choiceRun
+	case safety.Confirm:
+		statusf("⚠️  此命令需要确认（%s），输入 yes 执行，或 %s: ", decision.Reason, choiceHint)
+		confirm, _ := reader.ReadString('\n')
+		confirm = strings.TrimSpace(confirm)
+		if confirm == "yes" {
+			return choiceRun
+		}
+		return choiceKeys[strings.ToLower(confirm)]
+	}
+
+	statusf("⚠️  请确认是否执行此命令？(y/N，%s): ", choiceHint)
+	confirm, _ := reader.ReadString('\n')
+	confirm = strings.TrimSpace(strings.ToLower(confirm))
+	if confirm == "y" || confirm == "yes" {
+		return choiceRun
+	}
+	return choiceKeys[confirm]
+}
@@ -0,0 +1,236 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"Qwen-cli/client"
+	"Qwen-cli/lineedit"
+	"Qwen-cli/safety"
+	"Qwen-cli/shell"
+	"Qwen-cli/utils"
+)
+
+// explainPrompt 是解释命令时使用的系统提示词，%s 为环境信息
+const explainPrompt = `你是一个熟悉各种 shell 和命令行工具的专家。用户会给出一条命令，请逐项解释：
+1. 每个命令的作用
+2. 每个参数和选项的含义
+3. 管道、重定向以及 &&、||、; 等连接符的作用
+4. 执行后的效果和可能的风险
+
+不要改写或执行命令，用简洁的中文列表回答。
+
+环境信息：
+%s`
+
+// regeneratePrompt 是要求模型换一种方式生成命令时追加的用户消息
+const regeneratePrompt = "请换一种方式实现同样的需求，给出一条与上面不同的命令，仍然只回复 JSON 对象"
+
+// formatRetryPrompt 是回复不符合约定格式时要求模型重新回复的用户消息，%v 为格式错误
+const formatRetryPrompt = `你的回复格式不正确（%v）。请只回复一个 JSON 对象，不要使用代码块或添加其他文字：
+{"command": "要执行的命令", "explanation": "命令的作用", "risk": "low、medium 或 high"}`
+
+// commandReview 在执行生成的命令之前与用户确认，确认时可以编辑、解释、
+// 复制到 shell 的提示符或重新生成命令
+type commandReview struct {
+	st         *replState
+	policy     safety.Policy
+	interrupts *interrupter
+}
+
+// run 反复询问直到用户决定执行或放弃，返回最终的命令和用户的选择：
+// choiceRun（执行）、choiceCopy（已复制到 shell 提示符）或 choiceCancel（取消）
+func (r *commandReview) run(p shell.Proposal) (shell.Proposal, confirmChoice) {
+	quiet := false
+	for {
+		choice := confirmCommand(r.policy, r.st.reader, p.Command, declaredRisk(p), quiet)
+		quiet = true
+		switch choice {
+		case choiceEdit:
+			edited, err := editCommand(r.st.reader, p.Command)
+			if err != nil {
+				statusf("❌ %s\n", err)
+				continue
+			}
+			if edited == "" || edited == p.Command {
+				statusf("💡 命令未修改\n")
+				continue
+			}
+			// 修改后的命令不再沿用模型的说明和风险评估
+			p, quiet = shell.Proposal{Command: edited}, false
+			showProposal("✏️  修改后的命令：", p)
+		case choiceExplain:
+			if err := r.explain(p.Command); err != nil {
+				statusf("\n❌ %s\n", err)
+			}
+		case choiceCopy:
+			copyToPrompt(p.Command)
+			return p, choiceCopy
+		case choiceRegenerate:
+			alternative, err := r.regenerate(p)
+			if err != nil {
+				statusf("\n❌ %s\n", err)
+				continue
+			}
+			p, quiet = alternative, false
+			showProposal("💡 AI生成的命令：", p)
+		default:
+			return p, choice
+		}
+	}
+}
+
+// propose 从模型生成命令的回复中取出命令。回复不是约定的 JSON 时自动要求模型按格式重新回复一次，
+// 仍然不符合时从回复中提取命令，有多个候选时由用户选择。messages 是生成 reply 时发送的对话。
+// 返回的 Command 为空表示回复中没有可执行的命令
+func (r *commandReview) propose(messages []client.Message, reply string) shell.Proposal {
+	p, err := shell.ParseProposal(reply)
+	if err == nil {
+		return p
+	}
+
+	statusf("\n\n⚠️  %s，要求模型重新回复\n", err)
+	retry := append(slices.Clone(messages),
+		client.Message{Role: "assistant", Content: reply},
+		client.Message{Role: "user", Content: fmt.Sprintf(formatRetryPrompt, err)},
+	)
+	second, genErr := r.generate(retry)
+	if genErr != nil {
+		statusf("\n❌ %s\n", genErr)
+	} else if p, err := shell.ParseProposal(second); err == nil {
+		return p
+	} else {
+		reply = second
+	}
+
+	// 两次都不符合格式，从最后一次回复中提取命令
+	candidates := shell.Extract(reply)
+	switch len(candidates) {
+	case 0:
+		return shell.Proposal{}
+	case 1:
+		return shell.Proposal{Command: candidates[0]}
+	}
+	return shell.Proposal{Command: r.pick(candidates)}
+}
+
+// pick 列出多个候选命令由用户选择，直接回车选择第一个，返回空字符串表示放弃
+func (r *commandReview) pick(candidates []string) string {
+	statusf("\n\n🔀 回复中有 %d 条候选命令：\n\n", len(candidates))
+	for i, c := range candidates {
+		// 多行命令的后续行与第一行对齐
+		statusf("  %d) %s\n", i+1, strings.ReplaceAll(c, "\n", "\n     "))
+	}
+	statusf("\n请选择要使用的命令 (1-%d，直接回车选择 1): ", len(candidates))
+	answer, _ := r.st.reader.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return candidates[0]
+	}
+	var n int
+	if _, err := fmt.Sscan(answer, &n); err != nil || n < 1 || n > len(candidates) {
+		statusf("❌ 无效的选择: %s\n", answer)
+		return ""
+	}
+	return candidates[n-1]
+}
+
+// explain 请模型逐项解释命令，不执行。解释不加入对话历史
+func (r *commandReview) explain(command string) error {
+	messages := []client.Message{
+		{Role: "system", Content: fmt.Sprintf(explainPrompt, utils.GetEnvironmentInfo())},
+		{Role: "user", Content: "请解释下面的命令：\n\n```\n" + command + "\n```"},
+	}
+	if _, err := r.generate(messages); err != nil {
+		return err
+	}
+	statusf("\n\n")
+	return nil
+}
+
+// regenerate 请模型给出另一种实现方式，返回新的命令。被替换的命令不加入对话历史
+func (r *commandReview) regenerate(p shell.Proposal) (shell.Proposal, error) {
+	messages := append(slices.Clone(r.st.conversation),
+		client.Message{Role: "assistant", Content: p.JSON()},
+		client.Message{Role: "user", Content: regeneratePrompt},
+	)
+	reply, err := r.generate(messages)
+	if err != nil {
+		return shell.Proposal{}, err
+	}
+	alternative := r.propose(messages, reply)
+	if alternative.Command == "" {
+		return shell.Proposal{}, errors.New("模型没有生成新的命令")
+	}
+	return alternative, nil
+}
+
+// generate 向模型发送 messages 并流式显示回复，返回去除首尾空白的回复内容，Ctrl-C 取消生成
+func (r *commandReview) generate(messages []client.Message) (string, error) {
+	st := r.st
+	params := st.params()
+	req := client.ChatRequest{
+		Model:    st.model.Name,
+		Messages: fitContext(messages, st.model, params),
+	}
+	applyGeneration(&req, params)
+
+	statusf("\n🤔 AI正在思考...\n")
+	printer := newReplyPrinter(os.Stdout, st.model.Name, os.Stdout, func(content string) {
+		fmt.Print(content)
+	})
+	ctx := r.interrupts.begin()
+	resp, err := streamReply(ctx, st.provider, req, printer.delta, printer.reasoning)
+	r.interrupts.end()
+	printer.finish(resp, resp.Content, errors.Is(err, context.Canceled), err)
+	st.lastReasoning = resp.Reasoning
+	if resp.Usage != nil {
+		st.lastUsage = resp.Usage
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return "", errors.New("已取消生成")
+	}
+	if err != nil {
+		return "", fmt.Errorf("错误: %w", err)
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// editCommand 修改命令：设置了 VISUAL 或 EDITOR 时在编辑器中打开，否则在命令行中直接编辑。
+// 非终端环境下读取一行新命令，直接回车保持不变
+func editCommand(reader *bufio.Reader, command string) (string, error) {
+	if os.Getenv("VISUAL") != "" || os.Getenv("EDITOR") != "" {
+		return editText(command, "ask-cmd-*.sh")
+	}
+	edited, err := lineedit.New(reader, os.Stdin, "").Edit("📝 > ", command)
+	return strings.TrimSpace(edited), err
+}
+
+// showProposal 显示命令和模型给出的说明
+func showProposal(title string, p shell.Proposal) {
+	statusf("\n\n%s\n\n```bash\n%s\n```\n\n", title, p.Command)
+	if p.Explanation != "" {
+		statusf("📖 %s\n\n", p.Explanation)
+	}
+}
+
+// declaredRisk 返回模型评估的风险等级，未评估时为 Low
+func declaredRisk(p shell.Proposal) safety.Level {
+	level, _ := safety.ParseLevel(p.Risk)
+	return level
+}
+
+// printNoCommand 在回复中没有命令时显示模型的说明（如询问需求细节）
+func printNoCommand(p shell.Proposal) {
+	if p.Explanation != "" {
+		statusf("\n\n💡 %s\n", p.Explanation)
+		return
+	}
+	statusf("\n\n💡 这不是一个有效的命令，请重新描述您的需求。\n")
+}
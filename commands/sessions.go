@@ -0,0 +1,412 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"Qwen-cli/client"
+	"Qwen-cli/session"
+)
+
+// SessionsCommand 创建会话管理命令
+func SessionsCommand() *cobra.Command {
+	sessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "管理已保存的聊天会话",
+		Long: `管理 ask chat 保存的会话。会话 ID 支持唯一前缀。
+
+	 ask sessions list                 # 列出所有会话
+	 ask sessions show <id>            # 查看会话内容（--tree 显示所有分支）
+	 ask sessions rm <id> [id...]      # 删除会话
+	 ask sessions rename <id> <标题>    # 重命名会话
+	 ask sessions search <关键词>       # 全文搜索会话内容`,
+		Run: func(cmd *cobra.Command, args []string) {
+			listSessions(session.DefaultStore())
+		},
+	}
+
+	sessionsCmd.AddCommand(&cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "列出所有会话",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			listSessions(session.DefaultStore())
+		},
+	})
+
+	var showTree bool
+	showCmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "查看会话内容",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := session.DefaultStore().Load(args[0])
+			if err != nil {
+				fmt.Printf("❌ %s\n", err)
+				os.Exit(1)
+			}
+			if machineOutput() {
+				printJSON(os.Stdout, s)
+				return
+			}
+			if showTree {
+				printSessionTree(s)
+				return
+			}
+			printSession(s)
+		},
+	}
+	showCmd.Flags().BoolVar(&showTree, "tree", false, "以树形显示所有分支")
+	sessionsCmd.AddCommand(showCmd)
+
+	sessionsCmd.AddCommand(&cobra.Command{
+		Use:     "rm <id> [id...]",
+		Aliases: []string{"delete"},
+		Short:   "删除会话",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			store := session.DefaultStore()
+			failed := false
+			for _, id := range args {
+				deleted, err := store.Delete(id)
+				if err != nil {
+					fmt.Printf("❌ %s\n", err)
+					failed = true
+					continue
+				}
+				fmt.Printf("🗑️  已删除会话 %s\n", deleted)
+			}
+			if failed {
+				os.Exit(1)
+			}
+		},
+	})
+
+	sessionsCmd.AddCommand(&cobra.Command{
+		Use:   "rename <id> <标题>",
+		Short: "重命名会话",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			store := session.DefaultStore()
+			s, err := store.Load(args[0])
+			if err != nil {
+				fmt.Printf("❌ %s\n", err)
+				os.Exit(1)
+			}
+			s.Title = strings.Join(args[1:], " ")
+			if err := store.Save(s); err != nil {
+				fmt.Printf("❌ %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("✅ 会话 %s 已重命名为: %s\n", s.ID, s.Title)
+		},
+	})
+
+	sessionsCmd.AddCommand(searchCommand())
+
+	return sessionsCmd
+}
+
+func searchCommand() *cobra.Command {
+	var since, until, model, role, from string
+	var limit int
+
+	searchCmd := &cobra.Command{
+		Use:   "search <关键词...>",
+		Short: "全文搜索会话内容",
+		Long: `在所有已保存会话的消息中搜索，按 BM25 相关度排序并显示命中片段。
+
+除了 sessions 目录中的会话，还会搜索配置目录中的 chat_auto_*.md 自动记录和
+/save 导出的 Markdown 文件；已保存为会话的自动记录不会重复出现在结果中。
+索引保存在 sessions/index.json，保存或删除会话时增量更新，搜索前再补齐有变化的文件。
+
+	 ask sessions search nginx 配置
+	 ask sessions search 反向代理 --since 2025-01-01 --model qwen-plus`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := session.SearchOptions{Model: model, Role: role, Limit: limit}
+
+			var err error
+			if opts.Since, err = parseDate(since, false); err != nil {
+				fmt.Printf("❌ --since: %s\n", err)
+				os.Exit(1)
+			}
+			if opts.Until, err = parseDate(until, true); err != nil {
+				fmt.Printf("❌ --until: %s\n", err)
+				os.Exit(1)
+			}
+			switch from {
+			case "", client.RoleUser, client.RoleAssistant:
+				opts.From = from
+			default:
+				fmt.Printf("❌ --from 只能是 user 或 assistant\n")
+				os.Exit(1)
+			}
+
+			results, err := session.DefaultStore().Search(strings.Join(args, " "), opts)
+			if err != nil {
+				fmt.Printf("❌ 搜索失败: %s\n", err)
+				os.Exit(1)
+			}
+			if machineOutput() {
+				hits := make([]searchHit, 0, len(results))
+				for _, r := range results {
+					msg := r.Session.Messages[r.Message]
+					hit := searchHit{
+						SessionID: r.Session.ID,
+						Title:     r.Session.Title,
+						Model:     r.Session.Model,
+						Message:   r.Message,
+						Role:      msg.Role,
+						Content:   msg.Content,
+						Snippet:   session.Snippet(msg.Content, r.Terms, 60, nil),
+						Score:     r.Score,
+						CreatedAt: msg.CreatedAt,
+					}
+					if r.File != "" {
+						hit.SessionID, hit.File = "", r.File
+					}
+					hits = append(hits, hit)
+				}
+				printList(hits)
+				return
+			}
+			if len(results) == 0 {
+				fmt.Println("🔍 没有找到匹配的消息")
+				return
+			}
+
+			highlight := func(s string) string { return "[" + s + "]" }
+			if isTerminal(os.Stdout) {
+				highlight = func(s string) string { return "\033[1;33m" + s + "\033[0m" }
+			}
+
+			for i, r := range results {
+				msg := r.Session.Messages[r.Message]
+				icon := "👤"
+				if msg.Role == client.RoleAssistant {
+					icon = "🤖"
+				}
+				source := r.Session.ID
+				if r.File != "" {
+					source = r.File
+				}
+				fmt.Printf("%d. %s  %s  %s  %.2f\n", i+1, source,
+					msg.CreatedAt.Format("2006-01-02 15:04"), r.Session.Model, r.Score)
+				fmt.Printf("   %s\n", r.Session.Title)
+				fmt.Printf("   %s %s\n\n", icon, session.Snippet(msg.Content, r.Terms, 60, highlight))
+			}
+		},
+	}
+
+	searchCmd.Flags().StringVar(&since, "since", "", "只搜索该日期之后的消息 (YYYY-MM-DD)")
+	searchCmd.Flags().StringVar(&until, "until", "", "只搜索该日期之前的消息 (YYYY-MM-DD，含当天)")
+	searchCmd.Flags().StringVar(&model, "model", "", "按会话模型过滤")
+	searchCmd.Flags().StringVar(&role, "role", "", "按会话角色过滤")
+	searchCmd.Flags().StringVar(&from, "from", "", "按消息作者过滤 (user 或 assistant)")
+	searchCmd.Flags().IntVarP(&limit, "limit", "n", 10, "最多显示的结果数")
+
+	return searchCmd
+}
+
+// parseDate 解析 YYYY-MM-DD 格式的本地日期，endOfDay 为 true 时返回当天最后一刻
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的日期 %q，格式应为 YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+// isTerminal 判断文件是否为终端，用于决定是否输出颜色
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// sessionSummary 是机器可读模式下会话列表中的一项
+type sessionSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model"`
+	Role      string    `json:"role,omitempty"`
+	Messages  int       `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// searchHit 是机器可读模式下的一条搜索结果
+type searchHit struct {
+	SessionID string    `json:"session_id,omitempty"`
+	File      string    `json:"file,omitempty"` // 命中 Markdown 对话记录时的文件路径
+	Title     string    `json:"title"`
+	Model     string    `json:"model"`
+	Message   int       `json:"message"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Snippet   string    `json:"snippet"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// printList 在 json 模式下输出数组，jsonl 模式下每行输出一项
+func printList[T any](items []T) {
+	if outputFormat == outputJSONL {
+		for _, item := range items {
+			printJSON(os.Stdout, item)
+		}
+		return
+	}
+	printJSON(os.Stdout, items)
+}
+
+func listSessions(store *session.Store) {
+	sessions, err := store.List()
+	if err != nil {
+		fmt.Printf("❌ %s\n", err)
+		os.Exit(1)
+	}
+	if machineOutput() {
+		summaries := make([]sessionSummary, 0, len(sessions))
+		for _, s := range sessions {
+			summaries = append(summaries, sessionSummary{
+				ID:        s.ID,
+				Title:     s.Title,
+				Model:     s.Model,
+				Role:      s.Role,
+				Messages:  len(s.Messages),
+				CreatedAt: s.CreatedAt,
+				UpdatedAt: s.UpdatedAt,
+			})
+		}
+		printList(summaries)
+		return
+	}
+	if len(sessions) == 0 {
+		fmt.Println("📭 暂无已保存的会话")
+		return
+	}
+
+	for _, s := range sessions {
+		fmt.Printf("%s  %s  %-12s %3d 条  %s\n",
+			s.ID, s.UpdatedAt.Format("2006-01-02 15:04"), s.Model, len(s.Messages), s.Title)
+	}
+}
+
+func printSession(s *session.Session) {
+	fmt.Printf("# %s\n\n", s.Title)
+	fmt.Printf("ID: %s\n", s.ID)
+	fmt.Printf("模型: %s\n", s.Model)
+	if s.Role != "" {
+		fmt.Printf("角色: %s\n", s.Role)
+	}
+	fmt.Printf("创建时间: %s\n", s.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("更新时间: %s\n", s.UpdatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Token 用量: 输入 %d / 输出 %d / 合计 %d\n\n",
+		s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.TotalTokens)
+	if len(s.Branches) > 1 {
+		fmt.Printf("当前分支: %s（共 %d 个分支，使用 --tree 查看）\n\n", s.Current, len(s.Branches))
+	}
+	if c, covered := s.Compacted(); c != nil {
+		path := s.Path()
+		if len(path) > 0 && path[0].Role == client.RoleSystem {
+			covered--
+		}
+		fmt.Printf("## 🗜️ 早期对话摘要 (%s，覆盖前 %d 条消息)\n%s\n\n", c.CreatedAt.Format("15:04:05"), covered, c.Summary)
+	}
+
+	n := 0
+	for _, msg := range s.Path() {
+		switch msg.Role {
+		case client.RoleSystem:
+			continue
+		case client.RoleUser:
+			n++
+			fmt.Printf("## 👤 用户 #%d (%s)\n%s\n\n", n, msg.CreatedAt.Format("15:04:05"), msg.Content)
+			if len(msg.Images) > 0 {
+				fmt.Printf("🖼️  附带 %d 张图片\n\n", len(msg.Images))
+			}
+		default:
+			n++
+			fmt.Printf("## 🤖 AI助手 #%d (%s)\n", n, msg.CreatedAt.Format("15:04:05"))
+			if msg.Reasoning != "" {
+				fmt.Printf("💭 思考过程：\n%s\n\n", msg.Reasoning)
+			}
+			fmt.Printf("%s\n\n", msg.Content)
+		}
+	}
+}
+
+// printSessionTree 以树形显示会话的所有分支，只在分叉处缩进
+func printSessionTree(s *session.Session) {
+	fmt.Printf("# %s\n\n", s.Title)
+
+	labels := map[string][]string{}
+	for _, b := range s.Branches {
+		label := b.ID
+		if b.ID == s.Current {
+			label = "*" + label
+		}
+		labels[b.Head] = append(labels[b.Head], label)
+	}
+
+	byID := map[string]session.Message{}
+	for _, msg := range s.Messages {
+		byID[msg.ID] = msg
+	}
+	children := s.Children()
+
+	var render func(id, first, rest string, n int)
+	render = func(id, first, rest string, n int) {
+		msg := byID[id]
+		icon := "👤"
+		if msg.Role == client.RoleAssistant {
+			icon = "🤖"
+		}
+		line := fmt.Sprintf("%s%s #%d %s", first, icon, n, summarize(msg.Content, 50))
+		if names := labels[id]; len(names) > 0 {
+			line += "  ← " + strings.Join(names, ", ")
+		}
+		fmt.Println(line)
+
+		renderChildren(children[id], rest, n+1, render)
+	}
+
+	// 系统提示词是根消息，不显示
+	roots := children[""]
+	var top []string
+	for _, id := range roots {
+		if byID[id].Role == client.RoleSystem {
+			top = append(top, children[id]...)
+		} else {
+			top = append(top, id)
+		}
+	}
+	renderChildren(top, "", 1, render)
+}
+
+// renderChildren 显示同一父消息下的子消息：只有一个时直接接在下一行，多个时画出分叉
+func renderChildren(ids []string, prefix string, n int, render func(id, first, rest string, n int)) {
+	if len(ids) == 1 {
+		render(ids[0], prefix, prefix, n)
+		return
+	}
+	for i, id := range ids {
+		if i == len(ids)-1 {
+			render(id, prefix+"└─ ", prefix+"   ", n)
+		} else {
+			render(id, prefix+"├─ ", prefix+"│  ", n)
+		}
+	}
+}
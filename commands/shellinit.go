@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// promptFileEnv 由 shell 集成设置，指向一个临时文件。确认命令时选择 c 会把命令写入该文件，
+// ask 退出后 shell 函数读取它并放到下一个提示符中
+const promptFileEnv = "ASK_PROMPT_FILE"
+
+// shellScripts 是各 shell 的集成脚本，用同名函数包装 ask 命令
+var shellScripts = map[string]string{
+	// zsh 的 print -z 把文本放入下一个提示符的编辑缓冲区
+	"zsh": `ask() {
+  local __ask_file __ask_status
+  __ask_file=$(mktemp "${TMPDIR:-/tmp}/ask-prompt.XXXXXX") || { command ask "$@"; return; }
+  ASK_PROMPT_FILE="$__ask_file" command ask "$@"
+  __ask_status=$?
+  if [[ -s "$__ask_file" ]]; then
+    print -z -- "$(<"$__ask_file")"
+  fi
+  rm -f -- "$__ask_file"
+  return $__ask_status
+}
+`,
+	// bash 无法预填提示符，命令写入历史记录，按 ↑ 即可取出
+	"bash": `ask() {
+  local __ask_file __ask_status
+  __ask_file=$(mktemp "${TMPDIR:-/tmp}/ask-prompt.XXXXXX") || { command ask "$@"; return; }
+  ASK_PROMPT_FILE="$__ask_file" command ask "$@"
+  __ask_status=$?
+  if [[ -s "$__ask_file" ]]; then
+    history -s -- "$(<"$__ask_file")"
+  fi
+  rm -f -- "$__ask_file"
+  return $__ask_status
+}
+`,
+}
+
+// ShellInitCommand 创建输出 shell 集成脚本的命令
+func ShellInitCommand() *cobra.Command {
+	shells := make([]string, 0, len(shellScripts))
+	for name := range shellScripts {
+		shells = append(shells, name)
+	}
+	sort.Strings(shells)
+
+	return &cobra.Command{
+		Use:   "shell-init <" + strings.Join(shells, "|") + ">",
+		Short: "输出 shell 集成脚本",
+		Long: `输出 shell 集成脚本。启用后，在 ask cmd 的确认提示中输入 c，
+ask 退出后命令会出现在 shell 的下一个提示符中（bash 中按 ↑ 取出），修改后再执行。
+
+在 ~/.zshrc 或 ~/.bashrc 中加入：
+	 eval "$(ask shell-init zsh)"
+	 eval "$(ask shell-init bash)"`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: shells,
+		Run: func(cmd *cobra.Command, args []string) {
+			script, ok := shellScripts[args[0]]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "❌ 不支持的 shell: %s（支持 %s）\n", args[0], strings.Join(shells, "、"))
+				os.Exit(1)
+			}
+			fmt.Print(script)
+		},
+	}
+}
+
+// copyToPrompt 把命令交给 shell 集成，ask 退出后出现在 shell 的下一个提示符中；
+// 未启用 shell 集成时通过 OSC 52 控制序列复制到终端的剪贴板
+func copyToPrompt(command string) {
+	if path := os.Getenv(promptFileEnv); path != "" {
+		err := os.WriteFile(path, []byte(command), 0600)
+		if err == nil {
+			statusf("📋 退出后命令会出现在 shell 的下一个提示符中\n")
+			return
+		}
+		statusf("❌ 无法写入 %s: %s\n", path, err)
+	}
+
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if isTerminal(f) {
+			fmt.Fprintf(f, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(command)))
+			statusf("📋 已复制到剪贴板（需要终端支持 OSC 52）\n")
+			statusf("💡 运行 eval \"$(ask shell-init zsh)\" 或 eval \"$(ask shell-init bash)\" 后可以直接放到下一个提示符中\n")
+			return
+		}
+	}
+	statusf("💡 未启用 shell 集成，无法复制命令。请运行 eval \"$(ask shell-init zsh)\" 或 eval \"$(ask shell-init bash)\"\n")
+}
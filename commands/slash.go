@@ -0,0 +1,411 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"Qwen-cli/attach"
+	"Qwen-cli/client"
+	"Qwen-cli/config"
+	"Qwen-cli/lineedit"
+	"Qwen-cli/session"
+)
+
+// replState 是 chat 和 cmd 两个 REPL 共享的状态，斜杠命令通过它读取和修改当前对话
+type replState struct {
+	cfg    config.Config
+	reader *bufio.Reader
+
+	conversation  []client.Message
+	session       *session.Session // cmd 不保存会话，为 nil
+	role          string           // 通过 /prompt 选择的角色
+	model         config.ModelConfig
+	provider      client.Provider
+	lastUsage     *client.Usage
+	lastReasoning string        // 上一次回复的思考过程，/think show 查看
+	attachments   []attach.File // /file 附加的文件，随下一条消息发送
+	images        []string      // /image 附加的图片，随下一条消息发送
+
+	// 生成参数优先级：/set 会话参数 > 命令行标志 > 模型配置
+	modelParams   config.GenerationConfig
+	flagParams    config.GenerationConfig
+	sessionParams config.GenerationConfig
+
+	// save 在命令修改了会话后调用，为 nil 时不保存
+	save func()
+
+	// 以下字段由命令设置，REPL 每轮开始时重置
+	reply bool // 命令执行后立即向模型请求回复，如 /retry、/edit
+	quit  bool
+}
+
+// params 返回当前生效的生成参数
+func (st *replState) params() config.GenerationConfig {
+	return st.modelParams.Merge(st.flagParams).Merge(st.sessionParams)
+}
+
+// switchModel 切换模型及其后端，模型自带的生成参数随之替换
+func (st *replState) switchModel(model config.ModelConfig) error {
+	provider, err := newProvider(st.cfg, model)
+	if err != nil {
+		return fmt.Errorf("无法切换到模型 %s: %w", model.Name, err)
+	}
+	st.provider = provider
+	st.model = model
+	st.modelParams = model.GenerationConfig
+	if st.session != nil {
+		st.session.Model = model.Name
+	}
+	return nil
+}
+
+// switchRole 将系统提示词替换为角色 name 的提示词
+func (st *replState) switchRole(name string) {
+	st.role = name
+	system := client.Message{Role: client.RoleSystem, Content: st.cfg.Roles[name]}
+	if len(st.conversation) > 0 && st.conversation[0].Role == client.RoleSystem {
+		st.conversation[0] = system
+	} else {
+		st.conversation = append([]client.Message{system}, st.conversation...)
+	}
+	if st.session != nil {
+		st.session.SetSystem(name, system.Content)
+	}
+}
+
+// slashCommand 是 REPL 中的一条斜杠命令
+type slashCommand struct {
+	Name    string   // 命令名，如 /model
+	Aliases []string // 别名，不以 / 开头的别名只在单独输入时生效，如 exit
+	Args    string   // 参数说明，如 [名称]
+	Help    string
+	Run     func(st *replState, args string) error
+}
+
+// commandRegistry 保存 REPL 支持的斜杠命令
+type commandRegistry struct {
+	commands []*slashCommand
+	usage    string // /help 在命令列表之后显示的说明
+}
+
+// newCommandRegistry 返回包含 chat 和 cmd 共用命令的注册表
+func newCommandRegistry() *commandRegistry {
+	r := &commandRegistry{}
+	r.add(
+		slashCommand{
+			Name:    "/help",
+			Aliases: []string{"help"},
+			Help:    "显示所有命令",
+			Run: func(st *replState, args string) error {
+				r.printHelp()
+				return nil
+			},
+		},
+		slashCommand{
+			Name: "/model",
+			Args: "[名称]",
+			Help: "切换模型，不带参数时从列表中选择",
+			Run:  runModelCommand,
+		},
+		slashCommand{
+			Name: "/prompt",
+			Args: "[名称]",
+			Help: "切换角色提示词，不带参数时从列表中选择",
+			Run:  runPromptCommand,
+		},
+		slashCommand{
+			Name: "/set",
+			Args: "[参数 值]",
+			Help: "调整本次会话的生成参数，不带参数时查看当前参数",
+			Run: func(st *replState, args string) error {
+				handleSetCommand("/set "+args, &st.sessionParams, st.params())
+				return nil
+			},
+		},
+		slashCommand{
+			Name: "/think",
+			Args: "on|off|show",
+			Help: "开关思考模式（enable_thinking），show 查看上一次回复的思考过程",
+			Run:  runThinkCommand,
+		},
+		slashCommand{
+			Name: "/tokens",
+			Help: "查看当前对话的 token 估算和上下文窗口占用",
+			Run: func(st *replState, args string) error {
+				printTokenUsage(st.conversation, st.model, st.params(), st.lastUsage)
+				return nil
+			},
+		},
+		slashCommand{
+			Name: "/file",
+			Args: "<路径...>",
+			Help: "附加文件、目录或通配符（如 src/**/*.go）到下一条消息，也可在消息中用 @路径",
+			Run:  runFileCommand,
+		},
+		slashCommand{
+			Name: "/image",
+			Args: "<路径...>",
+			Help: "附加图片到下一条消息，需要支持图片的模型（vision: true）",
+			Run:  runImageCommand,
+		},
+		slashCommand{
+			Name: "/clear",
+			Help: "清空对话历史，保留系统提示词",
+			Run:  runClearCommand,
+		},
+		slashCommand{
+			Name: "/save",
+			Args: "[-all]",
+			Help: "保存最后一次回复，-all 保存完整对话",
+			Run: func(st *replState, args string) error {
+				var fileName string
+				if args == "-all" || args == "--all" {
+					fileName = saveFullConversation(st.conversation)
+				} else {
+					fileName = saveLastResponse(st.conversation)
+				}
+				// 导出的文件可能不在配置目录中，记入索引后 ask sessions search 也能搜到
+				if fileName != "" {
+					if err := session.DefaultStore().IndexTranscript(fileName); err != nil {
+						statusf("⚠️  无法索引导出的文件: %s\n", err)
+					}
+				}
+				return nil
+			},
+		},
+		slashCommand{
+			Name:    "/exit",
+			Aliases: []string{"exit", "/quit", "quit"},
+			Help:    "退出",
+			Run: func(st *replState, args string) error {
+				st.quit = true
+				return nil
+			},
+		},
+	)
+	return r
+}
+
+// add 注册命令，同名命令会替换已有的命令
+func (r *commandRegistry) add(commands ...slashCommand) {
+	for _, c := range commands {
+		c := c
+		if existing := r.lookup(c.Name); existing != nil {
+			*existing = c
+			continue
+		}
+		r.commands = append(r.commands, &c)
+	}
+}
+
+// lookup 按名称或别名查找命令
+func (r *commandRegistry) lookup(name string) *slashCommand {
+	for _, c := range r.commands {
+		if c.Name == name {
+			return c
+		}
+		for _, alias := range c.Aliases {
+			if alias == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// names 返回所有命令名和以 / 开头的别名，用于补全
+func (r *commandRegistry) names() []string {
+	var names []string
+	for _, c := range r.commands {
+		names = append(names, c.Name)
+		for _, alias := range c.Aliases {
+			if strings.HasPrefix(alias, "/") {
+				names = append(names, alias)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// commandPattern 匹配看起来像命令的输入，如 /modle。/etc/nginx/nginx.conf 之类的路径不是命令
+var commandPattern = regexp.MustCompile(`^/[a-z-]+$`)
+
+// dispatch 执行输入对应的命令。handled 为 false 表示输入不是命令，应作为普通消息发送
+func (r *commandRegistry) dispatch(st *replState, text string) (handled bool, err error) {
+	name, args, _ := strings.Cut(text, " ")
+	if i := strings.IndexByte(name, '\n'); i >= 0 {
+		name, args = name[:i], text[i+1:]
+	}
+	args = strings.TrimSpace(args)
+
+	c := r.lookup(name)
+	// 不带 / 的别名（如 exit、help）必须单独输入，避免误判普通消息
+	if c == nil || (!strings.HasPrefix(name, "/") && args != "") {
+		if !commandPattern.MatchString(name) {
+			return false, nil
+		}
+		return true, r.unknown(name)
+	}
+	return true, c.Run(st, args)
+}
+
+// unknown 返回未知命令的错误，并给出拼写相近的命令
+func (r *commandRegistry) unknown(name string) error {
+	var suggestions []string
+	for _, candidate := range r.names() {
+		if strings.HasPrefix(candidate, name) || editDistance(name, candidate) <= 2 {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	if len(suggestions) == 0 {
+		return fmt.Errorf("未知命令 %s，输入 /help 查看所有命令", name)
+	}
+	return fmt.Errorf("未知命令 %s，你是不是想输入 %s？", name, strings.Join(suggestions, "、"))
+}
+
+// printHelp 列出所有命令
+func (r *commandRegistry) printHelp() {
+	fmt.Println("\n📚 可用命令：")
+	for _, c := range r.commands {
+		usage := c.Name
+		if c.Args != "" {
+			usage += " " + c.Args
+		}
+		fmt.Printf("  %s%s %s", usage, strings.Repeat(" ", max(0, 22-lineedit.Width(usage))), c.Help)
+		if len(c.Aliases) > 0 {
+			fmt.Printf("（也可输入 %s）", strings.Join(c.Aliases, "、"))
+		}
+		fmt.Println()
+	}
+	if r.usage != "" {
+		fmt.Println()
+		fmt.Print(r.usage)
+	}
+	fmt.Println()
+}
+
+// editDistance 返回两个字符串的编辑距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// runModelCommand 处理 /model [名称]
+func runModelCommand(st *replState, args string) error {
+	var model config.ModelConfig
+	if args != "" {
+		var ok bool
+		if model, ok = lookupModel(st.cfg, args); !ok {
+			return fmt.Errorf("未找到模型 %s", args)
+		}
+	} else {
+		fmt.Println("🤖 切换模型：")
+		modelKeys := sortedModelKeys(st.cfg.Models)
+		for i, key := range modelKeys {
+			model := st.cfg.Models[key]
+			providerName := model.Provider
+			if providerName == "" {
+				providerName = client.ProviderOpenAI
+			}
+			fmt.Printf("  %d. %s (%s, %s)\n", i+1, model.Name, key, providerName)
+		}
+		fmt.Print("👉 请选择模型编号：")
+		modelChoice, _ := st.reader.ReadString('\n')
+		modelIndex := 0
+		fmt.Sscanf(strings.TrimSpace(modelChoice), "%d", &modelIndex)
+		if modelIndex < 1 || modelIndex > len(modelKeys) {
+			return errors.New("无效的模型编号，未进行变更。")
+		}
+		model = st.cfg.Models[modelKeys[modelIndex-1]]
+	}
+
+	if err := st.switchModel(model); err != nil {
+		return err
+	}
+	fmt.Printf("已切换到模型：%s\n", model.Name)
+	return nil
+}
+
+// runPromptCommand 处理 /prompt [名称]
+func runPromptCommand(st *replState, args string) error {
+	name := args
+	if name != "" {
+		if _, ok := st.cfg.Roles[name]; !ok {
+			return fmt.Errorf("未找到角色提示词 %s", name)
+		}
+	} else {
+		fmt.Println("🎭 可用的角色提示词：")
+		prompts := make([]string, 0, len(st.cfg.Roles))
+		for role := range st.cfg.Roles {
+			prompts = append(prompts, role)
+		}
+		sort.Strings(prompts)
+		for i, role := range prompts {
+			fmt.Printf("  %d. %s\n", i+1, role)
+		}
+		fmt.Print("👉 请选择角色提示词编号：")
+		promptChoice, _ := st.reader.ReadString('\n')
+		promptIndex := 0
+		fmt.Sscanf(strings.TrimSpace(promptChoice), "%d", &promptIndex)
+		if promptIndex < 1 || promptIndex > len(prompts) {
+			return errors.New("无效的角色提示词编号，未进行变更。")
+		}
+		name = prompts[promptIndex-1]
+	}
+
+	st.switchRole(name)
+	if st.save != nil {
+		st.save()
+	}
+	fmt.Printf("已切换到角色提示词：%s\n", name)
+	return nil
+}
+
+// runClearCommand 处理 /clear。chat 中原会话保存后开始一个新会话
+func runClearCommand(st *replState, args string) error {
+	var system []client.Message
+	if len(st.conversation) > 0 && st.conversation[0].Role == client.RoleSystem {
+		system = st.conversation[:1]
+	}
+	st.conversation = append([]client.Message(nil), system...)
+	st.lastUsage = nil
+	st.attachments = nil
+	st.images = nil
+
+	if st.session == nil {
+		fmt.Println("🧹 已清空对话历史")
+		return nil
+	}
+	previous := st.session
+	if st.save != nil {
+		st.save()
+	}
+	content := ""
+	if len(system) > 0 {
+		content = system[0].Content
+	}
+	st.session = session.New(previous.Model, previous.Role, content)
+	fmt.Printf("🧹 已清空对话历史，之前的对话已保存为会话 %s\n", previous.ID)
+	return nil
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	var ran, ranArgs string
+	r := &commandRegistry{}
+	for _, name := range []string{"/model", "/save", "/exit"} {
+		r.add(slashCommand{Name: name, Run: func(st *replState, args string) error {
+			ran, ranArgs = name, args
+			return nil
+		}})
+	}
+	r.lookup("/exit").Aliases = []string{"exit", "/quit"}
+
+	tests := []struct {
+		text     string
+		handled  bool
+		run      string // 执行的命令，为空表示没有执行
+		args     string
+		errorHas string // 错误信息应包含的内容，为空表示没有错误
+	}{
+		{"/model qwen-max", true, "/model", "qwen-max", ""},
+		{"/save\n-all", true, "/save", "-all", ""},
+		{"/quit", true, "/exit", "", ""},
+		{"exit", true, "/exit", "", ""},
+		{"exit the loop early", false, "", "", ""},
+		{"/modle", true, "", "", "/model"},
+		{"/modle qwen-max", true, "", "", "/model"},
+		{"/unknown-thing", true, "", "", "/help"},
+		{"/etc/nginx/nginx.conf 报错了", false, "", "", ""},
+		{"/usr/bin/python 找不到", false, "", "", ""},
+		{"/tmp 目录满了怎么办", true, "", "", "/help"}, // 单段路径与命令无法区分，按拼错的命令提示
+		{"/Users/me/app.log 是什么", false, "", "", ""},
+		{"/var/log/syslog\n里有很多错误", false, "", "", ""},
+		{"/etc/hosts", false, "", "", ""},
+		{"你好", false, "", "", ""},
+	}
+	for _, tt := range tests {
+		ran, ranArgs = "", ""
+		handled, err := r.dispatch(&replState{}, tt.text)
+		if handled != tt.handled || ran != tt.run || ranArgs != tt.args {
+			t.Errorf("dispatch(%q) handled=%v ran=%q args=%q; want %v %q %q", tt.text, handled, ran, ranArgs, tt.handled, tt.run, tt.args)
+		}
+		switch {
+		case tt.errorHas == "" && err != nil:
+			t.Errorf("dispatch(%q) error: %v", tt.text, err)
+		case tt.errorHas != "" && (err == nil || !strings.Contains(err.Error(), tt.errorHas)):
+			t.Errorf("dispatch(%q) error = %v, want one mentioning %s", tt.text, err, tt.errorHas)
+		}
+	}
+}
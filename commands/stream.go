@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"Qwen-cli/client"
+	"Qwen-cli/config"
+)
+
+// retryPolicy 根据配置生成重试策略
+func retryPolicy(cfg config.Config) client.RetryPolicy {
+	policy := client.DefaultRetryPolicy()
+	if r := cfg.Retry; r != nil {
+		if r.MaxAttempts > 0 {
+			policy.MaxAttempts = r.MaxAttempts
+		}
+		if r.BaseDelayMs > 0 {
+			policy.BaseDelay = time.Duration(r.BaseDelayMs) * time.Millisecond
+		}
+		if r.MaxDelayMs > 0 {
+			policy.MaxDelay = time.Duration(r.MaxDelayMs) * time.Millisecond
+		}
+		if r.Jitter != nil {
+			policy.Jitter = *r.Jitter
+		}
+	}
+	return policy
+}
+
+// newProvider 为模型创建对应的 Provider，未单独配置的地址和密钥使用全局设置
+func newProvider(cfg config.Config, model config.ModelConfig) (client.Provider, error) {
+	baseURL, apiKey := model.APIURL, model.APIKey
+	switch model.Provider {
+	case "", client.ProviderOpenAI:
+		if baseURL == "" {
+			baseURL = cfg.APIURL
+		}
+		if apiKey == "" {
+			apiKey = cfg.APIKey
+		}
+	case client.ProviderDashScope:
+		if apiKey == "" {
+			apiKey = cfg.APIKey
+		}
+	}
+	return client.NewProvider(model.Provider, baseURL, apiKey, retryPolicy(cfg))
+}
+
+// reply 是一次流式请求的结果
+type reply struct {
+	Content      string
+	Reasoning    string // 思考模型返回的思考过程
+	Usage        *client.Usage
+	FinishReason client.FinishReason
+}
+
+// streamReply 发起流式请求，每收到一段增量内容就调用 onDelta，每收到一段思考过程就调用
+// onReasoning，返回完整回复。ctx 被取消时返回已收到的部分回复和 ctx.Err()
+func streamReply(ctx context.Context, c client.Provider, req client.ChatRequest, onDelta, onReasoning func(text string)) (reply, error) {
+	var result reply
+
+	stream, err := c.Stream(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, err
+	}
+	defer stream.Close()
+
+	var fullResponse, reasoning strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Content = fullResponse.String()
+			result.Reasoning = reasoning.String()
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			return result, err
+		}
+
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage
+		}
+		if reason := chunk.FinishReason(); reason != "" {
+			result.FinishReason = reason
+		}
+
+		if text := chunk.Reasoning(); text != "" {
+			reasoning.WriteString(text)
+			onReasoning(text)
+		}
+
+		content := chunk.Content()
+		if content == "" {
+			continue
+		}
+		fullResponse.WriteString(content)
+		onDelta(content)
+	}
+
+	result.Content = fullResponse.String()
+	result.Reasoning = reasoning.String()
+	return result, nil
+}
@@ -1,8 +1,17 @@
 package commands
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -11,64 +20,278 @@ import (
 )
 
 func TestCommand(cfg config.Config) *cobra.Command {
-	return &cobra.Command{
+	var timeout time.Duration
+
+	testCmd := &cobra.Command{
 		Use:   "test",
 		Short: "测试模型或端点连接",
+		Long: `诊断 API 端点的连通性，依次检查配置、DNS 解析、TLS 握手和 API 密钥，
+然后逐个测试配置文件中的所有模型并显示延迟。
+
+任一检查失败时以非零状态码退出，便于在脚本中使用。`,
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Testing connectivity to the model...")
-
-			params := struct {
-				Model    string `json:"model"`
-				Messages []struct {
-					Role    string `json:"role"`
-					Content string `json:"content"`
-				} `json:"messages"`
-			}{
-				Model: cfg.Models["default"].Name,
-				Messages: []struct {
-					Role    string `json:"role"`
-					Content string `json:"content"`
-				}{
-					{
-						Role:    "system",
-						Content: "You are a helpful assistant.",
-					},
-					{
-						Role:    "user",
-						Content: "Hello",
-					},
-				},
+			defaultModel := cfg.Models["default"]
+			diag := &diagnosis{}
+			provider, err := newDiagnosticProvider(cfg, defaultModel)
+			if err != nil {
+				diag.check("配置", time.Now(), "", err)
+				diag.exit()
 			}
 
-			jsonParams, _ := json.Marshal(params)
+			diag.Endpoint = provider.Endpoint()
+			statusf("🔍 正在诊断: %s\n\n", diag.Endpoint)
 
-			err := client.Client(cfg.APIURL, cfg.APIKey, jsonParams, func(data []byte) {
-				fmt.Println("连接测试成功！")
-				var response struct {
-					Choices []struct {
-						Message struct {
-							Content string `json:"content"`
-						} `json:"message"`
-					} `json:"choices"`
-				}
+			// 配置检查，Ollama 不需要密钥
+			start := time.Now()
+			u, err := url.Parse(diag.Endpoint)
+			if err == nil && (u.Scheme == "" || u.Host == "") {
+				err = fmt.Errorf("无效的 API 地址: %s", diag.Endpoint)
+			}
+			if err == nil && defaultModel.Provider != client.ProviderOllama && cfg.APIKey == "" && defaultModel.APIKey == "" {
+				err = errors.New("未设置 API 密钥（api_key 或 ASK_API_KEY）")
+			}
+			if !diag.check("配置", start, "API 地址和密钥已设置", err) {
+				diag.exit()
+			}
 
-				err := json.Unmarshal(data, &response)
-				if err != nil {
-					fmt.Printf("解析响应错误: %s\n", err)
-					return
-				}
+			// DNS 解析
+			start = time.Now()
+			detail, err := checkDNS(u.Hostname(), timeout)
+			if !diag.check("DNS", start, detail, err) {
+				diag.exit()
+			}
 
-				if len(response.Choices) > 0 {
-					fmt.Println("模型响应:")
-					fmt.Println(response.Choices[0].Message.Content)
-				} else {
-					fmt.Println("模型无响应。")
+			// TLS 握手
+			if u.Scheme == "https" {
+				start = time.Now()
+				detail, err = checkTLS(u, timeout)
+				if !diag.check("TLS", start, detail, err) {
+					diag.exit()
 				}
-			})
+			} else {
+				diag.skip("TLS", "非 HTTPS 地址，已跳过")
+			}
 
-			if err != nil {
-				fmt.Printf("错误: %s\n", err)
+			// 认证：使用默认模型发起最小请求，401/403 视为密钥无效
+			start = time.Now()
+			_, err = pingModel(provider, defaultModel.Name, timeout)
+			var apiErr *client.APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode != http.StatusUnauthorized && apiErr.StatusCode != http.StatusForbidden {
+				// 非认证错误交给模型测试报告
+				err = nil
+			}
+			if !diag.check("认证", start, "API 密钥有效", err) {
+				diag.exit()
+			}
+
+			// 逐个测试模型
+			statusf("\n📋 模型测试:\n")
+			for _, key := range sortedModelKeys(cfg.Models) {
+				model := cfg.Models[key]
+				start := time.Now()
+				reply := ""
+				modelProvider, err := newDiagnosticProvider(cfg, model)
+				if err == nil {
+					reply, err = pingModel(modelProvider, model.Name, timeout)
+				}
+				diag.model(key, model.Name, start, reply, err)
 			}
+
+			diag.exit()
 		},
 	}
+
+	testCmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "每项检查的超时时间")
+
+	return testCmd
+}
+
+// diagnosticResult 是一项检查或一个模型的测试结果
+type diagnosticResult struct {
+	Type      string `json:"type"` // check 或 model
+	Name      string `json:"name"`
+	Model     string `json:"model,omitempty"`
+	OK        bool   `json:"ok"`
+	Skipped   bool   `json:"skipped,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// diagnosis 收集诊断结果，并按输出格式显示
+type diagnosis struct {
+	Endpoint string             `json:"endpoint"`
+	OK       bool               `json:"ok"`
+	Checks   []diagnosticResult `json:"checks"`
+	Models   []diagnosticResult `json:"models"`
+	failed   bool
+}
+
+// check 记录一项检查，返回是否通过
+func (d *diagnosis) check(name string, start time.Time, detail string, err error) bool {
+	elapsed := time.Since(start).Round(time.Millisecond)
+	result := diagnosticResult{Type: "check", Name: name, OK: err == nil, LatencyMs: elapsed.Milliseconds()}
+	if err != nil {
+		d.failed = true
+		result.Error = err.Error()
+	} else {
+		result.Detail = detail
+	}
+	d.Checks = append(d.Checks, result)
+
+	switch {
+	case outputFormat == outputJSONL:
+		printJSON(os.Stdout, result)
+	case outputFormat == outputText && err != nil:
+		fmt.Printf("❌ %s: %s (%s)\n", name, err, elapsed)
+	case outputFormat == outputText:
+		fmt.Printf("✅ %s: %s (%s)\n", name, detail, elapsed)
+	}
+	return err == nil
+}
+
+// skip 记录一项被跳过的检查
+func (d *diagnosis) skip(name, detail string) {
+	result := diagnosticResult{Type: "check", Name: name, OK: true, Skipped: true, Detail: detail}
+	d.Checks = append(d.Checks, result)
+
+	switch outputFormat {
+	case outputJSONL:
+		printJSON(os.Stdout, result)
+	case outputText:
+		fmt.Printf("⚪ %s: %s\n", name, detail)
+	}
+}
+
+// model 记录一个模型的测试结果
+func (d *diagnosis) model(key, name string, start time.Time, reply string, err error) {
+	elapsed := time.Since(start).Round(time.Millisecond)
+	result := diagnosticResult{Type: "model", Name: key, Model: name, OK: err == nil, LatencyMs: elapsed.Milliseconds()}
+	if err != nil {
+		d.failed = true
+		result.Error = err.Error()
+	} else {
+		result.Detail = reply
+	}
+	d.Models = append(d.Models, result)
+
+	switch {
+	case outputFormat == outputJSONL:
+		printJSON(os.Stdout, result)
+	case outputFormat == outputText && err != nil:
+		fmt.Printf("❌ %-12s %-16s %8s  %s\n", key, name, elapsed, err)
+	case outputFormat == outputText:
+		fmt.Printf("✅ %-12s %-16s %8s  %s\n", key, name, elapsed, summarize(reply, 40))
+	}
+}
+
+// exit 输出诊断结论并退出，有任一项失败时状态码为 1
+func (d *diagnosis) exit() {
+	d.OK = !d.failed
+	switch outputFormat {
+	case outputJSON:
+		printJSON(os.Stdout, d)
+	case outputJSONL:
+		printJSON(os.Stdout, map[string]any{"type": "summary", "endpoint": d.Endpoint, "ok": d.OK})
+	default:
+		if d.failed {
+			fmt.Println("\n❌ 诊断未通过")
+		} else {
+			fmt.Println("\n✅ 连接测试成功！")
+		}
+	}
+	if d.failed {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// newDiagnosticProvider 创建不重试的 Provider，以便如实反映端点状态
+func newDiagnosticProvider(cfg config.Config, model config.ModelConfig) (client.Provider, error) {
+	cfg.Retry = &config.RetryConfig{MaxAttempts: 1}
+	return newProvider(cfg, model)
+}
+
+// checkDNS 解析主机名，返回解析到的地址
+func checkDNS(host string, timeout time.Duration) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return fmt.Sprintf("%s 是 IP 地址，无需解析", host), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("解析 %s 失败: %w", host, err)
+	}
+	return fmt.Sprintf("%s -> %s", host, strings.Join(addrs, ", ")), nil
+}
+
+// checkTLS 与端点完成 TLS 握手并校验证书
+func checkTLS(u *url.URL, timeout time.Duration) (string, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	if err != nil {
+		return "", fmt.Errorf("TLS 握手失败: %w", err)
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	detail := tls.VersionName(state.Version)
+	if len(state.PeerCertificates) > 0 {
+		detail += fmt.Sprintf("，证书有效期至 %s", state.PeerCertificates[0].NotAfter.Format("2006-01-02"))
+	}
+	return detail, nil
+}
+
+// pingModel 向模型发送一条最小的非流式请求
+func pingModel(provider client.Provider, model string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := provider.Complete(ctx, client.ChatRequest{
+		Model: model,
+		Messages: []client.Message{
+			{Role: client.RoleUser, Content: "Hello"},
+		},
+		MaxTokens: 16,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("模型无响应")
+	}
+	return resp.Content(), nil
+}
+
+// sortedModelKeys 返回排序后的模型键，default 排在最前
+func sortedModelKeys(models map[string]config.ModelConfig) []string {
+	keys := make([]string, 0, len(models))
+	for key := range models {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == "default" || keys[j] == "default" {
+			return keys[i] == "default"
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// summarize 将文本压缩为单行并截断到 n 个字符
+func summarize(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return text
 }
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+
+	"Qwen-cli/lineedit"
+)
+
+// 终端暗色显示的控制序列
+const (
+	dimStart = "\033[2m"
+	dimEnd   = "\033[0m"
+)
+
+// thinkingView 显示思考模型（如 Qwen3、QwQ）的思考过程。终端中以暗色显示，
+// 正式回复开始后，如果思考过程仍完整地留在屏幕内，就折叠为一行摘要
+type thinkingView struct {
+	out           *os.File
+	tty           bool
+	width, height int // 终端尺寸，无法获取时为 0，此时不折叠
+	start         time.Time
+	open          bool
+	rows, col     int // 已占用的行数和当前行的列宽，用于折叠时回退光标
+}
+
+func newThinkingView(out *os.File) *thinkingView {
+	v := &thinkingView{out: out, tty: isTerminal(out)}
+	if v.tty {
+		v.width, v.height, _ = term.GetSize(int(out.Fd()))
+	}
+	return v
+}
+
+// write 显示一段思考过程，第一段之前先输出标题
+func (v *thinkingView) write(text string) {
+	if !v.open {
+		v.open, v.start = true, time.Now()
+		v.rows, v.col = 1, 0
+		v.print("💭 思考中...\n")
+	}
+	v.print(text)
+	for _, r := range text {
+		if r == '\n' {
+			v.rows++
+			v.col = 0
+			continue
+		}
+		w := lineedit.Width(string(r))
+		if v.width > 0 && v.col+w > v.width {
+			v.rows++
+			v.col = 0
+		}
+		v.col += w
+	}
+}
+
+// close 结束思考过程的显示，正式回复开始或请求结束时调用，可重复调用
+func (v *thinkingView) close() {
+	if !v.open {
+		return
+	}
+	v.open = false
+	if v.col > 0 {
+		fmt.Fprintln(v.out)
+		v.rows++
+	}
+	elapsed := time.Since(v.start).Seconds()
+	if v.width > 0 && v.rows < v.height {
+		// 光标回到标题行并清除到屏幕末尾
+		fmt.Fprintf(v.out, "\033[%dF\033[J", v.rows)
+		v.print(fmt.Sprintf("💭 已思考 %.1f 秒（/think show 查看思考过程）\n", elapsed))
+		return
+	}
+	v.print(fmt.Sprintf("💭 思考结束，用时 %.1f 秒\n\n", elapsed))
+}
+
+// print 输出文本，终端中以暗色显示
+func (v *thinkingView) print(text string) {
+	if v.tty {
+		text = dimStart + text + dimEnd
+	}
+	fmt.Fprint(v.out, text)
+}
+
+// runThinkCommand 处理 /think on|off|show
+func runThinkCommand(st *replState, args string) error {
+	switch args {
+	case "":
+		switch on := st.params().EnableThinking; {
+		case on == nil:
+			fmt.Println("💭 思考模式：未设置（使用服务端默认值）")
+		case *on:
+			fmt.Println("💭 思考模式：已开启")
+		default:
+			fmt.Println("💭 思考模式：已关闭")
+		}
+	case "on", "off":
+		on := args == "on"
+		st.sessionParams.EnableThinking = &on
+		if on {
+			fmt.Println("💭 已开启思考模式（enable_thinking=true）")
+		} else {
+			fmt.Println("💭 已关闭思考模式（enable_thinking=false）")
+		}
+	case "show":
+		if st.lastReasoning == "" {
+			return errors.New("上一次回复没有思考过程")
+		}
+		fmt.Printf("💭 上一次回复的思考过程：\n%s\n", st.lastReasoning)
+	default:
+		return errors.New("用法: /think on|off|show")
+	}
+	return nil
+}
@@ -16,6 +16,22 @@ func VersionCommand() *cobra.Command {
 		Short: "显示版本信息",
 		Long:  `显示Qwen-cli的当前版本信息，包括构建时间和Git提交信息。`,
 		Run: func(cmd *cobra.Command, args []string) {
+			if machineOutput() {
+				printJSON(cmd.OutOrStdout(), struct {
+					Version   string
+					BuildDate string
+					GitCommit string
+					GoVersion string
+					Platform  string
+				}{
+					Version:   version.Version,
+					BuildDate: version.BuildDate,
+					GitCommit: version.GitCommit,
+					GoVersion: runtime.Version(),
+					Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+				})
+				return
+			}
 			fmt.Println(version.GetVersionInfo())
 		},
 	}
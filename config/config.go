@@ -6,10 +6,52 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 )
 
 type ModelConfig struct {
 	Name string `json:"name"`
+	// Provider 选择后端：openai（默认，OpenAI 兼容接口）、dashscope（DashScope 原生接口）、ollama
+	Provider string `json:"provider,omitempty"`
+	// APIURL 和 APIKey 覆盖全局设置；为空时 openai 使用全局 api_url，
+	// dashscope 和 ollama 使用各自的默认地址
+	APIURL string `json:"api_url,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+	// ContextWindow 模型的上下文窗口大小（token 数），为 0 时使用 DefaultContextWindow
+	ContextWindow int `json:"context_window,omitempty"`
+	// Vision 模型支持图片输入，如 qwen-vl-plus、qwen-vl-max
+	Vision bool `json:"vision,omitempty"`
+	// 生成参数（temperature、top_p 等）与上述字段平铺在同一层
+	GenerationConfig
+}
+
+// DefaultContextWindow 未配置 context_window 时使用的上下文窗口大小
+const DefaultContextWindow = 32768
+
+// Window 返回模型的上下文窗口大小
+func (m ModelConfig) Window() int {
+	if m.ContextWindow > 0 {
+		return m.ContextWindow
+	}
+	return DefaultContextWindow
+}
+
+// CommandPolicyConfig 是 ask cmd 执行生成的命令之前的检查策略。
+// 规则是命令前缀，如 "git push"、"rm -rf *"，每个单词支持通配符
+type CommandPolicyConfig struct {
+	Allow   []string `json:"allow,omitempty"`   // 匹配的命令直接执行，不询问（不适用于高风险命令）
+	Confirm []string `json:"confirm,omitempty"` // 匹配的命令需要输入 yes 确认
+	Deny    []string `json:"deny,omitempty"`    // 匹配的命令拒绝执行
+	// Risk 是各风险等级（low、medium、high）的处理方式：allow、ask、confirm 或 deny
+	Risk map[string]string `json:"risk,omitempty"`
+}
+
+// RetryConfig 请求失败时的重试配置，未设置的字段使用默认值
+type RetryConfig struct {
+	MaxAttempts int      `json:"max_attempts,omitempty"`  // 最大尝试次数（包含首次请求）
+	BaseDelayMs int      `json:"base_delay_ms,omitempty"` // 首次重试等待毫秒数，之后指数增长
+	MaxDelayMs  int      `json:"max_delay_ms,omitempty"`  // 单次等待上限毫秒数
+	Jitter      *float64 `json:"jitter,omitempty"`        // 随机抖动比例 0~1
 }
 
 type Config struct {
@@ -17,6 +59,53 @@ type Config struct {
 	APIKey string                 `json:"api_key"`
 	Models map[string]ModelConfig `json:"models"`
 	Roles  map[string]string      `json:"roles"`
+	Retry  *RetryConfig           `json:"retry,omitempty"`
+	// CompactThreshold 对话占用上下文窗口的比例超过该值时自动压缩早期对话，
+	// 未设置时使用 DefaultCompactThreshold，设为 0 关闭自动压缩
+	CompactThreshold *float64 `json:"compact_threshold,omitempty"`
+	// SaveThinking 在会话和对话记录中保存思考模型的思考过程，默认不保存
+	SaveThinking bool `json:"save_thinking,omitempty"`
+	// CommandPolicy 是 ask cmd 执行命令前的检查策略，未设置时使用默认策略
+	CommandPolicy *CommandPolicyConfig `json:"command_policy,omitempty"`
+	// CommandTimeout 是 ask cmd 执行每条命令的超时时间（秒），
+	// 未设置时使用 DefaultCommandTimeout，设为 0 不限制
+	CommandTimeout *int `json:"command_timeout,omitempty"`
+	// CommandOutputLimit 是命令输出加入对话上下文时保留的最大字节数，超出时省略中间部分，
+	// 未设置时使用 DefaultCommandOutputLimit，设为 0 不限制
+	CommandOutputLimit *int `json:"command_output_limit,omitempty"`
+}
+
+// DefaultCompactThreshold 默认的自动压缩阈值
+const DefaultCompactThreshold = 0.8
+
+// CompactRatio 返回自动压缩阈值，0 表示关闭
+func (c Config) CompactRatio() float64 {
+	if c.CompactThreshold == nil {
+		return DefaultCompactThreshold
+	}
+	return *c.CompactThreshold
+}
+
+// DefaultCommandTimeout 默认的命令超时时间
+const DefaultCommandTimeout = 10 * time.Minute
+
+// DefaultCommandOutputLimit 默认加入对话上下文的命令输出字节数
+const DefaultCommandOutputLimit = 8 * 1024
+
+// CommandTimeoutDuration 返回命令超时时间，0 表示不限制
+func (c Config) CommandTimeoutDuration() time.Duration {
+	if c.CommandTimeout == nil {
+		return DefaultCommandTimeout
+	}
+	return time.Duration(*c.CommandTimeout) * time.Second
+}
+
+// OutputLimit 返回加入对话上下文的命令输出字节数上限，0 表示不限制
+func (c Config) OutputLimit() int {
+	if c.CommandOutputLimit == nil {
+		return DefaultCommandOutputLimit
+	}
+	return *c.CommandOutputLimit
 }
 
 // GetConfigDir 获取跨平台配置目录
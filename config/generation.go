@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GenerationConfig 生成参数，未设置（nil）的字段不会发送给服务端
+type GenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	MaxTokens       *int     `json:"max_tokens,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	Stop            []string `json:"stop,omitempty"`
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+	EnableThinking  *bool    `json:"enable_thinking,omitempty"`
+	ThinkingBudget  *int     `json:"thinking_budget,omitempty"`
+}
+
+// GenerationKeys 是 Set 支持的参数名
+var GenerationKeys = []string{
+	"temperature", "top_p", "max_tokens", "seed", "stop",
+	"presence_penalty", "enable_thinking", "thinking_budget",
+}
+
+// Merge 返回以 override 中已设置的字段覆盖 g 后的结果
+func (g GenerationConfig) Merge(override GenerationConfig) GenerationConfig {
+	if override.Temperature != nil {
+		g.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		g.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		g.MaxTokens = override.MaxTokens
+	}
+	if override.Seed != nil {
+		g.Seed = override.Seed
+	}
+	if override.Stop != nil {
+		g.Stop = override.Stop
+	}
+	if override.PresencePenalty != nil {
+		g.PresencePenalty = override.PresencePenalty
+	}
+	if override.EnableThinking != nil {
+		g.EnableThinking = override.EnableThinking
+	}
+	if override.ThinkingBudget != nil {
+		g.ThinkingBudget = override.ThinkingBudget
+	}
+	return g
+}
+
+// normalizeKey 同时接受 top_p 和 top-p 两种写法
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+}
+
+// Set 按参数名设置值，stop 以逗号分隔多个停止词
+func (g *GenerationConfig) Set(key, value string) error {
+	value = strings.TrimSpace(value)
+	switch normalizeKey(key) {
+	case "temperature":
+		return setFloat(&g.Temperature, value, 0, 2)
+	case "top_p":
+		return setFloat(&g.TopP, value, 0, 1)
+	case "presence_penalty":
+		return setFloat(&g.PresencePenalty, value, -2, 2)
+	case "max_tokens":
+		return setInt(&g.MaxTokens, value, 1)
+	case "seed":
+		return setInt(&g.Seed, value, 0)
+	case "thinking_budget":
+		return setInt(&g.ThinkingBudget, value, 1)
+	case "enable_thinking":
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		g.EnableThinking = &b
+	case "stop":
+		var stop []string
+		for _, s := range strings.Split(value, ",") {
+			if s != "" {
+				stop = append(stop, s)
+			}
+		}
+		if len(stop) == 0 {
+			return fmt.Errorf("stop 不能为空")
+		}
+		g.Stop = stop
+	default:
+		return fmt.Errorf("未知参数: %s（可用: %s）", key, strings.Join(GenerationKeys, ", "))
+	}
+	return nil
+}
+
+// Unset 清除参数，恢复为未设置
+func (g *GenerationConfig) Unset(key string) error {
+	switch normalizeKey(key) {
+	case "temperature":
+		g.Temperature = nil
+	case "top_p":
+		g.TopP = nil
+	case "presence_penalty":
+		g.PresencePenalty = nil
+	case "max_tokens":
+		g.MaxTokens = nil
+	case "seed":
+		g.Seed = nil
+	case "thinking_budget":
+		g.ThinkingBudget = nil
+	case "enable_thinking":
+		g.EnableThinking = nil
+	case "stop":
+		g.Stop = nil
+	default:
+		return fmt.Errorf("未知参数: %s（可用: %s）", key, strings.Join(GenerationKeys, ", "))
+	}
+	return nil
+}
+
+// Values 以参数名为键返回已设置的参数
+func (g GenerationConfig) Values() map[string]string {
+	values := map[string]string{}
+	if g.Temperature != nil {
+		values["temperature"] = strconv.FormatFloat(*g.Temperature, 'g', -1, 64)
+	}
+	if g.TopP != nil {
+		values["top_p"] = strconv.FormatFloat(*g.TopP, 'g', -1, 64)
+	}
+	if g.MaxTokens != nil {
+		values["max_tokens"] = strconv.Itoa(*g.MaxTokens)
+	}
+	if g.Seed != nil {
+		values["seed"] = strconv.Itoa(*g.Seed)
+	}
+	if g.Stop != nil {
+		values["stop"] = strconv.Quote(strings.Join(g.Stop, ","))
+	}
+	if g.PresencePenalty != nil {
+		values["presence_penalty"] = strconv.FormatFloat(*g.PresencePenalty, 'g', -1, 64)
+	}
+	if g.EnableThinking != nil {
+		values["enable_thinking"] = strconv.FormatBool(*g.EnableThinking)
+	}
+	if g.ThinkingBudget != nil {
+		values["thinking_budget"] = strconv.Itoa(*g.ThinkingBudget)
+	}
+	return values
+}
+
+// String 返回 "key=value" 形式的参数列表，未设置任何参数时返回 "(默认)"
+func (g GenerationConfig) String() string {
+	values := g.Values()
+	if len(values) == 0 {
+		return "(默认)"
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+"="+values[key])
+	}
+	return strings.Join(parts, " ")
+}
+
+func setFloat(dst **float64, value string, min, max float64) error {
+	f, err := strconv.ParseFloat(value, 64)
+	// ParseFloat 接受 NaN 和 Inf，它们无法编码为 JSON
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("无效的数值: %s", value)
+	}
+	if f < min || f > max {
+		return fmt.Errorf("取值范围为 %g ~ %g", min, max)
+	}
+	*dst = &f
+	return nil
+}
+
+func setInt(dst **int, value string, min int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("无效的整数: %s", value)
+	}
+	if n < min {
+		return fmt.Errorf("取值不能小于 %d", min)
+	}
+	*dst = &n
+	return nil
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("无效的开关值: %s（可用 on/off）", value)
+}
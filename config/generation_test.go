@@ -0,0 +1,71 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerationSet(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       string // 设置成功后 String() 的结果，为空表示应当失败
+	}{
+		{"temperature", "0.7", "temperature=0.7"},
+		{"Temperature", " 2 ", "temperature=2"},
+		{"top-p", "0.9", "top_p=0.9"},
+		{"presence_penalty", "-2", "presence_penalty=-2"},
+		{"max_tokens", "1024", "max_tokens=1024"},
+		{"seed", "0", "seed=0"},
+		{"thinking_budget", "512", "thinking_budget=512"},
+		{"enable_thinking", "on", "enable_thinking=true"},
+		{"enable_thinking", "false", "enable_thinking=false"},
+		{"stop", "a,,b", `stop="a,b"`},
+
+		{"temperature", "2.1", ""},
+		{"temperature", "-0.1", ""},
+		{"temperature", "NaN", ""},
+		{"temperature", "nan", ""},
+		{"temperature", "Inf", ""},
+		{"top_p", "+Inf", ""},
+		{"presence_penalty", "-Inf", ""},
+		{"temperature", "abc", ""},
+		{"max_tokens", "0", ""},
+		{"max_tokens", "1.5", ""},
+		{"seed", "-1", ""},
+		{"enable_thinking", "maybe", ""},
+		{"stop", ",", ""},
+		{"unknown", "1", ""},
+	}
+	for _, tt := range tests {
+		var g GenerationConfig
+		err := g.Set(tt.key, tt.value)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Set(%q, %q) succeeded with %s, want an error", tt.key, tt.value, g)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Set(%q, %q) error: %v", tt.key, tt.value, err)
+			continue
+		}
+		if got := g.String(); got != tt.want {
+			t.Errorf("Set(%q, %q) = %s, want %s", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGenerationMerge(t *testing.T) {
+	var base, override GenerationConfig
+	base.Set("temperature", "0.5")
+	base.Set("seed", "1")
+	override.Set("temperature", "1")
+	override.Set("stop", "END")
+	got := base.Merge(override)
+	if want := `seed=1 stop="END" temperature=1`; got.String() != want {
+		t.Errorf("Merge = %s, want %s", got, want)
+	}
+	if !reflect.DeepEqual(base.Merge(GenerationConfig{}), base) {
+		t.Errorf("Merge with empty override changed the config")
+	}
+}
@@ -2,9 +2,11 @@ module Qwen-cli
 
 go 1.24.1
 
-require github.com/spf13/cobra v1.9.1
-
 require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
+	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
+	golang.org/x/sys v0.33.0
+	golang.org/x/term v0.32.0
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,106 @@
+package lineedit
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory 历史记录保留的最大条数
+const maxHistory = 1000
+
+// History 是保存在文件中的输入历史，每行一条，多行输入中的换行会被转义
+type History struct {
+	path    string
+	entries []string
+}
+
+// LoadHistory 读取历史文件，文件不存在时返回空历史。path 为空时不持久化
+func LoadHistory(path string) *History {
+	h := &History{path: path}
+	if path == "" {
+		return h
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, unescape(line))
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		h.rewrite()
+	}
+	return h
+}
+
+// Entries 返回所有历史记录，最旧的在前
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Add 追加一条历史记录，与上一条相同或为空时忽略
+func (h *History) Add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	if h.path == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(escape(line) + "\n")
+}
+
+// rewrite 用内存中的历史覆盖文件，用于截断过长的历史
+func (h *History) rewrite() {
+	var b strings.Builder
+	for _, entry := range h.entries {
+		b.WriteString(escape(entry) + "\n")
+	}
+	_ = os.WriteFile(h.path, []byte(b.String()), 0600)
+}
+
+func escape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
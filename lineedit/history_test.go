@@ -0,0 +1,68 @@
+package lineedit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	for _, s := range []string{"", "plain", "two\nlines", `back\slash`, `\n literal`, "trailing\\", "a\\\nb"} {
+		escaped := escape(s)
+		if strings.Contains(escaped, "\n") {
+			t.Errorf("escape(%q) = %q contains a newline", s, escaped)
+		}
+		if got := unescape(escaped); got != s {
+			t.Errorf("unescape(escape(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "history")
+	h := LoadHistory(path)
+	for _, line := range []string{"first", "", "  ", "second", "second", "multi\nline", "first"} {
+		h.Add(line)
+	}
+	want := []string{"first", "second", "multi\nline", "first"}
+	if got := h.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Entries = %q, want %q", got, want)
+	}
+	if got := LoadHistory(path).Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded Entries = %q, want %q", got, want)
+	}
+}
+
+func TestHistoryTrimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var b strings.Builder
+	for i := 0; i < maxHistory+10; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := LoadHistory(path).Entries()
+	if len(entries) != maxHistory || entries[0] != "line 10" {
+		t.Fatalf("loaded %d entries starting at %q", len(entries), entries[0])
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != maxHistory {
+		t.Errorf("history file has %d lines after trimming, want %d", lines, maxHistory)
+	}
+}
+
+func TestHistoryInMemory(t *testing.T) {
+	h := LoadHistory("")
+	h.Add("a")
+	if got := h.Entries(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Entries = %q", got)
+	}
+}
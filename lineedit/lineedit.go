@@ -0,0 +1,619 @@
+// Package lineedit 为交互式命令行提供行编辑：光标移动、多行输入、括号粘贴、
+// 持久化的历史记录、反向搜索和 Tab 补全。标准输入或输出不是终端时退化为按行读取。
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupt 表示用户在空行上按下了 Ctrl-C
+var ErrInterrupt = errors.New("interrupted")
+
+// errNoRaw 表示无法将终端切换到原始模式，调用方退回按行读取
+var errNoRaw = errors.New("raw mode unavailable")
+
+// MultilineDelimiter 包围多行输入的分隔符，以它开头的输入直到再次出现才会提交
+const MultilineDelimiter = `"""`
+
+// Editor 从终端读取一行（或多行）输入
+type Editor struct {
+	// Complete 返回以 line 为前缀的补全候选，每个候选都是完整的一行
+	Complete func(line string) []string
+
+	reader  *bufio.Reader
+	in      *os.File
+	out     *os.File
+	history *History
+}
+
+// New 创建编辑器。reader 与调用方共用，保证两者读取的输入不会错位；
+// historyPath 为空时历史记录只保存在内存中
+func New(reader *bufio.Reader, in io.Reader, historyPath string) *Editor {
+	e := &Editor{
+		reader:  reader,
+		out:     os.Stdout,
+		history: LoadHistory(historyPath),
+	}
+	if f, ok := in.(*os.File); ok {
+		e.in = f
+	}
+	return e
+}
+
+// interactive 判断是否可以使用行编辑
+func (e *Editor) interactive() bool {
+	return e.in != nil && term.IsTerminal(int(e.in.Fd())) && term.IsTerminal(int(e.out.Fd()))
+}
+
+// ReadLine 显示 prompt 并读取一次输入，多行输入返回分隔符之间的内容。
+// 输入结束时返回 io.EOF，空行上按 Ctrl-C 返回 ErrInterrupt
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.interactive() {
+		return e.readPlain(prompt)
+	}
+	line, err := e.edit(prompt, "")
+	if errors.Is(err, errNoRaw) {
+		return e.readPlain(prompt)
+	}
+	if err != nil {
+		return "", err
+	}
+	e.history.Add(line)
+	return unquote(line), nil
+}
+
+// Edit 显示 prompt 并以 initial 为初始内容编辑，返回编辑后的文本，不记入历史记录。
+// 非终端环境下读取一行输入，输入为空时返回 initial
+func (e *Editor) Edit(prompt, initial string) (string, error) {
+	var line string
+	err := errNoRaw
+	if e.interactive() {
+		line, err = e.edit(prompt, initial)
+	}
+	if errors.Is(err, errNoRaw) {
+		line, err = e.readPlain(prompt)
+		if err == nil && strings.TrimSpace(line) == "" {
+			return initial, nil
+		}
+	}
+	return line, err
+}
+
+// edit 在原始模式下运行一次编辑，光标位于 initial 末尾
+func (e *Editor) edit(prompt, initial string) (string, error) {
+	state, err := term.MakeRaw(int(e.in.Fd()))
+	if err != nil {
+		return "", errNoRaw
+	}
+	fmt.Fprint(e.out, "\x1b[?2004h")
+	defer func() {
+		fmt.Fprint(e.out, "\x1b[?2004l")
+		term.Restore(int(e.in.Fd()), state)
+	}()
+
+	s := &editState{
+		editor:       e,
+		prompt:       prompt,
+		continuation: continuationPrompt(prompt),
+		buf:          []rune(initial),
+		histIndex:    len(e.history.Entries()),
+	}
+	s.cursor = len(s.buf)
+	return s.run()
+}
+
+// readPlain 在非终端环境下按行读取，仍支持以分隔符包围的多行输入
+func (e *Editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", io.EOF
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if isOpenMultiline(line) {
+		for {
+			next, err := e.reader.ReadString('\n')
+			line += "\n" + strings.TrimRight(next, "\r\n")
+			if err != nil || !isOpenMultiline(line) {
+				break
+			}
+		}
+	}
+	return unquote(line), nil
+}
+
+// isOpenMultiline 判断输入是否以分隔符开头且尚未闭合
+func isOpenMultiline(text string) bool {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, MultilineDelimiter) {
+		return false
+	}
+	return len(text) < 2*len(MultilineDelimiter) || !strings.HasSuffix(text, MultilineDelimiter)
+}
+
+// unquote 去掉多行输入两端的分隔符
+func unquote(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, MultilineDelimiter) {
+		return text
+	}
+	trimmed = strings.TrimPrefix(trimmed, MultilineDelimiter)
+	if strings.HasSuffix(trimmed, MultilineDelimiter) {
+		trimmed = strings.TrimSuffix(trimmed, MultilineDelimiter)
+	}
+	return strings.Trim(trimmed, "\r\n")
+}
+
+// continuationPrompt 返回多行输入后续行的提示符，与 prompt 等宽
+func continuationPrompt(prompt string) string {
+	width := Width(prompt)
+	if width < 2 {
+		return ""
+	}
+	return strings.Repeat(" ", width-2) + "… "
+}
+
+// editState 是一次 ReadLine 的编辑状态
+type editState struct {
+	editor       *Editor
+	prompt       string
+	continuation string
+
+	buf    []rune
+	cursor int
+	// cursorRow 上次绘制后光标相对于输入首行的行数
+	cursorRow int
+
+	histIndex int
+	draft     []rune
+
+	searching   bool
+	query       []rune
+	searchIndex int
+	searchSaved []rune
+	searchFound bool
+
+	lastTab bool
+}
+
+func (s *editState) run() (string, error) {
+	s.render()
+	for {
+		r, _, err := s.editor.reader.ReadRune()
+		if err != nil {
+			s.finish()
+			if len(s.buf) > 0 {
+				return string(s.buf), nil
+			}
+			return "", io.EOF
+		}
+
+		tab := r == '\t'
+		if s.searching {
+			done, handled := s.searchKey(r)
+			if done {
+				s.finish()
+				return string(s.buf), nil
+			}
+			if handled {
+				s.render()
+				continue
+			}
+		}
+
+		switch r {
+		case '\r', '\n':
+			if isOpenMultiline(string(s.buf)) {
+				s.insert([]rune{'\n'})
+				break
+			}
+			s.finish()
+			return string(s.buf), nil
+		case 0x03: // Ctrl-C
+			if len(s.buf) == 0 {
+				s.finish()
+				return "", ErrInterrupt
+			}
+			s.cursor = len(s.buf)
+			s.render()
+			fmt.Fprint(s.editor.out, "^C\r\n")
+			s.buf, s.cursor, s.cursorRow = nil, 0, 0
+			s.histIndex = len(s.editor.history.Entries())
+		case 0x04: // Ctrl-D
+			if len(s.buf) == 0 {
+				s.finish()
+				return "", io.EOF
+			}
+			s.deleteForward()
+		case 0x01: // Ctrl-A
+			s.cursor = s.lineStart()
+		case 0x05: // Ctrl-E
+			s.cursor = s.lineEnd()
+		case 0x02: // Ctrl-B
+			s.move(-1)
+		case 0x06: // Ctrl-F
+			s.move(1)
+		case 0x08, 0x7f: // Backspace
+			if s.cursor > 0 {
+				s.buf = append(s.buf[:s.cursor-1], s.buf[s.cursor:]...)
+				s.cursor--
+			}
+		case 0x0b: // Ctrl-K
+			s.buf = append(s.buf[:s.cursor], s.buf[s.lineEnd():]...)
+		case 0x15: // Ctrl-U
+			start := s.lineStart()
+			s.buf = append(s.buf[:start], s.buf[s.cursor:]...)
+			s.cursor = start
+		case 0x17: // Ctrl-W
+			s.deleteWordBackward()
+		case 0x0c: // Ctrl-L
+			fmt.Fprint(s.editor.out, "\x1b[H\x1b[2J")
+			s.cursorRow = 0
+		case 0x10: // Ctrl-P
+			s.previous()
+		case 0x0e: // Ctrl-N
+			s.next()
+		case 0x12: // Ctrl-R
+			s.startSearch()
+		case '\t':
+			s.complete()
+		case 0x1b:
+			s.escape()
+		default:
+			if r >= 0x20 {
+				s.insert([]rune{r})
+			}
+		}
+		s.lastTab = tab
+		s.render()
+	}
+}
+
+// escape 处理以 ESC 开头的按键序列
+func (s *editState) escape() {
+	reader := s.editor.reader
+	// 单独按下的 ESC 后面不会紧跟其他字节
+	if reader.Buffered() == 0 {
+		return
+	}
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return
+	}
+	switch r {
+	case '\r', '\n': // Alt-Enter
+		s.insert([]rune{'\n'})
+	case 'b', 'B':
+		s.cursor = s.wordLeft()
+	case 'f', 'F':
+		s.cursor = s.wordRight()
+	case 0x7f:
+		s.deleteWordBackward()
+	case 'O':
+		final, _, _ := reader.ReadRune()
+		s.cursorKey(string(final))
+	case '[':
+		var seq strings.Builder
+		for {
+			c, _, err := reader.ReadRune()
+			if err != nil {
+				return
+			}
+			seq.WriteRune(c)
+			if c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+		if seq.String() == "200~" {
+			s.paste()
+			return
+		}
+		s.cursorKey(seq.String())
+	}
+}
+
+// cursorKey 处理 CSI/SS3 序列表示的方向键和编辑键
+func (s *editState) cursorKey(seq string) {
+	switch seq {
+	case "A":
+		s.up()
+	case "B":
+		s.down()
+	case "C":
+		s.move(1)
+	case "D":
+		s.move(-1)
+	case "H", "1~", "7~":
+		s.cursor = s.lineStart()
+	case "F", "4~", "8~":
+		s.cursor = s.lineEnd()
+	case "3~":
+		s.deleteForward()
+	case "1;5C", "1;3C":
+		s.cursor = s.wordRight()
+	case "1;5D", "1;3D":
+		s.cursor = s.wordLeft()
+	}
+}
+
+// paste 读取括号粘贴的内容直到结束标记，换行原样插入而不会提交
+func (s *editState) paste() {
+	const end = "\x1b[201~"
+	var text []rune
+	for {
+		r, _, err := s.editor.reader.ReadRune()
+		if err != nil {
+			break
+		}
+		text = append(text, r)
+		if strings.HasSuffix(string(text[max(0, len(text)-len(end)):]), end) {
+			text = text[:len(text)-len(end)]
+			break
+		}
+	}
+	pasted := strings.ReplaceAll(string(text), "\r\n", "\n")
+	pasted = strings.ReplaceAll(pasted, "\r", "\n")
+	s.insert([]rune(pasted))
+}
+
+func (s *editState) insert(text []rune) {
+	buf := make([]rune, 0, len(s.buf)+len(text))
+	buf = append(buf, s.buf[:s.cursor]...)
+	buf = append(buf, text...)
+	buf = append(buf, s.buf[s.cursor:]...)
+	s.buf = buf
+	s.cursor += len(text)
+}
+
+func (s *editState) move(delta int) {
+	s.cursor = min(max(s.cursor+delta, 0), len(s.buf))
+}
+
+func (s *editState) deleteForward() {
+	if s.cursor < len(s.buf) {
+		s.buf = append(s.buf[:s.cursor], s.buf[s.cursor+1:]...)
+	}
+}
+
+func (s *editState) deleteWordBackward() {
+	start := s.wordLeft()
+	s.buf = append(s.buf[:start], s.buf[s.cursor:]...)
+	s.cursor = start
+}
+
+// lineStart 返回光标所在行的起始位置
+func (s *editState) lineStart() int {
+	i := s.cursor
+	for i > 0 && s.buf[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+// lineEnd 返回光标所在行的结束位置
+func (s *editState) lineEnd() int {
+	i := s.cursor
+	for i < len(s.buf) && s.buf[i] != '\n' {
+		i++
+	}
+	return i
+}
+
+func (s *editState) wordLeft() int {
+	i := s.cursor
+	for i > 0 && isSpace(s.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !isSpace(s.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (s *editState) wordRight() int {
+	i := s.cursor
+	for i < len(s.buf) && isSpace(s.buf[i]) {
+		i++
+	}
+	for i < len(s.buf) && !isSpace(s.buf[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n'
+}
+
+// up 在多行输入中移到上一行，已在首行时切换到上一条历史记录
+func (s *editState) up() {
+	start := s.lineStart()
+	if start == 0 {
+		s.previous()
+		return
+	}
+	column := s.cursor - start
+	s.cursor = start - 1
+	prev := s.lineStart()
+	s.cursor = prev + min(column, start-1-prev)
+}
+
+// down 在多行输入中移到下一行，已在末行时切换到下一条历史记录
+func (s *editState) down() {
+	end := s.lineEnd()
+	if end == len(s.buf) {
+		s.next()
+		return
+	}
+	column := s.cursor - s.lineStart()
+	s.cursor = end + 1
+	s.cursor = min(end+1+column, s.lineEnd())
+}
+
+func (s *editState) previous() {
+	entries := s.editor.history.Entries()
+	if s.histIndex == 0 {
+		return
+	}
+	if s.histIndex == len(entries) {
+		s.draft = append([]rune(nil), s.buf...)
+	}
+	s.histIndex--
+	s.buf = []rune(entries[s.histIndex])
+	s.cursor = len(s.buf)
+}
+
+func (s *editState) next() {
+	entries := s.editor.history.Entries()
+	if s.histIndex >= len(entries) {
+		return
+	}
+	s.histIndex++
+	if s.histIndex == len(entries) {
+		s.buf = append([]rune(nil), s.draft...)
+	} else {
+		s.buf = []rune(entries[s.histIndex])
+	}
+	s.cursor = len(s.buf)
+}
+
+// complete 补全光标前的内容：唯一候选直接补全，多个候选先补全公共前缀，连按两次 Tab 列出所有候选
+func (s *editState) complete() {
+	if s.editor.Complete == nil {
+		return
+	}
+	line := string(s.buf[:s.cursor])
+	candidates := s.editor.Complete(line)
+	switch len(candidates) {
+	case 0:
+		fmt.Fprint(s.editor.out, "\a")
+		return
+	case 1:
+		s.replacePrefix(candidates[0] + " ")
+		return
+	}
+
+	prefix := commonPrefix(candidates)
+	if len([]rune(prefix)) > s.cursor {
+		s.replacePrefix(prefix)
+		return
+	}
+	if !s.lastTab {
+		fmt.Fprint(s.editor.out, "\a")
+		return
+	}
+
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c[strings.LastIndex(c, " ")+1:]
+	}
+	saved := s.cursor
+	s.cursor = len(s.buf)
+	s.render()
+	fmt.Fprint(s.editor.out, "\r\n"+strings.Join(names, "  ")+"\r\n")
+	s.cursor, s.cursorRow = saved, 0
+}
+
+// replacePrefix 用 text 替换光标前的内容
+func (s *editState) replacePrefix(text string) {
+	rest := s.buf[s.cursor:]
+	s.buf = append([]rune(text), rest...)
+	s.cursor = len([]rune(text))
+}
+
+func commonPrefix(values []string) string {
+	prefix := []rune(values[0])
+	for _, v := range values[1:] {
+		r := []rune(v)
+		n := 0
+		for n < len(prefix) && n < len(r) && prefix[n] == r[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
+// startSearch 进入反向搜索模式
+func (s *editState) startSearch() {
+	s.searching = true
+	s.query = nil
+	s.searchSaved = append([]rune(nil), s.buf...)
+	s.searchIndex = len(s.editor.history.Entries())
+	s.searchFound = true
+}
+
+// search 从 from 开始向前查找包含搜索词的历史记录
+func (s *editState) search(from int) {
+	entries := s.editor.history.Entries()
+	query := string(s.query)
+	for i := min(from, len(entries)-1); i >= 0; i-- {
+		if j := strings.Index(entries[i], query); j >= 0 {
+			s.searchIndex = i
+			s.buf = []rune(entries[i])
+			s.cursor = len([]rune(entries[i][:j]))
+			s.searchFound = true
+			return
+		}
+	}
+	s.searchFound = false
+}
+
+// searchKey 处理反向搜索模式下的按键。done 表示应提交当前匹配，
+// handled 为 false 时退出搜索模式并按普通按键继续处理
+func (s *editState) searchKey(r rune) (done, handled bool) {
+	switch r {
+	case '\r', '\n':
+		s.searching = false
+		return true, true
+	case 0x12: // Ctrl-R 查找更早的匹配
+		if len(s.query) > 0 {
+			s.search(s.searchIndex - 1)
+		}
+		return false, true
+	case 0x08, 0x7f:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+		}
+		if len(s.query) == 0 {
+			s.buf = append([]rune(nil), s.searchSaved...)
+			s.cursor, s.searchFound = len(s.buf), true
+		} else {
+			s.search(len(s.editor.history.Entries()) - 1)
+		}
+		return false, true
+	case 0x07, 0x03: // Ctrl-G、Ctrl-C 取消搜索
+		s.searching = false
+		s.buf = s.searchSaved
+		s.cursor = len(s.buf)
+		return false, true
+	case 0x1b:
+		if s.editor.reader.Buffered() == 0 {
+			s.searching = false
+			return false, true
+		}
+	}
+	if r >= 0x20 {
+		s.query = append(s.query, r)
+		s.search(s.searchIndex)
+		return false, true
+	}
+	s.searching = false
+	return false, false
+}
+
+// finish 将光标移到输入末尾并换行
+func (s *editState) finish() {
+	s.searching = false
+	s.cursor = len(s.buf)
+	s.render()
+	fmt.Fprint(s.editor.out, "\r\n")
+	s.cursorRow = 0
+}
@@ -0,0 +1,190 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWidth(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"💬 你: ", 7},
+		{"你好", 4},
+		{"ｈｉ", 4},
+		{"한국", 4},
+		{"é", 1},
+		{"👍🏻", 4},
+		{"❤️", 1},
+		{"a\tb\x1b", 2},
+	}
+	for _, tt := range tests {
+		if got := Width(tt.text); got != tt.want {
+			t.Errorf("Width(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	tests := []struct {
+		text    string
+		open    bool
+		unquote string
+	}{
+		{"hello", false, "hello"},
+		{`"""`, true, ""},
+		{`  """`, true, ""},
+		{"\"\"\"\nline one", true, "line one"},
+		{"\"\"\"\nline one\nline two\n\"\"\"", false, "line one\nline two"},
+		{`"""inline"""`, false, "inline"},
+		{`""""`, true, `"`},
+		{`say """hi"""`, false, `say """hi"""`},
+	}
+	for _, tt := range tests {
+		if got := isOpenMultiline(tt.text); got != tt.open {
+			t.Errorf("isOpenMultiline(%q) = %v, want %v", tt.text, got, tt.open)
+		}
+		if got := unquote(tt.text); got != tt.unquote {
+			t.Errorf("unquote(%q) = %q, want %q", tt.text, got, tt.unquote)
+		}
+	}
+}
+
+func TestContinuationPrompt(t *testing.T) {
+	tests := []struct{ prompt, want string }{
+		{"", ""},
+		{">", ""},
+		{"> ", "… "},
+		{"💬 你: ", "     … "},
+	}
+	for _, tt := range tests {
+		if got := continuationPrompt(tt.prompt); got != tt.want {
+			t.Errorf("continuationPrompt(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{[]string{"/help"}, "/help"},
+		{[]string{"/save", "/sessions", "/search"}, "/s"},
+		{[]string{"/session", "/sessions"}, "/session"},
+		{[]string{"你好", "你们"}, "你"},
+		{[]string{"a", "b"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonPrefix(tt.values); got != tt.want {
+			t.Errorf("commonPrefix(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+// runKeys 将 keys 作为终端输入运行一次编辑，输出写入空设备
+func runKeys(t *testing.T, keys string, history []string, complete func(string) []string) (string, error) {
+	t.Helper()
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	e := &Editor{
+		Complete: complete,
+		reader:   bufio.NewReader(strings.NewReader(keys)),
+		out:      out,
+		history:  LoadHistory(""),
+	}
+	for _, line := range history {
+		e.history.Add(line)
+	}
+	s := &editState{editor: e, prompt: "> ", continuation: continuationPrompt("> "), histIndex: len(history)}
+	return s.run()
+}
+
+func TestEditKeys(t *testing.T) {
+	history := []string{"git status", "docker ps", "git log --oneline"}
+	complete := func(line string) []string {
+		var out []string
+		for _, c := range []string{"/save", "/sessions", "/search", "/help"} {
+			if strings.HasPrefix(c, line) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"type and enter", "hello\r", "hello"},
+		{"backspace", "helo\x7f\x7fllo\r", "hello"},
+		{"ctrl-a and ctrl-e", "world\x01hello \x05!\r", "hello world!"},
+		{"arrow keys", "hllo\x1b[D\x1b[D\x1b[D\x1b[C\x1b[De\r", "hello"},
+		{"ss3 arrow keys", "ac\x1bODb\r", "abc"},
+		{"home and delete", "xhello\x1b[H\x1b[3~\r", "hello"},
+		{"ctrl-k", "hello world\x01\x1bf\x0b\r", "hello"},
+		{"ctrl-u", "hello world\x15bye\r", "bye"},
+		{"ctrl-w", "hello big world\x17\x17there\r", "hello there"},
+		{"alt-backspace", "one two\x1b\x7fthree\r", "one three"},
+		{"word movement", "one two three\x1bb\x1bb\x1b[1;5Cx\r", "one twox three"},
+		{"ctrl-d deletes forward", "abc\x01\x04\r", "bc"},
+		{"unicode", "你好\x7f们\r", "你们"},
+		{"history up", "\x1b[A\x1b[A\r", "docker ps"},
+		{"history keeps draft", "draft\x10\x10\x0e\x0e\r", "draft"},
+		{"history stops at oldest", "\x10\x10\x10\x10\x10\r", "git status"},
+		{"reverse search", "\x12git\r", "git log --oneline"},
+		{"reverse search older match", "\x12git\x12\r", "git status"},
+		{"reverse search backspace", "\x12dock\x7f\x7f\x7f\x7fgit s\r", "git status"},
+		{"reverse search cancel", "typed\x12git\x07\r", "typed"},
+		{"reverse search then edit", "\x12docker\x05 -a\r", "docker ps -a"},
+		{"alt-enter inserts newline", "a\x1b\rb\r", "a\nb"},
+		{"multiline delimiter", "\"\"\"\rline one\rline two\r\"\"\"\r", "\"\"\"\nline one\nline two\n\"\"\""},
+		{"multiline up and down", "\"\"\"\rabc\rxy\x1b[A\x1b[Az\x1b[B\x1b[B\"\"\"\r", "\"\"z\"\nabc\nxy\"\"\""},
+		{"bracketed paste", "\x1b[200~line1\r\nline2\x1b[201~\r", "line1\nline2"},
+		{"ctrl-c clears line", "discard\x03kept\r", "kept"},
+		{"tab completes unique", "/he\t\r", "/help "},
+		{"tab completes common prefix", "/se\t\r", "/se"},
+		{"tab completes longer prefix", "/sa\t\r", "/save "},
+		{"tab with no candidates", "xyz\t\r", "xyz"},
+		{"eof returns buffer", "partial", "partial"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runKeys(t, tt.keys, history, complete)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditInterruptAndEOF(t *testing.T) {
+	tests := []struct {
+		keys string
+		want error
+	}{
+		{"\x03", ErrInterrupt},
+		{"\x04", io.EOF},
+		{"", io.EOF},
+		{"abc\x15\x04", io.EOF},
+	}
+	for _, tt := range tests {
+		if _, err := runKeys(t, tt.keys, nil, nil); !errors.Is(err, tt.want) {
+			t.Errorf("keys %q: error = %v, want %v", tt.keys, err, tt.want)
+		}
+	}
+}
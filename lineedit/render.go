@@ -0,0 +1,124 @@
+package lineedit
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// render 重绘提示符和整个输入缓冲区，并把光标放回编辑位置。
+// 自动换行由这里显式完成，以便准确计算光标所在的行和列
+func (s *editState) render() {
+	out := s.editor.out
+	width := 80
+	if w, _, err := term.GetSize(int(out.Fd())); err == nil && w > 0 {
+		width = w
+	}
+
+	var b strings.Builder
+	b.WriteString("\r")
+	if s.cursorRow > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", s.cursorRow)
+	}
+	b.WriteString("\x1b[J")
+
+	prompt := s.prompt
+	if s.searching {
+		if s.searchFound {
+			prompt = fmt.Sprintf("(历史搜索 '%s'): ", string(s.query))
+		} else {
+			prompt = fmt.Sprintf("(未找到 '%s'): ", string(s.query))
+		}
+	}
+
+	row, col := 0, 0
+	curRow, curCol := 0, 0
+	put := func(text string) {
+		for _, r := range text {
+			w := runeWidth(r)
+			if col+w > width {
+				b.WriteString("\r\n")
+				row, col = row+1, 0
+			}
+			b.WriteRune(r)
+			col += w
+		}
+	}
+
+	put(prompt)
+	for i, r := range s.buf {
+		if i == s.cursor {
+			if col >= width {
+				b.WriteString("\r\n")
+				row, col = row+1, 0
+			}
+			curRow, curCol = row, col
+		}
+		if r == '\n' {
+			b.WriteString("\r\n")
+			row, col = row+1, 0
+			put(s.continuation)
+			continue
+		}
+		if r == '\t' {
+			r = ' '
+		}
+		put(string(r))
+	}
+	// 恰好写满一行时终端光标停在行尾，换到下一行使位置确定
+	if col >= width {
+		b.WriteString("\r\n")
+		row, col = row+1, 0
+	}
+	if s.cursor >= len(s.buf) {
+		curRow, curCol = row, col
+	}
+
+	if row > curRow {
+		fmt.Fprintf(&b, "\x1b[%dA", row-curRow)
+	}
+	b.WriteString("\r")
+	if curCol > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", curCol)
+	}
+	s.cursorRow = curRow
+	fmt.Fprint(out, b.String())
+}
+
+// Width 返回文本在终端中占用的列数
+func Width(text string) int {
+	width := 0
+	for _, r := range text {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth 返回字符的显示宽度：控制字符和组合字符为 0，中日韩文字、全角符号和 emoji 为 2
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r == 0x7f:
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.Is(unicode.Mn, r) || r == 0x200d || (r >= 0xfe00 && r <= 0xfe0f):
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	case r == utf8.RuneError:
+		return 1
+	}
+	return 1
+}
@@ -0,0 +1,782 @@
+package safety
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Level 是命令的风险等级
+type Level int
+
+const (
+	Low    Level = iota // 未发现风险
+	Medium              // 会修改或删除数据，但影响范围有限
+	High                // 可能造成难以恢复的破坏，如删除关键路径、提权、下载后直接执行
+)
+
+func (l Level) String() string {
+	switch l {
+	case Medium:
+		return "medium"
+	case High:
+		return "high"
+	}
+	return "low"
+}
+
+// ParseLevel 解析 low、medium、high
+func ParseLevel(s string) (Level, error) {
+	for _, l := range []Level{Low, Medium, High} {
+		if s == l.String() {
+			return l, nil
+		}
+	}
+	return Low, fmt.Errorf("未知的风险等级 %q，可选 low、medium、high", s)
+}
+
+// Finding 是分析发现的一项风险
+type Finding struct {
+	Level  Level
+	Reason string
+}
+
+// Analysis 是一条命令的分析结果
+type Analysis struct {
+	Level    Level // 所有风险中最高的等级
+	Findings []Finding
+	// Commands 是命令中所有简单命令的参数（去掉 sudo、env 等前缀，包括命令替换中的命令），
+	// 用于匹配策略规则
+	Commands [][]string
+}
+
+// maxDepth 限制 sh -c、eval、命令替换等嵌套脚本的分析深度
+const maxDepth = 4
+
+// Analyze 分析命令行的风险
+func Analyze(line string) Analysis {
+	a := &analyzer{seen: map[string]bool{}}
+	a.script(line, 0)
+	return a.result
+}
+
+type analyzer struct {
+	result Analysis
+	seen   map[string]bool
+}
+
+func (a *analyzer) add(level Level, format string, args ...any) {
+	reason := fmt.Sprintf(format, args...)
+	if a.seen[reason] {
+		return
+	}
+	a.seen[reason] = true
+	a.result.Findings = append(a.result.Findings, Finding{Level: level, Reason: reason})
+	a.result.Level = max(a.result.Level, level)
+}
+
+// script 分析一段脚本，depth 为嵌套深度
+func (a *analyzer) script(line string, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	commands, err := Parse(line)
+	if err != nil {
+		a.add(Medium, "命令语法不完整（%s），无法完整分析", err)
+	}
+	if name := forkBomb(line); name != "" {
+		a.add(High, "fork 炸弹（函数 %s 不断复制自身）", name)
+	}
+
+	downloaders := map[int]string{}   // 管道编号 -> 管道中已出现的下载命令
+	piped := map[int][]string{}       // 管道编号 -> 管道中 echo、here-document 等输出的文本
+	downloaded := map[string]string{} // 下载命令写入的文件 -> 下载命令，用于发现先下载再执行
+	for _, c := range commands {
+		for _, sub := range c.Substitutions {
+			a.script(sub, depth+1)
+		}
+		for _, r := range c.Redirects {
+			a.redirect(r)
+		}
+
+		args, elevated := unwrap(c.Args)
+		if elevated != "" {
+			a.add(High, "以管理员权限运行（%s）", elevated)
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if strings.ContainsAny(args[0], "$`") {
+			a.add(Medium, "命令名由变量或命令替换决定（%s），无法分析实际执行的命令", args[0])
+		}
+		name := commandName(args[0])
+
+		if downloader[name] {
+			downloaders[c.Pipeline] = name
+			for _, file := range downloadedFiles(name, args[1:], c.Redirects) {
+				downloaded[cleanPath(file)] = name
+			}
+		}
+		if d, ok := downloaded[cleanPath(args[0])]; ok && strings.ContainsAny(args[0], `/\`) {
+			a.add(High, "直接执行 %s 下载的文件 %s", d, args[0])
+		}
+		if interpreter[name] || name == "source" || name == "." {
+			for _, arg := range operands(args[1:]) {
+				if d, ok := downloaded[cleanPath(arg)]; ok {
+					a.add(High, "%s 下载的文件 %s 交给 %s 执行", d, arg, name)
+				}
+			}
+		}
+		if interpreter[name] {
+			if d, ok := downloaders[c.Pipeline]; ok {
+				a.add(High, "%s 下载的内容直接交给 %s 执行", d, name)
+			}
+			for _, sub := range c.Substitutions {
+				if d := findDownloader(sub); d != "" {
+					a.add(High, "%s 下载的内容直接交给 %s 执行", d, name)
+				}
+			}
+			if script, ok := inlineScript(name, args); ok {
+				a.script(script, depth+1)
+			} else if len(operands(args[1:])) == 0 {
+				// 没有脚本文件时从标准输入读取脚本，如 sh <<EOF、echo ... | sh
+				for _, input := range append(piped[c.Pipeline], c.Input...) {
+					a.script(input, depth+1)
+				}
+			}
+		}
+		piped[c.Pipeline] = append(piped[c.Pipeline], c.Input...)
+		if name == "echo" || name == "printf" {
+			piped[c.Pipeline] = append(piped[c.Pipeline], strings.Join(operands(args[1:]), " "))
+		}
+		a.command(name, args, depth)
+	}
+}
+
+// command 按命令名检查一条简单命令
+func (a *analyzer) command(name string, args []string, depth int) {
+	a.result.Commands = append(a.result.Commands, args)
+	flags, targets := splitFlags(args[1:])
+
+	switch name {
+	case "rm":
+		recursive := flags.has("r", "R", "recursive")
+		if flags.has("no-preserve-root") {
+			a.add(High, "rm --no-preserve-root 会删除根目录")
+		}
+		critical := false
+		for _, t := range targets {
+			switch {
+			case isCritical(t):
+				a.add(High, "删除关键路径 %s", t)
+				critical = true
+			case isSystemPath(t):
+				a.add(High, "删除系统文件 %s", t)
+				critical = true
+			case recursive && emptyVarPath.MatchString(t):
+				a.add(High, "变量为空时会删除根目录下的内容（%s）", t)
+				critical = true
+			}
+		}
+		switch {
+		case critical:
+		case recursive:
+			a.add(Medium, "递归删除 %s", describe(targets))
+		default:
+			a.add(Medium, "删除文件 %s", describe(targets))
+		}
+	case "shred", "wipefs", "mkswap", "fdisk", "sfdisk", "cfdisk", "gdisk", "sgdisk", "parted", "diskpart", "format":
+		a.add(High, "擦除或改写磁盘（%s）", name)
+	case "diskutil":
+		if len(targets) > 0 && (strings.HasPrefix(targets[0], "erase") || strings.HasPrefix(targets[0], "partition")) {
+			a.add(High, "擦除或改写磁盘（diskutil %s）", targets[0])
+		}
+	case "dd":
+		for _, arg := range args[1:] {
+			if of, ok := strings.CutPrefix(arg, "of="); ok {
+				if isDevice(of) {
+					a.add(High, "dd 写入磁盘设备 %s", of)
+				} else {
+					a.add(Medium, "dd 覆盖文件 %s", of)
+				}
+			}
+		}
+	case "git":
+		a.git(args[1:])
+	case "chmod", "chown", "chgrp":
+		recursive := flags.has("R", "recursive")
+		// 第一个操作数是权限或属主
+		for _, t := range targets[min(1, len(targets)):] {
+			switch {
+			case isSystemPath(t) || (recursive && isCritical(t) && !isRelative(t)):
+				a.add(High, "修改关键路径的权限或属主（%s %s）", name, t)
+			case recursive:
+				a.add(Medium, "递归修改权限或属主（%s -R %s）", name, t)
+			}
+		}
+	case "mv":
+		for i, t := range targets {
+			if i < len(targets)-1 && (isCritical(t) || isSystemPath(t)) {
+				a.add(High, "移动关键路径 %s", t)
+			}
+		}
+		if len(targets) > 1 && targets[len(targets)-1] == "/dev/null" {
+			a.add(High, "把文件移动到 /dev/null 会丢失文件")
+		}
+	case "shutdown", "reboot", "halt", "poweroff":
+		a.add(High, "关机或重启系统（%s）", name)
+	case "init", "telinit":
+		if len(targets) > 0 && (targets[0] == "0" || targets[0] == "6") {
+			a.add(High, "关机或重启系统（%s %s）", name, targets[0])
+		}
+	case "systemctl":
+		if len(targets) > 0 {
+			switch targets[0] {
+			case "poweroff", "reboot", "halt", "kexec":
+				a.add(High, "关机或重启系统（systemctl %s）", targets[0])
+			case "stop", "disable", "mask", "kill":
+				a.add(Medium, "停止或禁用系统服务（systemctl %s）", targets[0])
+			}
+		}
+	case "kill":
+		for _, t := range append(targets, flags.names...) {
+			if t == "-1" || t == "1" {
+				a.add(High, "终止所有进程或 init 进程（kill %s）", t)
+			}
+		}
+	case "killall", "pkill":
+		a.add(Medium, "按名称批量终止进程（%s）", name)
+	case "crontab":
+		if flags.has("r") {
+			a.add(High, "删除所有定时任务（crontab -r）")
+		}
+	case "truncate":
+		// -s、-r 的参数是大小和参考文件，不是被截断的文件
+		_, files := splitFlags(skipValues(args[1:], "-s", "--size", "-r", "--reference"))
+		for _, t := range files {
+			if isSystemPath(t) {
+				a.add(High, "截断系统文件 %s", t)
+			}
+		}
+		a.add(Medium, "截断文件 %s", describe(files))
+	case "find":
+		a.find(args[1:], depth)
+	case "eval":
+		a.add(Medium, "动态执行字符串（eval）")
+		a.script(strings.Join(args[1:], " "), depth+1)
+	case "iptables", "ip6tables":
+		if flags.has("F", "flush", "X") {
+			a.add(Medium, "清空防火墙规则（%s）", name)
+		}
+	case "userdel", "deluser", "groupdel":
+		a.add(Medium, "删除系统用户或用户组（%s）", name)
+	case "docker", "podman":
+		// docker rm、docker rmi、docker system prune、docker volume rm 等
+		sub := strings.Join(targets[:min(2, len(targets))], " ")
+		if len(targets) > 0 && (targets[0] == "rm" || targets[0] == "rmi" || strings.HasSuffix(sub, "prune") || strings.HasSuffix(sub, " rm")) {
+			a.add(Medium, "删除容器、镜像或数据卷（%s %s）", name, sub)
+		}
+	case "kubectl":
+		if len(targets) > 0 && targets[0] == "delete" {
+			a.add(Medium, "删除 Kubernetes 资源（kubectl delete）")
+		}
+	case "del", "erase", "remove-item", "ri":
+		a.add(Medium, "删除文件 %s", describe(targets))
+	case "rd":
+		for _, arg := range args[1:] {
+			if strings.EqualFold(arg, "/s") {
+				a.add(Medium, "递归删除目录（rd /s）")
+			}
+		}
+	case "reg":
+		if len(targets) > 0 && strings.EqualFold(targets[0], "delete") {
+			a.add(Medium, "删除注册表项（reg delete）")
+		}
+	default:
+		if strings.HasPrefix(name, "mkfs") {
+			a.add(High, "格式化磁盘（%s）", name)
+		}
+	}
+}
+
+// git 检查 git 子命令
+func (a *analyzer) git(args []string) {
+	// 跳过 -C <路径>、-c <配置> 等全局选项
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args[0] == "-C" || args[0] == "-c" {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return
+	}
+	flags, targets := splitFlags(args[1:])
+	switch args[0] {
+	case "push":
+		if flags.has("f", "force", "force-with-lease", "force-if-includes", "mirror") {
+			a.add(High, "强制推送会覆盖远程历史（git push --force）")
+		}
+		for _, t := range targets {
+			if strings.HasPrefix(t, "+") {
+				a.add(High, "强制推送会覆盖远程历史（git push %s）", t)
+			}
+			if strings.HasPrefix(t, ":") && len(t) > 1 {
+				a.add(High, "删除远程分支（git push %s）", t)
+			}
+		}
+		if flags.has("d", "delete") {
+			a.add(High, "删除远程分支（git push --delete）")
+		}
+	case "reset":
+		if flags.has("hard") {
+			a.add(Medium, "丢弃未提交的修改（git reset --hard）")
+		}
+	case "clean":
+		if flags.has("f", "force") {
+			a.add(Medium, "删除未跟踪的文件（git clean）")
+		}
+	case "branch":
+		if flags.has("D") {
+			a.add(Medium, "强制删除分支（git branch -D）")
+		}
+	case "checkout", "restore":
+		if flags.has("f", "force") || (len(targets) > 0 && targets[len(targets)-1] == ".") {
+			a.add(Medium, "丢弃工作区的修改（git %s）", args[0])
+		}
+	case "stash":
+		if len(targets) > 0 && (targets[0] == "clear" || targets[0] == "drop") {
+			a.add(Medium, "删除暂存的修改（git stash %s）", targets[0])
+		}
+	case "filter-branch", "filter-repo":
+		a.add(High, "改写仓库历史（git %s）", args[0])
+	}
+}
+
+// find 检查 find 的 -delete 和 -exec 中执行的命令。起始路径是关键路径或系统目录时，
+// -exec 中的 {} 按起始路径分析，如 find / -exec rm -rf {} + 相当于 rm -rf /
+func (a *analyzer) find(args []string, depth int) {
+	// 第一个表达式之前的参数是起始路径，-H、-L、-P 等选项除外
+	var roots []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") || arg == "(" || arg == "!" {
+			if arg == "-H" || arg == "-L" || arg == "-P" {
+				continue
+			}
+			break
+		}
+		if isSystemPath(arg) || (isCritical(arg) && !isRelative(arg)) {
+			roots = append(roots, arg)
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-delete":
+			if len(roots) > 0 {
+				a.add(High, "删除关键路径下 find 匹配的文件（find %s -delete）", strings.Join(roots, " "))
+			} else {
+				a.add(Medium, "删除 find 匹配的文件（find -delete）")
+			}
+		case "-exec", "-execdir", "-ok", "-okdir":
+			end := i + 1
+			for end < len(args) && args[end] != ";" && args[end] != "+" {
+				end++
+			}
+			sub := args[i+1 : end]
+			i = end
+			if len(sub) == 0 {
+				continue
+			}
+			if len(roots) == 0 {
+				a.command(commandName(sub[0]), sub, depth+1)
+				continue
+			}
+			for _, root := range roots {
+				expanded := make([]string, len(sub))
+				for j, arg := range sub {
+					expanded[j] = strings.ReplaceAll(arg, "{}", root)
+				}
+				a.command(commandName(expanded[0]), expanded, depth+1)
+			}
+		}
+	}
+}
+
+// redirect 检查写入磁盘设备、系统文件和覆盖已有文件的重定向
+func (a *analyzer) redirect(r Redirect) {
+	switch r.Op {
+	case ">", ">>", ">|", "&>", "&>>", ">&", "<>":
+	default:
+		return
+	}
+	t := r.Target
+	if t == "" || t == "-" || strings.Trim(t, "0123456789") == "" || harmlessDevices[t] || strings.HasPrefix(t, "/dev/fd/") {
+		return
+	}
+	switch {
+	case isDevice(t):
+		a.add(High, "写入磁盘设备 %s", t)
+	case isSystemPath(t):
+		a.add(High, "写入系统文件 %s", t)
+	case r.Op != ">>" && r.Op != "&>>" && r.Op != "<>" && exists(t):
+		a.add(Medium, "覆盖已有文件 %s", t)
+	}
+}
+
+// wrappers 是运行其他命令的前缀命令，值为需要跳过的带参数选项
+var wrappers = map[string][]string{
+	"sudo":    {"-u", "-g", "-p", "-C", "-D", "-h", "-r", "-t", "-T", "-U"},
+	"doas":    {"-u", "-C"},
+	"env":     {"-u", "-C", "-S"},
+	"nohup":   nil,
+	"time":    nil,
+	"exec":    {"-a"},
+	"command": nil,
+	"builtin": nil,
+	"nice":    {"-n"},
+	"ionice":  {"-c", "-n", "-p"},
+	"stdbuf":  {"-i", "-o", "-e"},
+	"timeout": {"-s", "-k"},
+	"xargs":   {"-I", "-n", "-P", "-d", "-L", "-s", "-E", "-a"},
+	"watch":   {"-n", "-d"},
+	"strace":  {"-o", "-e", "-p"},
+	// busybox、toybox 的第一个参数是要运行的命令
+	"busybox": nil,
+	"toybox":  nil,
+}
+
+// unwrap 去掉 sudo、env、xargs 等前缀，返回实际执行的命令，
+// 通过 sudo、doas 或 su 提权时 elevated 为提权命令
+func unwrap(args []string) (cmd []string, elevated string) {
+	for len(args) > 0 {
+		name := commandName(args[0])
+		if name == "su" {
+			elevated = name
+			for i, arg := range args {
+				if (arg == "-c" || arg == "--command") && i+1 < len(args) {
+					return []string{"sh", "-c", args[i+1]}, elevated
+				}
+			}
+			return nil, elevated
+		}
+		valueOpts, ok := wrappers[name]
+		if !ok {
+			return args, elevated
+		}
+		if name == "sudo" || name == "doas" {
+			elevated = name
+		}
+		args = args[1:]
+		for len(args) > 0 && (strings.HasPrefix(args[0], "-") || (name == "env" && isAssignment(args[0]))) {
+			if args[0] == "--" {
+				args = args[1:]
+				break
+			}
+			for _, opt := range valueOpts {
+				if args[0] == opt {
+					args = args[1:]
+					break
+				}
+			}
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		}
+		if name == "timeout" && len(args) > 0 {
+			args = args[1:] // 时长
+		}
+		if name == "nice" && len(args) > 0 && strings.Trim(args[0], "-0123456789") == "" {
+			args = args[1:]
+		}
+	}
+	return args, elevated
+}
+
+// downloadedFiles 返回 curl、wget 等下载命令写入的文件：重定向的目标、-o/-O 指定的文件，
+// 以及按 URL 文件名保存时（curl -O、wget 默认）的文件名
+func downloadedFiles(name string, args []string, redirects []Redirect) []string {
+	var files []string
+	for _, r := range redirects {
+		switch r.Op {
+		case ">", ">>", ">|", "&>", "&>>":
+			files = append(files, r.Target)
+		}
+	}
+
+	var outputOpts []string
+	remoteName, fold := false, false
+	switch name {
+	case "curl":
+		outputOpts = []string{"-o", "--output"}
+	case "wget":
+		outputOpts = []string{"-O", "--output-document"}
+		remoteName = true
+	case "invoke-webrequest", "iwr", "invoke-restmethod", "irm":
+		outputOpts = []string{"-outfile"}
+		fold = true // PowerShell 参数不区分大小写
+	}
+	var urls []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		matched := false
+		for _, opt := range outputOpts {
+			switch {
+			case (arg == opt || (fold && strings.EqualFold(arg, opt))) && i+1 < len(args):
+				files = append(files, args[i+1])
+				i++
+				matched = true
+			case strings.HasPrefix(opt, "--") && strings.HasPrefix(arg, opt+"="):
+				files = append(files, strings.TrimPrefix(arg, opt+"="))
+				matched = true
+			case len(opt) == 2 && strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.HasSuffix(arg, opt[1:]) && i+1 < len(args):
+				// 短选项组合如 curl -fsSLo a.sh
+				files = append(files, args[i+1])
+				i++
+				matched = true
+			}
+			if matched {
+				break
+			}
+		}
+		switch {
+		case matched:
+		case name == "curl" && (arg == "--remote-name" || (strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "O"))):
+			remoteName = true
+		case strings.Contains(arg, "://"):
+			urls = append(urls, arg)
+		}
+	}
+	if remoteName {
+		for _, u := range urls {
+			u, _, _ = strings.Cut(u, "?")
+			if base := path.Base(u); base != "" && base != "/" && !strings.Contains(base, ":") {
+				files = append(files, base)
+			}
+		}
+	}
+	return files
+}
+
+// cleanPath 规范化路径以便比较，如 ./a.sh 和 a.sh
+func cleanPath(p string) string {
+	return path.Clean(strings.ReplaceAll(p, `\`, "/"))
+}
+
+// skipValues 去掉 opts 中带参数选项的参数，如 truncate -s 0 中的 0
+func skipValues(args []string, opts ...string) []string {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			return append(rest, args[i:]...)
+		}
+		rest = append(rest, args[i])
+		if slices.Contains(opts, args[i]) {
+			i++
+		}
+	}
+	return rest
+}
+
+// flagSet 是命令的选项，短选项组合如 -rf 拆开记录
+type flagSet struct {
+	names []string
+}
+
+// has 判断是否包含任一选项，names 不带 - 前缀
+func (f flagSet) has(names ...string) bool {
+	for _, flag := range f.names {
+		for _, name := range names {
+			if strings.TrimLeft(flag, "-") == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitFlags 将参数分为选项和操作数，-- 之后都是操作数。
+// 短选项组合如 -rf 同时记为 -rf、-r、-f，--force=x 记为 --force
+func splitFlags(args []string) (flagSet, []string) {
+	var flags flagSet
+	var targets []string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return flags, append(targets, args[i+1:]...)
+		case strings.HasPrefix(arg, "--"):
+			name, _, _ := strings.Cut(arg, "=")
+			flags.names = append(flags.names, name)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			flags.names = append(flags.names, arg)
+			// 数字选项如 kill -9、-1 不拆开
+			if len(arg) > 2 && strings.Trim(arg[1:], "0123456789") != "" {
+				for _, r := range arg[1:] {
+					flags.names = append(flags.names, "-"+string(r))
+				}
+			}
+		default:
+			targets = append(targets, arg)
+		}
+	}
+	return flags, targets
+}
+
+// describe 返回操作数列表的描述，没有操作数时（如由 xargs 提供）说明来源
+func describe(targets []string) string {
+	if len(targets) == 0 {
+		return "（由输入提供的文件）"
+	}
+	return strings.Join(targets, " ")
+}
+
+// operands 返回参数中不是选项的部分
+func operands(args []string) []string {
+	_, targets := splitFlags(args)
+	return targets
+}
+
+// commandName 返回命令的小写基本名称，如 /usr/bin/rm 为 rm，RM.EXE 为 rm
+func commandName(arg string) string {
+	name := strings.ToLower(arg)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".exe")
+}
+
+var downloader = map[string]bool{
+	"curl": true, "wget": true, "fetch": true, "aria2c": true,
+	"invoke-webrequest": true, "iwr": true, "invoke-restmethod": true, "irm": true,
+}
+
+var interpreter = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "python3": true, "perl": true, "ruby": true, "node": true, "php": true,
+	"pwsh": true, "powershell": true, "iex": true, "invoke-expression": true,
+}
+
+// inlineScript 返回 sh -c 等参数中的脚本
+func inlineScript(name string, args []string) (string, bool) {
+	switch name {
+	case "sh", "bash", "zsh", "dash", "ksh":
+	default:
+		return "", false
+	}
+	for i, arg := range args[1:] {
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c") && i+2 < len(args) {
+			return args[i+2], true
+		}
+	}
+	return "", false
+}
+
+// findDownloader 返回脚本中的下载命令
+func findDownloader(script string) string {
+	commands, _ := Parse(script)
+	for _, c := range commands {
+		if args, _ := unwrap(c.Args); len(args) > 0 && downloader[commandName(args[0])] {
+			return commandName(args[0])
+		}
+	}
+	return ""
+}
+
+// forkBombPattern 匹配函数定义，如 :(){ 或 bomb() {
+var forkBombPattern = regexp.MustCompile(`([\w:.-]+)\s*\(\s*\)\s*\{`)
+
+// forkBomb 检查在函数体中以管道调用自身并放到后台的函数，返回函数名
+func forkBomb(line string) string {
+	for _, m := range forkBombPattern.FindAllStringSubmatchIndex(line, -1) {
+		name := line[m[2]:m[3]]
+		body := strings.Join(strings.Fields(line[m[1]:]), "")
+		if strings.Contains(body, name+"|"+name+"&") {
+			return name
+		}
+	}
+	return ""
+}
+
+// emptyVarPath 匹配以变量开头的绝对路径，如 $DIR/ 或 ${BUILD}/*，变量为空时就是根目录
+var emptyVarPath = regexp.MustCompile(`^\$\{?\w+\}?/`)
+
+// criticalPaths 是删除、移动或修改权限时视为高风险的路径
+var criticalPaths = map[string]bool{
+	"/": true, "/*": true, "~": true, "~/*": true, "$HOME": true, "${HOME}": true,
+	"$HOME/*": true, "${HOME}/*": true, ".": true, "./*": true, "..": true, "../*": true,
+	"*": true, ".*": true, "~/.ssh": true, "~/.gnupg": true, "$HOME/.ssh": true,
+	`C:\`: true, `C:\*`: true, "C:": true,
+}
+
+// systemTrees 下的所有路径都视为系统文件
+var systemTrees = []string{
+	"/bin", "/boot", "/dev", "/etc", "/lib", "/lib32", "/lib64", "/proc", "/sbin", "/sys",
+	"/usr", "/System", "/Library", `C:\Windows`, `C:\Program Files`,
+}
+
+// systemDirs 本身及其直接子目录视为关键路径，如 /home 和 /home/alice
+var systemDirs = []string{"/home", "/Users", "/root", "/var", "/opt", "/Applications", "/private", "/mnt", "/media", "/srv", "/snap"}
+
+// isCritical 判断路径是否为删除后难以恢复的关键路径
+func isCritical(path string) bool {
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "" || criticalPaths[path] {
+		return true
+	}
+	for _, dir := range systemDirs {
+		rest, ok := strings.CutPrefix(path, dir)
+		if ok && (rest == "" || rest == "/*" || (strings.HasPrefix(rest, "/") && !strings.Contains(rest[1:], "/"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRelative 判断路径是否为当前目录下的相对路径，如 . 和 *
+func isRelative(path string) bool {
+	return strings.HasPrefix(path, ".") || strings.HasPrefix(path, "*")
+}
+
+// isSystemPath 判断路径是否位于系统目录中
+func isSystemPath(path string) bool {
+	for _, dir := range systemTrees {
+		if path == dir || strings.HasPrefix(path, dir+"/") || strings.HasPrefix(path, dir+`\`) {
+			return true
+		}
+	}
+	return false
+}
+
+// harmlessDevices 是可以安全写入的设备文件
+var harmlessDevices = map[string]bool{
+	"/dev/null": true, "/dev/stdout": true, "/dev/stderr": true, "/dev/tty": true, "NUL": true, "nul": true,
+}
+
+// diskDevice 匹配磁盘设备，如 /dev/sda、/dev/nvme0n1、/dev/disk2
+var diskDevice = regexp.MustCompile(`^/dev/(sd|hd|vd|xvd|nvme|mmcblk|disk|rdisk|md|loop|mapper/|dm-)`)
+
+func isDevice(path string) bool {
+	return diskDevice.MatchString(path) || strings.HasPrefix(path, `\\.\PhysicalDrive`)
+}
+
+// exists 判断重定向的目标文件是否已存在，含变量的路径无法判断
+func exists(path string) bool {
+	if strings.ContainsAny(path, "$`*?") {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		path = filepath.Join(home, rest)
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
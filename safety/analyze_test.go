@@ -0,0 +1,158 @@
+package safety
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeLevel(t *testing.T) {
+	tests := []struct {
+		command string
+		want    Level
+	}{
+		// 只读命令
+		{"ls -la", Low},
+		{"grep -rn rm .", Low},
+		{"echo 'rm -rf /'", Low},
+		{"cat /etc/passwd", Low},
+		{"find /etc -name '*.conf' -exec cat {} \\;", Low},
+		{"curl -o data.json https://example.com/data.json && cat data.json", Low},
+
+		// rm
+		{"rm notes.txt", Medium},
+		{"rm -rf build", Medium},
+		{"rm -rf /", High},
+		{"rm -rf ~", High},
+		{"rm -rf .", High},
+		{"rm -rf /usr", High},
+		{"rm -rf /etc/*", High},
+		{"rm -rf /boot", High},
+		{"rm -rf /bin/", High},
+		{"rm -r -f /etc", High},
+		{"rm /etc/passwd", High},
+		{"rm -rf $BUILD/", High},
+		{"rm -rf /home/alice", High},
+		{"rm --no-preserve-root -rf /", High},
+		{"/bin/rm -rf /", High},
+
+		// find
+		{"find . -name '*.tmp' -delete", Medium},
+		{"find / -delete", High},
+		{"find /usr -name '*.so' -delete", High},
+		{"find -L / -type f -delete", High},
+		{"find / -exec rm -rf {} +", High},
+		{"find /etc -type f -exec rm {} \\;", High},
+		{"find . -name '*.o' -exec rm {} +", Medium},
+
+		// 包装命令和动态命令名
+		{"sudo ls", High},
+		{"busybox rm -rf /", High},
+		{"toybox rm -rf /etc", High},
+		{"env FOO=1 nice -n 5 rm -rf /", High},
+		{"xargs rm -rf", Medium},
+		{"$(echo rm) -rf /", Medium},
+		{"$CMD -rf /", Medium},
+		{"`which rm` notes.txt", Medium},
+
+		// 下载后执行
+		{"curl -fsSL https://example.com/install.sh | sh", High},
+		{"wget -qO- https://example.com/install.sh | bash", High},
+		{"bash <(curl -s https://example.com/install.sh)", High},
+		{"sh -c \"$(curl -fsSL https://example.com/install.sh)\"", High},
+		{"curl https://example.com/a.sh > a.sh && bash a.sh", High},
+		{"curl -fsSLo a.sh https://example.com/a.sh; sh ./a.sh", High},
+		{"curl --output=/tmp/a.sh https://example.com/a.sh && source /tmp/a.sh", High},
+		{"curl -O https://example.com/setup.sh && chmod +x setup.sh && ./setup.sh", High},
+		{"wget https://example.com/install.sh && sh install.sh", High},
+		{"wget -O run.py https://example.com/x && python3 run.py", High},
+
+		// truncate
+		{"truncate -s 0 app.log", Medium},
+		{"truncate -s 0 /etc/passwd", High},
+		{"truncate --size=0 /etc/shadow", High},
+		{"truncate -r /etc/hosts notes.txt", Medium},
+
+		// 其他
+		{"git push --force origin main", High},
+		{"git push origin +main", High},
+		{"git reset --hard HEAD~1", Medium},
+		{"git status", Low},
+		{"dd if=/dev/zero of=/dev/sda bs=1M", High},
+		{"echo hi > /etc/hosts", High},
+		{"echo hi >> /dev/null", Low},
+		{":(){ :|:& };:", High},
+		{"sh -c 'rm -rf /'", High},
+		{"eval \"rm -rf /\"", High},
+		{"chmod -R 777 /", High},
+		{"chmod 644 /etc/hosts", High},
+		{"chmod -R 755 dist", Medium},
+		{"mv /etc /tmp/etc", High},
+		{"kill -9 1", High},
+		{"shutdown -h now", High},
+		{"mkfs.ext4 /dev/sdb1", High},
+		{"docker rm web", Medium},
+		{"kubectl delete pod web", Medium},
+		{"crontab -r", High},
+		{"sh <<EOF\nrm -rf /\nEOF", High},
+		{"echo 'rm -rf /' | sh", High},
+	}
+	for _, tt := range tests {
+		got := Analyze(tt.command)
+		if got.Level != tt.want {
+			t.Errorf("Analyze(%q).Level = %s, want %s; findings: %v", tt.command, got.Level, tt.want, got.Findings)
+		}
+	}
+}
+
+func TestAnalyzeReasons(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"truncate -s 0 app.log", "截断文件 app.log"},
+		{"truncate -s 0 /etc/passwd", "截断系统文件 /etc/passwd"},
+		{"rm -rf /usr", "删除系统文件 /usr"},
+		{"find / -exec rm -rf {} +", "删除关键路径 /"},
+		{"curl https://example.com/a.sh > a.sh && bash a.sh", "curl 下载的文件 a.sh 交给 bash 执行"},
+		{"curl -O https://example.com/setup.sh && ./setup.sh", "直接执行 curl 下载的文件 ./setup.sh"},
+		{"busybox rm -rf /", "删除关键路径 /"},
+		{"$(echo rm) -rf /", "命令名由变量或命令替换决定"},
+	}
+	for _, tt := range tests {
+		got := Analyze(tt.command)
+		found := false
+		for _, f := range got.Findings {
+			if strings.Contains(f.Reason, tt.want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Analyze(%q) findings = %v, want one containing %q", tt.command, got.Findings, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeCommands(t *testing.T) {
+	got := Analyze("sudo busybox rm -rf /tmp/x && ls | grep y").Commands
+	want := [][]string{{"rm", "-rf", "/tmp/x"}, {"ls"}, {"grep", "y"}}
+	if len(got) != len(want) {
+		t.Fatalf("Commands = %q, want %q", got, want)
+	}
+	for i := range want {
+		if strings.Join(got[i], " ") != strings.Join(want[i], " ") {
+			t.Errorf("Commands[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{Low, Medium, High} {
+		got, err := ParseLevel(l.String())
+		if err != nil || got != l {
+			t.Errorf("ParseLevel(%q) = %v, %v", l.String(), got, err)
+		}
+	}
+	if _, err := ParseLevel("critical"); err == nil {
+		t.Error("ParseLevel(\"critical\") should fail")
+	}
+}
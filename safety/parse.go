@@ -0,0 +1,461 @@
+// Package safety 在执行模型生成的 shell 命令之前分析其风险，并按策略决定是否执行。
+//
+// 命令先被解析为简单命令（识别管道、&&、;、子 shell、引号、重定向和 here-document），
+// 再逐条检查破坏性的文件操作、提权、下载后直接执行、写入磁盘设备、强制推送等行为。
+// 分析是静态的，不展开变量和通配符，只用于提醒和拦截明显的危险操作。
+package safety
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Command 是从命令行中解析出的一条简单命令
+type Command struct {
+	Args      []string // 命令名和参数，已去掉引号和前置的环境变量赋值
+	Redirects []Redirect
+	// Pipeline 是所在管道的编号，同一管道中的命令依次把输出传给下一条
+	Pipeline int
+	// Substitutions 是参数和重定向中命令替换 $(...)、`...` 和进程替换 <(...) 的内容
+	Substitutions []string
+	// Input 是 here-document 和 here-string 的内容
+	Input []string
+}
+
+// Redirect 是一个重定向
+type Redirect struct {
+	Op     string // >、>>、<、&> 等，不含文件描述符
+	Target string
+}
+
+// ErrIncomplete 表示命令行不完整，如引号或括号没有闭合
+var ErrIncomplete = errors.New("引号或括号没有闭合")
+
+// redirectOps 按长度从长到短排列，匹配时取最长的操作符
+var redirectOps = []string{"&>>", "<<<", "<<-", "&>", ">>", ">|", ">&", "<<", "<&", "<>", ">", "<"}
+
+// reservedWords 是出现在命令开头时不作为命令名的 shell 关键字
+var reservedWords = map[string]bool{
+	"{": true, "}": true, "!": true, "if": true, "then": true, "else": true, "elif": true,
+	"fi": true, "do": true, "done": true, "while": true, "until": true, "esac": true,
+}
+
+// Parse 将命令行解析为简单命令。语法不完整时返回已解析的部分和 ErrIncomplete
+func Parse(line string) ([]Command, error) {
+	p := &parser{src: []rune(line)}
+	p.run()
+	return p.commands, p.err
+}
+
+// Fields 按 shell 规则将字符串拆分为单词，用于解析策略规则
+func Fields(s string) []string {
+	commands, _ := Parse(s)
+	if len(commands) == 0 {
+		return nil
+	}
+	return commands[0].Args
+}
+
+type heredoc struct {
+	delim     string
+	stripTabs bool
+	command   int // 所属命令在 commands 中的下标
+}
+
+type parser struct {
+	src      []rune
+	pos      int
+	commands []Command
+	cur      *Command
+	skip     bool // 当前是 for、case 等语句头，不是命令
+	pipeline int
+	heredocs []heredoc
+	err      error
+}
+
+func (p *parser) peek(offset int) rune {
+	if p.pos+offset < len(p.src) {
+		return p.src[p.pos+offset]
+	}
+	return 0
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	for i, r := range s {
+		if p.peek(i) != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *parser) run() {
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '\n':
+			p.pos++
+			p.end(false)
+			p.readHeredocs()
+		case r == ' ' || r == '\t' || r == '\r':
+			p.pos++
+		case r == '\\' && p.peek(1) == '\n':
+			p.pos += 2
+		case r == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case p.hasPrefix("||") || p.hasPrefix("&&") || p.hasPrefix(";;"):
+			p.pos += 2
+			p.end(false)
+		case p.hasPrefix("|&"):
+			p.pos += 2
+			p.end(true)
+		case r == '|':
+			p.pos++
+			p.end(true)
+		case r == ';' || (r == '&' && p.peek(1) != '>'):
+			p.pos++
+			p.end(false)
+		case p.hasPrefix("(("):
+			// 算术表达式 ((...)) 不包含命令
+			p.pos += 2
+			p.balanced('(', ')')
+			p.pos++
+		case r == '(':
+			p.pos++
+			p.end(false)
+		case r == ')':
+			// 子 shell 结束后可能还接着管道，管道编号保持不变
+			p.pos++
+			p.end(true)
+		case (r == '<' || r == '>') && p.peek(1) == '(':
+			p.word()
+		case r == '<' || r == '>' || r == '&':
+			p.redirect()
+		case unicode.IsDigit(r) && p.fdRedirect():
+			p.redirect()
+		default:
+			p.word()
+		}
+	}
+	p.end(false)
+	if len(p.heredocs) > 0 {
+		p.fail(ErrIncomplete)
+	}
+}
+
+// command 返回当前命令，没有时开始一条新命令
+func (p *parser) command() *Command {
+	if p.cur == nil {
+		p.cur = &Command{Pipeline: p.pipeline}
+	}
+	return p.cur
+}
+
+// end 结束当前命令，pipe 为 true 表示输出通过管道传给下一条命令
+func (p *parser) end(pipe bool) {
+	if p.cur != nil && !p.skip && (len(p.cur.Args) > 0 || len(p.cur.Redirects) > 0 || len(p.cur.Substitutions) > 0) {
+		p.commands = append(p.commands, *p.cur)
+	}
+	p.cur, p.skip = nil, false
+	if !pipe {
+		p.pipeline++
+	}
+}
+
+// fdRedirect 判断当前位置是否为带文件描述符的重定向，如 2>
+func (p *parser) fdRedirect() bool {
+	i := 0
+	for unicode.IsDigit(p.peek(i)) {
+		i++
+	}
+	next := p.peek(i)
+	if (next == '<' || next == '>') && p.peek(i+1) != '(' {
+		p.pos += i
+		return true
+	}
+	return false
+}
+
+func (p *parser) redirect() {
+	op := ""
+	for _, candidate := range redirectOps {
+		if p.hasPrefix(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		// 单独的 & 已在 run 中处理，这里不会出现
+		p.pos++
+		return
+	}
+	p.pos += len(op)
+	for p.peek(0) == ' ' || p.peek(0) == '\t' {
+		p.pos++
+	}
+	cmd := p.command()
+	target, subs := p.readWord()
+	cmd.Substitutions = append(cmd.Substitutions, subs...)
+	switch op {
+	case "<<", "<<-":
+		// 当前命令结束后会保存在 commands 的末尾
+		p.heredocs = append(p.heredocs, heredoc{delim: target, stripTabs: op == "<<-", command: len(p.commands)})
+	case "<<<":
+		cmd.Input = append(cmd.Input, target)
+	}
+	cmd.Redirects = append(cmd.Redirects, Redirect{Op: op, Target: target})
+}
+
+// readHeredocs 读取换行之后的 here-document 内容，直到遇到结束标记
+func (p *parser) readHeredocs() {
+	pending := p.heredocs
+	p.heredocs = nil
+	for _, h := range pending {
+		var body []string
+		found := false
+		for p.pos < len(p.src) {
+			end := p.pos
+			for end < len(p.src) && p.src[end] != '\n' {
+				end++
+			}
+			line := string(p.src[p.pos:end])
+			p.pos = min(end+1, len(p.src))
+			if h.stripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == h.delim {
+				found = true
+				break
+			}
+			body = append(body, line)
+		}
+		if !found {
+			p.fail(ErrIncomplete)
+		}
+		if h.command < len(p.commands) {
+			c := &p.commands[h.command]
+			c.Input = append(c.Input, strings.Join(body, "\n"))
+		}
+	}
+}
+
+func (p *parser) word() {
+	cmd := p.command()
+	w, subs := p.readWord()
+	cmd.Substitutions = append(cmd.Substitutions, subs...)
+	if len(cmd.Args) == 0 {
+		switch {
+		case reservedWords[w]:
+			return
+		case w == "for" || w == "select" || w == "case" || w == "function":
+			p.skip = true
+			return
+		case isAssignment(w):
+			return
+		}
+	}
+	cmd.Args = append(cmd.Args, w)
+}
+
+// readWord 读取一个单词，返回去掉引号后的内容和其中的命令替换
+func (p *parser) readWord() (string, []string) {
+	var b strings.Builder
+	var subs []string
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case (r == '<' || r == '>') && p.peek(1) == '(':
+			p.pos += 2
+			inner := p.balanced('(', ')')
+			p.pos++
+			subs = append(subs, inner)
+			b.WriteString(string(r) + "(" + inner + ")")
+		case strings.ContainsRune(" \t\r\n;&|()<>", r):
+			return b.String(), subs
+		case r == '\\':
+			if p.peek(1) != '\n' && p.peek(1) != 0 {
+				b.WriteRune(p.peek(1))
+			}
+			p.pos += 2
+		case r == '\'':
+			p.pos++
+			start := p.pos
+			for p.pos < len(p.src) && p.src[p.pos] != '\'' {
+				p.pos++
+			}
+			if p.pos >= len(p.src) {
+				p.fail(ErrIncomplete)
+			}
+			b.WriteString(string(p.src[start:min(p.pos, len(p.src))]))
+			p.pos++
+		case r == '"':
+			p.pos++
+			subs = append(subs, p.readDoubleQuoted(&b)...)
+		case r == '`':
+			p.pos++
+			inner := p.backquoted()
+			subs = append(subs, inner)
+			b.WriteString("`" + inner + "`")
+		case r == '$':
+			subs = append(subs, p.readDollar(&b)...)
+		default:
+			b.WriteRune(r)
+			p.pos++
+		}
+	}
+	return b.String(), subs
+}
+
+// readDoubleQuoted 读取双引号中的内容，当前位置在左引号之后
+func (p *parser) readDoubleQuoted(b *strings.Builder) []string {
+	var subs []string
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch r {
+		case '"':
+			p.pos++
+			return subs
+		case '\\':
+			next := p.peek(1)
+			if strings.ContainsRune("\"\\$`", next) && next != 0 {
+				b.WriteRune(next)
+			} else if next != '\n' {
+				b.WriteRune(r)
+				if next != 0 {
+					b.WriteRune(next)
+				}
+			}
+			p.pos += 2
+		case '`':
+			p.pos++
+			inner := p.backquoted()
+			subs = append(subs, inner)
+			b.WriteString("`" + inner + "`")
+		case '$':
+			subs = append(subs, p.readDollar(b)...)
+		default:
+			b.WriteRune(r)
+			p.pos++
+		}
+	}
+	p.fail(ErrIncomplete)
+	return subs
+}
+
+// readDollar 读取 $ 开头的展开，命令替换的内容作为结果返回，其余原样保留
+func (p *parser) readDollar(b *strings.Builder) []string {
+	switch {
+	case p.hasPrefix("$(("):
+		p.pos += 3
+		inner := p.balanced('(', ')')
+		p.pos++
+		b.WriteString("$((" + inner + ")")
+		if p.peek(0) == ')' {
+			p.pos++
+			b.WriteString(")")
+		}
+	case p.hasPrefix("$("):
+		p.pos += 2
+		inner := p.balanced('(', ')')
+		p.pos++
+		b.WriteString("$(" + inner + ")")
+		return []string{inner}
+	case p.hasPrefix("${"):
+		p.pos += 2
+		inner := p.balanced('{', '}')
+		p.pos++
+		b.WriteString("${" + inner + "}")
+	case p.hasPrefix("$'"):
+		p.pos += 2
+		for p.pos < len(p.src) && p.src[p.pos] != '\'' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			if p.pos < len(p.src) {
+				b.WriteRune(p.src[p.pos])
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			p.fail(ErrIncomplete)
+		}
+		p.pos++
+	default:
+		b.WriteRune('$')
+		p.pos++
+	}
+	return nil
+}
+
+// balanced 读取到与已读入的左括号匹配的右括号之前，跳过引号中的括号，
+// 返回括号中的内容，当前位置停在右括号上
+func (p *parser) balanced(open, close rune) string {
+	start, depth := p.pos, 1
+	for p.pos < len(p.src) {
+		switch r := p.src[p.pos]; r {
+		case '\\':
+			p.pos++
+		case '\'', '"':
+			p.pos++
+			for p.pos < len(p.src) && p.src[p.pos] != r {
+				if r == '"' && p.src[p.pos] == '\\' {
+					p.pos++
+				}
+				p.pos++
+			}
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return string(p.src[start:p.pos])
+			}
+		}
+		p.pos++
+	}
+	p.fail(ErrIncomplete)
+	return string(p.src[start:min(p.pos, len(p.src))])
+}
+
+// backquoted 读取反引号中的内容，当前位置在左反引号之后
+func (p *parser) backquoted() string {
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		if r == '`' {
+			p.pos++
+			return b.String()
+		}
+		if r == '\\' && p.pos+1 < len(p.src) {
+			p.pos++
+			r = p.src[p.pos]
+		}
+		b.WriteRune(r)
+		p.pos++
+	}
+	p.fail(ErrIncomplete)
+	return b.String()
+}
+
+// isAssignment 判断单词是否为 NAME=value 形式的变量赋值
+func isAssignment(w string) bool {
+	name, _, ok := strings.Cut(w, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && !(i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package safety
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want []Command
+	}{
+		{
+			line: "ls -la",
+			want: []Command{{Args: []string{"ls", "-la"}}},
+		},
+		{
+			line: `echo "a b" 'c d' e\ f`,
+			want: []Command{{Args: []string{"echo", "a b", "c d", "e f"}}},
+		},
+		{
+			line: "cat a | grep b && echo ok; pwd",
+			want: []Command{
+				{Args: []string{"cat", "a"}},
+				{Args: []string{"grep", "b"}},
+				{Args: []string{"echo", "ok"}, Pipeline: 1},
+				{Args: []string{"pwd"}, Pipeline: 2},
+			},
+		},
+		{
+			line: "FOO=1 make 2>/dev/null > out.txt",
+			want: []Command{{
+				Args:      []string{"make"},
+				Redirects: []Redirect{{Op: ">", Target: "/dev/null"}, {Op: ">", Target: "out.txt"}},
+			}},
+		},
+		{
+			line: "echo $(date) `whoami`",
+			want: []Command{{
+				Args:          []string{"echo", "$(date)", "`whoami`"},
+				Substitutions: []string{"date", "whoami"},
+			}},
+		},
+		{
+			line: "cat <<EOF\nhello\nEOF\necho done",
+			want: []Command{
+				{Args: []string{"cat"}, Redirects: []Redirect{{Op: "<<", Target: "EOF"}}, Input: []string{"hello"}},
+				{Args: []string{"echo", "done"}, Pipeline: 1},
+			},
+		},
+		{
+			line: "if true; then echo yes; fi",
+			want: []Command{
+				{Args: []string{"true"}},
+				{Args: []string{"echo", "yes"}, Pipeline: 1},
+			},
+		},
+		{
+			line: "(cd /tmp && ls) | wc -l",
+			want: []Command{
+				{Args: []string{"cd", "/tmp"}, Pipeline: 1},
+				{Args: []string{"ls"}, Pipeline: 2},
+				{Args: []string{"wc", "-l"}, Pipeline: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.line)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseIncomplete(t *testing.T) {
+	for _, line := range []string{`echo "abc`, "echo 'abc", "echo $(date", "cat <<EOF\nhello"} {
+		if _, err := Parse(line); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q) error = %v, want ErrIncomplete", line, err)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	got := Fields(`git push "--force" origin`)
+	want := []string{"git", "push", "--force", "origin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %q, want %q", got, want)
+	}
+}
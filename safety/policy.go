@@ -0,0 +1,124 @@
+package safety
+
+import (
+	"fmt"
+	"path"
+)
+
+// Action 是策略对命令的处理方式
+type Action string
+
+const (
+	Allow   Action = "allow"   // 直接执行，不询问
+	Ask     Action = "ask"     // 询问 y/N
+	Confirm Action = "confirm" // 需要输入 yes 确认
+	Deny    Action = "deny"    // 拒绝执行
+)
+
+// ParseAction 解析 allow、ask、confirm、deny
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case Allow, Ask, Confirm, Deny:
+		return a, nil
+	}
+	return "", fmt.Errorf("未知的处理方式 %q，可选 allow、ask、confirm、deny", s)
+}
+
+// strictness 返回处理方式的严格程度，用于取较严格的一方
+func (a Action) strictness() int {
+	switch a {
+	case Allow:
+		return 0
+	case Confirm:
+		return 2
+	case Deny:
+		return 3
+	}
+	return 1
+}
+
+// Policy 决定命令是否需要确认。规则是以空格分隔的命令前缀，每个单词支持 * 等通配符，
+// 如 "git push"、"rm -rf *"，与命令中的每一条简单命令（去掉 sudo 等前缀）比较
+type Policy struct {
+	Allow   []string // 所有简单命令都匹配时直接执行，不适用于高风险命令
+	Confirm []string // 任一简单命令匹配时需要输入 yes 确认
+	Deny    []string // 任一简单命令匹配时拒绝执行
+	// Levels 是各风险等级的处理方式
+	Levels map[Level]Action
+}
+
+// DefaultPolicy 返回默认策略：低风险和中风险询问 y/N，高风险需要输入 yes 确认
+func DefaultPolicy() Policy {
+	return Policy{Levels: map[Level]Action{Low: Ask, Medium: Ask, High: Confirm}}
+}
+
+// Decision 是策略对一条命令的决定
+type Decision struct {
+	Action Action
+	Reason string // 作出决定的依据，如匹配的规则
+}
+
+// Decide 根据分析结果决定如何处理命令。deny 规则优先，其次取风险等级和 confirm 规则中
+// 较严格的处理方式，最后在只需询问时检查 allow 规则
+func (p Policy) Decide(a Analysis) Decision {
+	if rule, ok := matchAny(p.Deny, a.Commands); ok {
+		return Decision{Action: Deny, Reason: fmt.Sprintf("匹配 deny 规则 %q", rule)}
+	}
+
+	action, ok := p.Levels[a.Level]
+	if !ok {
+		action = DefaultPolicy().Levels[a.Level]
+	}
+	decision := Decision{Action: action, Reason: fmt.Sprintf("风险等级 %s", a.Level)}
+	if rule, ok := matchAny(p.Confirm, a.Commands); ok && Confirm.strictness() > action.strictness() {
+		decision = Decision{Action: Confirm, Reason: fmt.Sprintf("匹配 confirm 规则 %q", rule)}
+	}
+
+	if decision.Action == Ask && a.Level < High && matchAll(p.Allow, a.Commands) {
+		return Decision{Action: Allow, Reason: "匹配 allow 规则"}
+	}
+	return decision
+}
+
+// matchAny 返回第一条与任一命令匹配的规则
+func matchAny(rules []string, commands [][]string) (string, bool) {
+	for _, rule := range rules {
+		for _, args := range commands {
+			if matchRule(rule, args) {
+				return rule, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchAll 判断是否每条命令都至少匹配一条规则
+func matchAll(rules []string, commands [][]string) bool {
+	if len(rules) == 0 || len(commands) == 0 {
+		return false
+	}
+	for _, args := range commands {
+		if _, ok := matchAny(rules, [][]string{args}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRule 判断命令参数是否以规则中的单词开头，命令名按基本名称比较
+func matchRule(rule string, args []string) bool {
+	words := Fields(rule)
+	if len(words) == 0 || len(words) > len(args) {
+		return false
+	}
+	for i, word := range words {
+		arg := args[i]
+		if i == 0 {
+			arg = commandName(arg)
+		}
+		if ok, _ := path.Match(word, arg); !ok && word != arg {
+			return false
+		}
+	}
+	return true
+}
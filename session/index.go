@@ -0,0 +1,559 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"Qwen-cli/client"
+)
+
+// indexVersion 变更索引格式时递增，旧索引会被自动重建
+const indexVersion = 2
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// index 是保存在 sessions/index.json 的倒排索引，每条用户或助手消息是一个文档。
+// 会话和 Markdown 对话记录都是索引中的条目，对话记录以 transcriptKey 作为条目 ID
+type index struct {
+	Version  int                       `json:"version"`
+	Sessions map[string]indexedSession `json:"sessions"`
+	Docs     map[string]indexedDoc     `json:"docs"`
+	Postings map[string]map[string]int `json:"postings"` // 词 -> 文档 -> 词频
+}
+
+type indexedSession struct {
+	ModTime     time.Time `json:"mod_time"` // 索引时文件的修改时间
+	Model       string    `json:"model"`
+	Role        string    `json:"role"`
+	File        string    `json:"file,omitempty"`        // 对话记录的路径，会话为空
+	Transcripts []string  `json:"transcripts,omitempty"` // 会话的自动记录文件，不再单独索引
+	Docs        []string  `json:"docs"`
+}
+
+type indexedDoc struct {
+	Session string    `json:"session"`
+	Message int       `json:"message"`
+	Role    string    `json:"role"`
+	Time    time.Time `json:"time"`
+	Length  int       `json:"length"`
+	Terms   []string  `json:"terms"`
+}
+
+func newIndex() *index {
+	return &index{
+		Version:  indexVersion,
+		Sessions: map[string]indexedSession{},
+		Docs:     map[string]indexedDoc{},
+		Postings: map[string]map[string]int{},
+	}
+}
+
+// transcriptKey 返回对话记录在索引中的条目 ID
+func transcriptKey(path string) string {
+	return "file:" + path
+}
+
+func (st *Store) indexPath() string {
+	return filepath.Join(st.Dir, "index.json")
+}
+
+// loadIndex 读取索引，文件不存在或版本不符时返回空索引
+func (st *Store) loadIndex() *index {
+	data, err := os.ReadFile(st.indexPath())
+	if err != nil {
+		return newIndex()
+	}
+	idx := newIndex()
+	if err := json.Unmarshal(data, idx); err != nil || idx.Version != indexVersion {
+		return newIndex()
+	}
+	return idx
+}
+
+// saveIndex 写入索引。临时文件名唯一，多个 ask 进程同时写入时不会互相覆盖写到一半的文件
+func (st *Store) saveIndex(idx *index) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	tmp, err := os.CreateTemp(st.Dir, "index.*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), st.indexPath()); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
+
+// updateIndex 在会话保存后重新索引该会话，并移除其自动记录文件的重复条目
+func (st *Store) updateIndex(s *Session, modTime time.Time) error {
+	idx := st.loadIndex()
+	idx.remove(s.ID)
+	idx.add(s.ID, s, modTime, "")
+	for _, path := range s.Transcripts {
+		idx.remove(transcriptKey(path))
+	}
+	return st.saveIndex(idx)
+}
+
+// removeFromIndex 在会话删除后移除其索引
+func (st *Store) removeFromIndex(id string) error {
+	idx := st.loadIndex()
+	if _, ok := idx.Sessions[id]; !ok {
+		return nil
+	}
+	idx.remove(id)
+	return st.saveIndex(idx)
+}
+
+// IndexTranscript 索引 Markdown 对话记录，用于 /save 导出到 Transcripts 目录之外的文件
+func (st *Store) IndexTranscript(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	s, modTime, err := loadTranscript(path)
+	if err != nil {
+		return fmt.Errorf("failed to read transcript: %w", err)
+	}
+	if err := os.MkdirAll(st.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	idx := st.loadIndex()
+	key := transcriptKey(path)
+	idx.remove(key)
+	idx.add(key, s, modTime, path)
+	return st.saveIndex(idx)
+}
+
+// syncIndex 检查索引与磁盘是否一致。保存和删除时已增量更新索引，
+// 这里补齐多个进程同时写入时丢失的更新、手动修改或删除的文件，以及 Transcripts 目录中新增的对话记录
+func (st *Store) syncIndex() (*index, error) {
+	idx := st.loadIndex()
+	ids, err := st.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	present := map[string]bool{}
+	for _, id := range ids {
+		present[id] = true
+		info, err := os.Stat(st.path(id))
+		if err != nil {
+			continue
+		}
+		if indexed, ok := idx.Sessions[id]; ok && indexed.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		s, err := st.Load(id)
+		if err != nil {
+			continue
+		}
+		idx.remove(id)
+		idx.add(id, s, info.ModTime(), "")
+		changed = true
+	}
+
+	// 对话记录：Transcripts 目录中的文件和之前通过 IndexTranscript 索引的文件
+	recorded := map[string]bool{}
+	var files []string
+	for key, entry := range idx.Sessions {
+		switch {
+		case entry.File != "":
+			files = append(files, entry.File)
+		case !present[key]:
+			idx.remove(key)
+			changed = true
+		default:
+			for _, path := range entry.Transcripts {
+				recorded[path] = true
+			}
+		}
+	}
+	for _, path := range st.transcriptFiles() {
+		if abs, err := filepath.Abs(path); err == nil {
+			files = append(files, abs)
+		}
+	}
+	for _, path := range files {
+		key := transcriptKey(path)
+		indexed, ok := idx.Sessions[key]
+		info, err := os.Stat(path)
+		if err != nil || recorded[path] {
+			if ok {
+				idx.remove(key)
+				changed = true
+			}
+			continue
+		}
+		if ok && indexed.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		s, modTime, err := loadTranscript(path)
+		if err != nil {
+			continue
+		}
+		idx.remove(key)
+		idx.add(key, s, modTime, path)
+		changed = true
+	}
+
+	if changed {
+		if err := os.MkdirAll(st.Dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create session directory: %w", err)
+		}
+		if err := st.saveIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// add 索引会话 s 的消息，key 是索引条目 ID，file 是对话记录的路径
+func (idx *index) add(key string, s *Session, modTime time.Time, file string) {
+	entry := indexedSession{ModTime: modTime, Model: s.Model, Role: s.Role, File: file, Transcripts: s.Transcripts}
+	for i, msg := range s.Messages {
+		if msg.Role != client.RoleUser && msg.Role != client.RoleAssistant {
+			continue
+		}
+		terms := tokenize(msg.Content)
+		if len(terms) == 0 {
+			continue
+		}
+
+		docKey := fmt.Sprintf("%s#%d", key, i)
+		freq := map[string]int{}
+		for _, term := range terms {
+			freq[term]++
+		}
+		unique := make([]string, 0, len(freq))
+		for term, n := range freq {
+			unique = append(unique, term)
+			if idx.Postings[term] == nil {
+				idx.Postings[term] = map[string]int{}
+			}
+			idx.Postings[term][docKey] = n
+		}
+
+		idx.Docs[docKey] = indexedDoc{
+			Session: key,
+			Message: i,
+			Role:    msg.Role,
+			Time:    msg.CreatedAt,
+			Length:  len(terms),
+			Terms:   unique,
+		}
+		entry.Docs = append(entry.Docs, docKey)
+	}
+	idx.Sessions[key] = entry
+}
+
+func (idx *index) remove(key string) {
+	entry, ok := idx.Sessions[key]
+	if !ok {
+		return
+	}
+	for _, docKey := range entry.Docs {
+		for _, term := range idx.Docs[docKey].Terms {
+			delete(idx.Postings[term], docKey)
+			if len(idx.Postings[term]) == 0 {
+				delete(idx.Postings, term)
+			}
+		}
+		delete(idx.Docs, docKey)
+	}
+	delete(idx.Sessions, key)
+}
+
+// SearchOptions 搜索过滤条件，零值表示不过滤
+type SearchOptions struct {
+	Since time.Time // 只搜索该时间之后的消息
+	Until time.Time // 只搜索该时间之前的消息
+	Model string    // 会话模型
+	Role  string    // 会话角色提示词名称
+	From  string    // 消息作者：user 或 assistant
+	Limit int       // 最多返回的结果数，小于等于 0 时不限制
+}
+
+// SearchResult 是一条命中的消息
+type SearchResult struct {
+	Session *Session
+	File    string // 命中 Markdown 对话记录时为文件路径，此时 Session 由文件内容解析而来
+	Message int    // 命中消息在 Session.Messages 中的下标
+	Score   float64
+	Terms   []string // 查询分词结果，用于高亮
+}
+
+// Search 使用 BM25 在所有会话消息中搜索 query
+func (st *Store) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	idx, err := st.syncIndex()
+	if err != nil {
+		return nil, err
+	}
+	if len(idx.Docs) == 0 {
+		return nil, nil
+	}
+
+	totalLength := 0
+	for _, doc := range idx.Docs {
+		totalLength += doc.Length
+	}
+	avgLength := float64(totalLength) / float64(len(idx.Docs))
+	n := float64(len(idx.Docs))
+
+	scores := map[string]float64{}
+	for _, term := range terms {
+		postings := idx.Postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for key, tf := range postings {
+			doc := idx.Docs[key]
+			if !opts.match(doc, idx.Sessions[doc.Session]) {
+				continue
+			}
+			f := float64(tf)
+			scores[key] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(doc.Length)/avgLength))
+		}
+	}
+
+	keys := make([]string, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		return idx.Docs[keys[i]].Time.After(idx.Docs[keys[j]].Time)
+	})
+	if opts.Limit > 0 && len(keys) > opts.Limit {
+		keys = keys[:opts.Limit]
+	}
+
+	loaded := map[string]*Session{}
+	results := make([]SearchResult, 0, len(keys))
+	for _, key := range keys {
+		doc := idx.Docs[key]
+		file := idx.Sessions[doc.Session].File
+		s, ok := loaded[doc.Session]
+		if !ok {
+			if file != "" {
+				s, _, err = loadTranscript(file)
+			} else {
+				s, err = st.Load(doc.Session)
+			}
+			if err != nil {
+				continue
+			}
+			loaded[doc.Session] = s
+		}
+		if doc.Message >= len(s.Messages) {
+			continue
+		}
+		results = append(results, SearchResult{
+			Session: s,
+			File:    file,
+			Message: doc.Message,
+			Score:   scores[key],
+			Terms:   terms,
+		})
+	}
+	return results, nil
+}
+
+func (opts SearchOptions) match(doc indexedDoc, s indexedSession) bool {
+	if !opts.Since.IsZero() && doc.Time.Before(opts.Since) {
+		return false
+	}
+	if !opts.Until.IsZero() && doc.Time.After(opts.Until) {
+		return false
+	}
+	if opts.Model != "" && !strings.EqualFold(s.Model, opts.Model) {
+		return false
+	}
+	if opts.Role != "" && !strings.EqualFold(s.Role, opts.Role) {
+		return false
+	}
+	if opts.From != "" && doc.Role != opts.From {
+		return false
+	}
+	return true
+}
+
+// tokenize 将文本切分为索引词：拉丁字母和数字按单词切分并转为小写，
+// 中日韩文字按二元组（bigram）切分，单个汉字作为一个词
+func tokenize(text string) []string {
+	var tokens []string
+	var word, cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		r = unicode.ToLower(r)
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := map[string]bool{}
+	unique := terms[:0:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// Snippet 截取消息中第一个命中位置附近的文本，width 为两侧保留的字符数。
+// highlight 用于包裹命中的词，为 nil 时不高亮
+func Snippet(content string, terms []string, width int, highlight func(string) string) string {
+	runes := []rune(content)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	matched := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != term {
+				continue
+			}
+			// 拉丁单词需要完整匹配，避免 "go" 高亮 "google"
+			if !isCJK(t[0]) && !wordBoundary(lower, i, i+len(t)) {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				matched[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start, end := 0, len(runes)
+	if first >= 0 {
+		start = max(0, first-width)
+		end = min(len(runes), first+width)
+	} else if end > 2*width {
+		end = 2 * width
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && matched[j] == matched[i] {
+			j++
+		}
+		// 折叠空白，保持片段为单行
+		segment := strings.Join(strings.Fields(string(runes[i:j])), " ")
+		switch {
+		case segment == "":
+			segment = " "
+		default:
+			if i > start && unicode.IsSpace(runes[i]) {
+				segment = " " + segment
+			}
+			if j < end && unicode.IsSpace(runes[j-1]) {
+				segment += " "
+			}
+		}
+		if matched[i] && highlight != nil {
+			segment = highlight(segment)
+		}
+		b.WriteString(segment)
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func wordBoundary(runes []rune, start, end int) bool {
+	isWord := func(r rune) bool {
+		return !isCJK(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+	}
+	if start > 0 && isWord(runes[start-1]) {
+		return false
+	}
+	if end < len(runes) && isWord(runes[end]) {
+		return false
+	}
+	return true
+}
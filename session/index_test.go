@@ -0,0 +1,328 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"Qwen-cli/client"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Nginx reverse-proxy", []string{"nginx", "reverse", "proxy"}},
+		{"配置", []string{"配置"}},
+		{"反向代理", []string{"反向", "向代", "代理"}},
+		{"用 nginx 做反向代理", []string{"用", "nginx", "做反", "反向", "向代", "代理"}},
+		{"HTTP2 端口443", []string{"http2", "端口", "443"}},
+		{"", nil},
+		{"!!! ...", nil},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		content string
+		terms   []string
+		width   int
+		want    string
+	}{
+		{"use nginx as a proxy", []string{"nginx"}, 60, "use [nginx] as a proxy"},
+		{"google is not go", []string{"go"}, 60, "google is not [go]"},
+		{"aaaa bbbb nginx cccc dddd", []string{"nginx"}, 6, "…bbbb [nginx] …"},
+		{"配置反向代理", []string{"代理"}, 60, "配置反向[代理]"},
+		{"line one\n\nline  two", nil, 60, "line one line two"},
+	}
+	highlight := func(s string) string { return "[" + s + "]" }
+	for _, tt := range tests {
+		if got := Snippet(tt.content, tt.terms, tt.width, highlight); got != tt.want {
+			t.Errorf("Snippet(%q, %q) = %q, want %q", tt.content, tt.terms, got, tt.want)
+		}
+	}
+}
+
+// newTestSession 创建包含一问一答的会话，ID 由调用方指定以便断言
+func newTestSession(id, model, question, answer string, at time.Time) *Session {
+	s := New(model, "", "system")
+	s.ID = id
+	s.Append(Message{Role: client.RoleUser, Content: question, CreatedAt: at})
+	s.Append(Message{Role: client.RoleAssistant, Content: answer, CreatedAt: at})
+	return s
+}
+
+func newTestStore(t *testing.T) *Store {
+	dir := t.TempDir()
+	return &Store{Dir: filepath.Join(dir, "sessions"), Transcripts: dir}
+}
+
+// searchIDs 返回命中的消息，格式为 会话ID#消息下标，对话记录用文件名代替会话 ID。
+// 结果排序后返回，排名由 TestSearchRanking 单独检查
+func searchIDs(t *testing.T, st *Store, query string, opts SearchOptions) []string {
+	t.Helper()
+	results, err := st.Search(query, opts)
+	if err != nil {
+		t.Fatalf("Search(%q) error: %v", query, err)
+	}
+	var ids []string
+	for _, r := range results {
+		id := r.Session.ID
+		if r.File != "" {
+			id = filepath.Base(r.File)
+		}
+		ids = append(ids, fmt.Sprintf("%s#%d", id, r.Message))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	st := newTestStore(t)
+	jan := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	feb := time.Date(2025, 2, 10, 12, 0, 0, 0, time.Local)
+	for _, s := range []*Session{
+		newTestSession("a", "qwen-plus", "how do I configure nginx as a reverse proxy", "use proxy_pass in the nginx location block, nginx reloads with nginx -s reload", jan),
+		newTestSession("b", "qwen-max", "what is a reverse proxy", "a server that forwards requests, nginx is one example", feb),
+		newTestSession("c", "qwen-plus", "如何配置反向代理", "可以使用 nginx 的 proxy_pass 指令", feb),
+		newTestSession("d", "qwen-plus", "docker compose up", "starts the services", feb),
+	} {
+		if err := st.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  SearchOptions
+		want  []string
+	}{
+		{"latin words", "nginx", SearchOptions{}, []string{"a#1", "a#2", "b#2", "c#2"}},
+		{"chinese bigrams", "反向代理", SearchOptions{}, []string{"c#1"}},
+		{"from user", "nginx", SearchOptions{From: client.RoleUser}, []string{"a#1"}},
+		{"model filter", "nginx", SearchOptions{Model: "QWEN-MAX"}, []string{"b#2"}},
+		{"since", "nginx", SearchOptions{Since: feb.Add(-time.Hour)}, []string{"b#2", "c#2"}},
+		{"until", "nginx", SearchOptions{Until: jan.Add(time.Hour)}, []string{"a#1", "a#2"}},
+		{"limit", "nginx", SearchOptions{Limit: 1}, []string{"a#2"}},
+		{"no match", "kubernetes", SearchOptions{}, nil},
+		{"empty query", "...", SearchOptions{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchIDs(t, st, tt.query, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	st := newTestStore(t)
+	now := time.Now()
+	for _, s := range []*Session{
+		newTestSession("once", "m", "nginx", "a long answer that mentions the server only in passing and talks about many other things", now),
+		newTestSession("often", "m", "nginx nginx nginx", "ok", now),
+		newTestSession("other", "m", "docker", "ok", now),
+	} {
+		if err := st.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := st.Search("nginx docker", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Session.ID)
+	}
+	// docker 只出现一次，idf 最高；nginx 词频越高得分越高
+	want := []string{"other", "often", "once"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ranking = %q, want %q", got, want)
+	}
+	for _, r := range results {
+		if !reflect.DeepEqual(r.Terms, []string{"nginx", "docker"}) {
+			t.Errorf("Terms = %q", r.Terms)
+		}
+	}
+}
+
+func TestIndexUpdatedOnSaveAndDelete(t *testing.T) {
+	st := newTestStore(t)
+	s := newTestSession("a", "qwen-plus", "nginx config", "use proxy_pass", time.Now())
+	if err := st.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	idx := st.loadIndex()
+	if _, ok := idx.Sessions["a"]; !ok || len(idx.Postings["nginx"]) != 1 {
+		t.Fatalf("Save did not index the session: %+v", idx.Sessions)
+	}
+
+	s.Append(Message{Role: client.RoleUser, Content: "what about docker"})
+	if err := st.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	if idx := st.loadIndex(); len(idx.Postings["docker"]) != 1 || len(idx.Postings["nginx"]) != 1 {
+		t.Errorf("Save did not re-index the session: postings %v", idx.Postings)
+	}
+
+	if _, err := st.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if idx := st.loadIndex(); len(idx.Sessions) != 0 || len(idx.Docs) != 0 || len(idx.Postings) != 0 {
+		t.Errorf("Delete left index entries: %+v", idx)
+	}
+}
+
+func TestSearchRepairsIndex(t *testing.T) {
+	st := newTestStore(t)
+	if err := st.Save(newTestSession("a", "qwen-plus", "nginx config", "ok", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟索引丢失（如多个进程同时写入）和手动删除的会话文件
+	if err := st.Save(newTestSession("b", "qwen-plus", "nginx logs", "ok", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(st.indexPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(st.path("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchIDs(t, st, "nginx", SearchOptions{}); !reflect.DeepEqual(got, []string{"b#1"}) {
+		t.Errorf("Search = %q, want [b#1]", got)
+	}
+}
+
+func TestSearchTranscripts(t *testing.T) {
+	st := newTestStore(t)
+	auto := filepath.Join(st.Transcripts, "chat_auto_20250101_120000.md")
+	legacy := "# 通义千问对话记录\n\n开始时间: 2025-01-01 12:00:00\n模型: qwen-turbo\n\n---\n\n" +
+		"## 👤 用户\nnginx 反向代理怎么配置\n\n## 🤖 AI助手\n使用 proxy_pass\n\n---\n\n"
+	if err := os.WriteFile(auto, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	export := filepath.Join(t.TempDir(), "full_conversation_20250102000000.md")
+	if err := os.WriteFile(export, []byte("## system\nnginx expert\n\n## user\nnginx gzip\n\n## assistant\ngzip on;\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.IndexTranscript(export); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := st.Search("nginx", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]SearchResult{}
+	for _, r := range results {
+		files[filepath.Base(r.File)] = r
+	}
+	if len(results) != 2 || files[filepath.Base(auto)].File == "" || files[filepath.Base(export)].File == "" {
+		t.Fatalf("Search = %+v, want hits in both transcripts", results)
+	}
+	hit := files[filepath.Base(auto)]
+	if msg := hit.Session.Messages[hit.Message]; msg.Role != client.RoleUser || msg.Content != "nginx 反向代理怎么配置" {
+		t.Errorf("transcript hit = %+v", msg)
+	}
+	if hit.Session.Model != "qwen-turbo" {
+		t.Errorf("transcript model = %q, want qwen-turbo", hit.Session.Model)
+	}
+	if got := searchIDs(t, st, "nginx", SearchOptions{Model: "qwen-turbo"}); !reflect.DeepEqual(got, []string{filepath.Base(auto) + "#0"}) {
+		t.Errorf("Search with model filter = %q", got)
+	}
+
+	// 保存为会话的自动记录不重复出现
+	s := newTestSession("a", "qwen-turbo", "nginx 反向代理怎么配置", "使用 proxy_pass", time.Now())
+	s.AddTranscript(auto)
+	if err := st.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a#1", filepath.Base(export) + "#0"}
+	if got := searchIDs(t, st, "nginx", SearchOptions{From: client.RoleUser}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Search after saving the session = %q, want %q", got, want)
+	}
+
+	// 删除的导出文件从索引中移除
+	if err := os.Remove(export); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchIDs(t, st, "gzip", SearchOptions{}); got != nil {
+		t.Errorf("Search after removing the export = %q, want none", got)
+	}
+}
+
+func TestParseTranscript(t *testing.T) {
+	mod := time.Date(2025, 3, 1, 8, 0, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		data  string
+		model string
+		at    time.Time
+		want  []Message
+	}{
+		{
+			name: "auto save with reasoning and end time",
+			data: "# 通义千问对话记录\n\n开始时间: 2025-01-01 12:00:00\n模型: qwen-plus\n\n---\n\n" +
+				"## 👤 用户\n你好\n\n## 🤖 AI助手\n<details>\n<summary>💭 思考过程</summary>\n\n想一想\n\n</details>\n\n你好！\n\n---\n\n" +
+				"\n---\n\n结束时间: 2025-01-01 12:05:00\n",
+			model: "qwen-plus",
+			at:    time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local),
+			want: []Message{
+				{ID: "m1", Role: client.RoleUser, Content: "你好"},
+				{ID: "m2", Role: client.RoleAssistant, Content: "你好！"},
+			},
+		},
+		{
+			name: "full conversation export",
+			data: "## system\n你是助手\n\n## user\nls 是什么\n\n## assistant\n列出文件\n\n## tool\nignored\n",
+			at:   mod,
+			want: []Message{
+				{ID: "m1", Role: client.RoleUser, Content: "ls 是什么"},
+				{ID: "m2", Role: client.RoleAssistant, Content: "列出文件\n\n## tool\nignored"},
+			},
+		},
+		{
+			name: "last response export",
+			data: "# 最后一次AI回复\n使用 `df -h`\n",
+			at:   mod,
+			want: []Message{{ID: "m1", Role: client.RoleAssistant, Content: "使用 `df -h`"}},
+		},
+		{
+			name: "crlf",
+			data: "## user\r\nhi\r\n\r\n## assistant\r\nhello\r\n",
+			at:   mod,
+			want: []Message{
+				{ID: "m1", Role: client.RoleUser, Content: "hi"},
+				{ID: "m2", Role: client.RoleAssistant, Content: "hello"},
+			},
+		},
+		{name: "header only", data: "# 通义千问对话记录\n\n开始时间: bad\n\n---\n\n", at: mod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ParseTranscript("/tmp/x.md", []byte(tt.data), mod)
+			for i := range tt.want {
+				tt.want[i].CreatedAt = tt.at
+			}
+			if !reflect.DeepEqual(s.Messages, tt.want) {
+				t.Errorf("Messages = %+v\nwant %+v", s.Messages, tt.want)
+			}
+			if s.Model != tt.model || !s.CreatedAt.Equal(tt.at) || s.ID != "x.md" {
+				t.Errorf("session = %q %q %v", s.ID, s.Model, s.CreatedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+// Package session 将聊天会话以 JSON 形式保存在配置目录下，支持列出、恢复和删除。
+//
+// 会话中的消息组成一棵树：每条消息记录父消息的 ID，分支指向各自最新的一条消息，
+// 从根到当前分支最新消息的路径就是发送给模型的对话。
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"Qwen-cli/client"
+)
+
+// MainBranch 是新会话默认分支的 ID
+const MainBranch = "main"
+
+// Message 是会话中的一条消息
+type Message struct {
+	ID          string    `json:"id"`
+	Parent      string    `json:"parent,omitempty"` // 父消息 ID，根消息为空
+	Role        string    `json:"role"`
+	Content     string    `json:"content"`
+	Images      []string  `json:"images,omitempty"`    // 随消息发送的图片（data URI 或 URL）
+	Model       string    `json:"model,omitempty"`     // 生成该回复的模型
+	Reasoning   string    `json:"reasoning,omitempty"` // 思考过程，只保存不发送，配置 save_thinking 时记录
+	Interrupted bool      `json:"interrupted,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Branch 是消息树中的一个分支
+type Branch struct {
+	ID        string    `json:"id"`
+	Head      string    `json:"head"`           // 分支最新一条消息的 ID
+	Fork      string    `json:"fork,omitempty"` // 分叉处消息的 ID，主分支为空
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session 是一次完整的聊天会话
+type Session struct {
+	ID        string       `json:"id"`
+	Title     string       `json:"title"`
+	Model     string       `json:"model"`
+	Role      string       `json:"role"` // 角色提示词名称，自定义系统提示词时为空
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Messages  []Message    `json:"messages"` // 所有分支的消息，按创建顺序排列
+	Branches  []Branch     `json:"branches"`
+	Current   string       `json:"current"` // 当前分支 ID
+	Seq       int          `json:"seq"`     // 最近分配的消息序号
+	Usage     client.Usage `json:"usage"`   // 累计 token 用量
+
+	// Compactions 记录早期对话的摘要。发送给模型时用摘要代替被压缩的消息，
+	// Messages 中仍保留完整的原始记录
+	Compactions []Compaction `json:"compactions,omitempty"`
+
+	// Transcripts 是记录过该会话的 chat_auto_*.md 文件，内容与会话重复，搜索时不单独索引
+	Transcripts []string `json:"transcripts,omitempty"`
+
+	// LegacyCompaction 是消息树之前的版本记录的摘要，加载时由 migrate 转换为 Compactions
+	LegacyCompaction *legacyCompaction `json:"compaction,omitempty"`
+}
+
+// legacyCompaction 是线性会话的摘要，覆盖 Messages[:Through] 中的对话
+type legacyCompaction struct {
+	Summary   string    `json:"summary"`
+	Through   int       `json:"through"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Compaction 是由模型生成的早期对话摘要
+type Compaction struct {
+	Summary   string    `json:"summary"`
+	Through   string    `json:"through"` // 摘要覆盖到的最后一条消息 ID（含）
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SummaryPrefix 是摘要消息的开头，用于向模型说明这是早期对话的摘要
+const SummaryPrefix = "[早期对话摘要]\n"
+
+// New 创建新会话，system 为系统提示词
+func New(model, role, system string) *Session {
+	now := time.Now()
+	s := &Session{
+		ID:        newID(now),
+		Model:     model,
+		Role:      role,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Branches:  []Branch{{ID: MainBranch, CreatedAt: now}},
+		Current:   MainBranch,
+	}
+	if system != "" {
+		s.Append(Message{Role: client.RoleSystem, Content: system, CreatedAt: now})
+	}
+	return s
+}
+
+// newID 生成按时间排序的会话 ID，如 20250102-150405-a1b2
+func newID(now time.Time) string {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// migrate 将旧版本的线性会话转换为消息树
+func (s *Session) migrate() {
+	if len(s.Branches) > 0 {
+		return
+	}
+	parent := ""
+	for i := range s.Messages {
+		s.Seq++
+		s.Messages[i].ID = fmt.Sprintf("m%d", s.Seq)
+		s.Messages[i].Parent = parent
+		parent = s.Messages[i].ID
+	}
+	s.Branches = []Branch{{ID: MainBranch, Head: parent, CreatedAt: s.CreatedAt}}
+	s.Current = MainBranch
+
+	if c := s.LegacyCompaction; c != nil && c.Through > 0 {
+		through := min(c.Through, len(s.Messages))
+		s.Compactions = append(s.Compactions, Compaction{
+			Summary:   c.Summary,
+			Through:   s.Messages[through-1].ID,
+			Model:     c.Model,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+	s.LegacyCompaction = nil
+}
+
+// branch 返回当前分支
+func (s *Session) branch() *Branch {
+	for i := range s.Branches {
+		if s.Branches[i].ID == s.Current {
+			return &s.Branches[i]
+		}
+	}
+	s.Branches = append(s.Branches, Branch{ID: MainBranch, CreatedAt: time.Now()})
+	s.Current = MainBranch
+	return &s.Branches[len(s.Branches)-1]
+}
+
+// byID 返回消息 ID 到 Messages 下标的映射
+func (s *Session) byID() map[string]int {
+	ids := make(map[string]int, len(s.Messages))
+	for i, msg := range s.Messages {
+		ids[msg.ID] = i
+	}
+	return ids
+}
+
+// pathTo 返回从根到 head 的消息
+func (s *Session) pathTo(head string) []Message {
+	ids := s.byID()
+	var path []Message
+	for id := head; id != ""; {
+		i, ok := ids[id]
+		if !ok {
+			break
+		}
+		path = append(path, s.Messages[i])
+		id = s.Messages[i].Parent
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Path 返回当前分支从根到最新一条消息的路径
+func (s *Session) Path() []Message {
+	return s.pathTo(s.branch().Head)
+}
+
+// BranchPath 返回指定分支的路径
+func (s *Session) BranchPath(b Branch) []Message {
+	return s.pathTo(b.Head)
+}
+
+// Children 返回每条消息的子消息 ID，按创建顺序排列
+func (s *Session) Children() map[string][]string {
+	children := map[string][]string{}
+	for _, msg := range s.Messages {
+		children[msg.Parent] = append(children[msg.Parent], msg.ID)
+	}
+	return children
+}
+
+// Append 在当前分支末尾追加一条消息，首条用户消息会作为默认标题
+func (s *Session) Append(msg Message) {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	b := s.branch()
+	s.Seq++
+	msg.ID = fmt.Sprintf("m%d", s.Seq)
+	msg.Parent = b.Head
+	s.Messages = append(s.Messages, msg)
+	b.Head = msg.ID
+	s.UpdatedAt = msg.CreatedAt
+	if s.Title == "" && msg.Role == client.RoleUser {
+		s.Title = titleFrom(msg.Content)
+	}
+}
+
+// SetSystem 替换或插入系统提示词，所有分支共用同一个系统提示词
+func (s *Session) SetSystem(role, system string) {
+	s.Role = role
+	for i, msg := range s.Messages {
+		if msg.Parent == "" && msg.Role == client.RoleSystem {
+			s.Messages[i].Content = system
+			return
+		}
+	}
+
+	s.Seq++
+	root := Message{
+		ID:        fmt.Sprintf("m%d", s.Seq),
+		Role:      client.RoleSystem,
+		Content:   system,
+		CreatedAt: time.Now(),
+	}
+	for i := range s.Messages {
+		if s.Messages[i].Parent == "" {
+			s.Messages[i].Parent = root.ID
+		}
+	}
+	for i := range s.Branches {
+		if s.Branches[i].Head == "" {
+			s.Branches[i].Head = root.ID
+		}
+	}
+	s.Messages = append([]Message{root}, s.Messages...)
+}
+
+// AddTranscript 记录会话的自动记录文件
+func (s *Session) AddTranscript(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if !slices.Contains(s.Transcripts, path) {
+		s.Transcripts = append(s.Transcripts, path)
+	}
+}
+
+// AddUsage 累加 token 用量
+func (s *Session) AddUsage(u *client.Usage) {
+	if u == nil {
+		return
+	}
+	s.Usage.PromptTokens += u.PromptTokens
+	s.Usage.CompletionTokens += u.CompletionTokens
+	s.Usage.TotalTokens += u.TotalTokens
+}
+
+// Compacted 返回当前分支上最近的一次摘要，以及路径中被它覆盖的消息数（含系统提示词）
+func (s *Session) Compacted() (*Compaction, int) {
+	path := s.Path()
+	position := map[string]int{}
+	for i, msg := range path {
+		position[msg.ID] = i
+	}
+	var latest *Compaction
+	covered := 0
+	for i := range s.Compactions {
+		c := &s.Compactions[i]
+		if p, ok := position[c.Through]; ok && p+1 >= covered {
+			latest, covered = c, p+1
+		}
+	}
+	return latest, covered
+}
+
+// Conversation 返回当前分支可直接发送给模型的消息列表，已压缩的早期对话以一条摘要消息代替
+func (s *Session) Conversation() []client.Message {
+	path := s.Path()
+	messages := make([]client.Message, 0, len(path)+1)
+	start := 0
+	if len(path) > 0 && path[0].Role == client.RoleSystem {
+		messages = append(messages, client.Message{Role: client.RoleSystem, Content: path[0].Content})
+		start = 1
+	}
+	if c, covered := s.Compacted(); c != nil && covered > start {
+		messages = append(messages, client.Message{Role: client.RoleAssistant, Content: SummaryPrefix + c.Summary})
+		start = covered
+	}
+	for _, msg := range path[start:] {
+		messages = append(messages, client.Message{Role: msg.Role, Content: msg.Content, Images: msg.Images})
+	}
+	return messages
+}
+
+// LastUser 返回当前分支最后一条用户消息在 Path() 中的下标，没有时返回 -1
+func (s *Session) LastUser() int {
+	path := s.Path()
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Role == client.RoleUser {
+			return i
+		}
+	}
+	return -1
+}
+
+// Truncate 只保留当前分支路径中的前 n 条消息，不再被任何分支引用的消息会被删除
+func (s *Session) Truncate(n int) {
+	path := s.Path()
+	if n < 0 || n >= len(path) {
+		return
+	}
+	head := ""
+	if n > 0 {
+		head = path[n-1].ID
+	}
+	s.branch().Head = head
+	s.UpdatedAt = time.Now()
+	s.prune()
+}
+
+// prune 删除所有分支都无法到达的消息，以及覆盖了已删除消息的摘要
+func (s *Session) prune() {
+	reachable := map[string]bool{}
+	for _, b := range s.Branches {
+		for _, msg := range s.pathTo(b.Head) {
+			reachable[msg.ID] = true
+		}
+	}
+	// 根消息（系统提示词）即使没有分支引用也保留
+	kept := s.Messages[:0]
+	for _, msg := range s.Messages {
+		if reachable[msg.ID] || msg.Parent == "" {
+			kept = append(kept, msg)
+		}
+	}
+	s.Messages = kept
+
+	compactions := s.Compactions[:0]
+	for _, c := range s.Compactions {
+		if reachable[c.Through] {
+			compactions = append(compactions, c)
+		}
+	}
+	s.Compactions = compactions
+}
+
+// Compact 记录摘要，覆盖当前分支中直到消息 through 为止的对话
+func (s *Session) Compact(summary, through, model string) {
+	s.Compactions = append(s.Compactions, Compaction{
+		Summary:   summary,
+		Through:   through,
+		Model:     model,
+		CreatedAt: time.Now(),
+	})
+}
+
+// Fork 从当前分支的第 n 条消息（不含系统提示词，从 1 开始）创建新分支并切换过去，
+// 新分支包含第 1 到第 n 条消息
+func (s *Session) Fork(n int) (*Branch, error) {
+	path := s.Path()
+	if len(path) > 0 && path[0].Role == client.RoleSystem {
+		path = path[1:]
+	}
+	if n < 1 || n > len(path) {
+		return nil, fmt.Errorf("消息编号超出范围 (1~%d)", len(path))
+	}
+
+	b := Branch{
+		ID:        s.nextBranchID(),
+		Head:      path[n-1].ID,
+		Fork:      path[n-1].ID,
+		CreatedAt: time.Now(),
+	}
+	s.Branches = append(s.Branches, b)
+	s.Current = b.ID
+	s.UpdatedAt = b.CreatedAt
+	return &s.Branches[len(s.Branches)-1], nil
+}
+
+func (s *Session) nextBranchID() string {
+	exists := map[string]bool{}
+	for _, b := range s.Branches {
+		exists[b.ID] = true
+	}
+	for i := 1; ; i++ {
+		if id := fmt.Sprintf("b%d", i); !exists[id] {
+			return id
+		}
+	}
+}
+
+// Checkout 切换到指定分支
+func (s *Session) Checkout(id string) error {
+	for _, b := range s.Branches {
+		if b.ID == id {
+			s.Current = id
+			s.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return fmt.Errorf("分支 %s 不存在", id)
+}
+
+// titleFrom 取文本首行的前 40 个字符作为标题
+func titleFrom(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	runes := []rune(text)
+	if len(runes) > 40 {
+		return string(runes[:40]) + "…"
+	}
+	return text
+}
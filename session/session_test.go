@@ -0,0 +1,269 @@
+package session
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"Qwen-cli/client"
+)
+
+// contents 返回消息的 角色:内容 列表，便于比较
+func contents(messages []Message) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, msg.Role+":"+msg.Content)
+	}
+	return out
+}
+
+func conversationContents(messages []client.Message) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, msg.Role+":"+msg.Content)
+	}
+	return out
+}
+
+func TestMigrate(t *testing.T) {
+	const legacyMessages = `"messages": [
+		{"role": "system", "content": "sys"},
+		{"role": "user", "content": "q1"},
+		{"role": "assistant", "content": "a1"},
+		{"role": "user", "content": "q2"},
+		{"role": "assistant", "content": "a2"}
+	]`
+	tests := []struct {
+		name         string
+		data         string
+		path         []string
+		conversation []string
+	}{
+		{
+			name:         "linear",
+			data:         `{"id": "x", ` + legacyMessages + `}`,
+			path:         []string{"system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2"},
+			conversation: []string{"system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2"},
+		},
+		{
+			name:         "legacy compaction",
+			data:         `{"id": "x", ` + legacyMessages + `, "compaction": {"summary": "S", "through": 3, "model": "m"}}`,
+			path:         []string{"system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2"},
+			conversation: []string{"system:sys", "assistant:" + SummaryPrefix + "S", "user:q2", "assistant:a2"},
+		},
+		{
+			name:         "legacy compaction beyond the end",
+			data:         `{"id": "x", ` + legacyMessages + `, "compaction": {"summary": "S", "through": 99}}`,
+			path:         []string{"system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2"},
+			conversation: []string{"system:sys", "assistant:" + SummaryPrefix + "S"},
+		},
+		{
+			name:         "legacy compaction covering nothing",
+			data:         `{"id": "x", ` + legacyMessages + `, "compaction": {"summary": "S", "through": 0}}`,
+			path:         []string{"system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2"},
+			conversation: []string{"system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2"},
+		},
+		{
+			name: "already a tree",
+			data: `{"id": "x", "current": "b1", "seq": 3,
+				"branches": [{"id": "main", "head": "m2"}, {"id": "b1", "head": "m3", "fork": "m1"}],
+				"messages": [
+					{"id": "m1", "role": "user", "content": "q"},
+					{"id": "m2", "parent": "m1", "role": "assistant", "content": "a"},
+					{"id": "m3", "parent": "m1", "role": "assistant", "content": "b"}
+				]}`,
+			path:         []string{"user:q", "assistant:b"},
+			conversation: []string{"user:q", "assistant:b"},
+		},
+		{name: "empty", data: `{"id": "x"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Session
+			if err := json.Unmarshal([]byte(tt.data), &s); err != nil {
+				t.Fatal(err)
+			}
+			s.migrate()
+			if got := contents(s.Path()); !reflect.DeepEqual(got, tt.path) {
+				t.Errorf("Path = %q, want %q", got, tt.path)
+			}
+			if got := conversationContents(s.Conversation()); !reflect.DeepEqual(got, tt.conversation) {
+				t.Errorf("Conversation = %q, want %q", got, tt.conversation)
+			}
+			if s.LegacyCompaction != nil {
+				t.Error("LegacyCompaction was not cleared")
+			}
+
+			// 迁移后保存再读取，结果不变
+			data, err := json.Marshal(&s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), `"compaction"`) {
+				t.Errorf("legacy compaction field written back: %s", data)
+			}
+			var again Session
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatal(err)
+			}
+			again.migrate()
+			if got := conversationContents(again.Conversation()); !reflect.DeepEqual(got, tt.conversation) {
+				t.Errorf("Conversation after reload = %q, want %q", got, tt.conversation)
+			}
+		})
+	}
+}
+
+func TestMigrateAssignsIDs(t *testing.T) {
+	var s Session
+	if err := json.Unmarshal([]byte(`{"messages": [{"role": "user", "content": "q"}, {"role": "assistant", "content": "a"}]}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	s.migrate()
+	if s.Messages[0].ID != "m1" || s.Messages[0].Parent != "" || s.Messages[1].ID != "m2" || s.Messages[1].Parent != "m1" {
+		t.Errorf("messages = %+v", s.Messages)
+	}
+	if s.Seq != 2 || s.Current != MainBranch || len(s.Branches) != 1 || s.Branches[0].Head != "m2" {
+		t.Errorf("seq = %d, current = %q, branches = %+v", s.Seq, s.Current, s.Branches)
+	}
+	s.Append(Message{Role: client.RoleUser, Content: "next"})
+	if last := s.Messages[len(s.Messages)-1]; last.ID != "m3" || last.Parent != "m2" {
+		t.Errorf("appended message = %+v", last)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	s := New("m", "", "sys")
+	s.Append(Message{Role: client.RoleUser, Content: "q1"})
+	s.Append(Message{Role: client.RoleAssistant, Content: "a1"})
+	s.Append(Message{Role: client.RoleUser, Content: "q2"})
+	s.Append(Message{Role: client.RoleAssistant, Content: "a2"})
+	if s.Title != "q1" {
+		t.Errorf("Title = %q, want q1", s.Title)
+	}
+
+	if _, err := s.Fork(0); err == nil {
+		t.Error("Fork(0) should fail")
+	}
+	if _, err := s.Fork(5); err == nil {
+		t.Error("Fork(5) should fail")
+	}
+	b, err := s.Fork(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ID != "b1" || s.Current != "b1" {
+		t.Errorf("Fork created %q, current %q", b.ID, s.Current)
+	}
+	s.Append(Message{Role: client.RoleUser, Content: "q2'"})
+	s.Append(Message{Role: client.RoleAssistant, Content: "a2'"})
+
+	if got, want := contents(s.Path()), []string{"system:sys", "user:q1", "assistant:a1", "user:q2'", "assistant:a2'"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("b1 path = %q, want %q", got, want)
+	}
+	if err := s.Checkout(MainBranch); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := contents(s.Path()), []string{"system:sys", "user:q1", "assistant:a1", "user:q2", "assistant:a2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("main path = %q, want %q", got, want)
+	}
+	if err := s.Checkout("nope"); err == nil {
+		t.Error("Checkout of a missing branch should fail")
+	}
+	if children := s.Children()[s.Messages[2].ID]; len(children) != 2 {
+		t.Errorf("a1 has children %q, want two", children)
+	}
+	if got := s.LastUser(); got != 3 {
+		t.Errorf("LastUser = %d, want 3", got)
+	}
+
+	// main 截断后，只有 main 引用的 a2 被删除，b1 共用的消息保留
+	s.Truncate(4)
+	if got, want := contents(s.Path()), []string{"system:sys", "user:q1", "assistant:a1", "user:q2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("path after Truncate = %q, want %q", got, want)
+	}
+	if len(s.Messages) != 6 {
+		t.Errorf("%d messages after Truncate, want 6: %q", len(s.Messages), contents(s.Messages))
+	}
+	if got := contents(s.BranchPath(s.Branches[1])); len(got) != 5 {
+		t.Errorf("b1 path after truncating main = %q", got)
+	}
+
+	// 截断到空仍保留系统提示词
+	s.Truncate(0)
+	if got := contents(s.Path()); got != nil {
+		t.Errorf("path after Truncate(0) = %q", got)
+	}
+	if s.Messages[0].Role != client.RoleSystem {
+		t.Errorf("system prompt removed: %q", contents(s.Messages))
+	}
+}
+
+func TestCompactedFollowsBranch(t *testing.T) {
+	s := New("m", "", "sys")
+	for _, c := range []string{"q1", "a1", "q2", "a2", "q3", "a3"} {
+		role := client.RoleUser
+		if c[0] == 'a' {
+			role = client.RoleAssistant
+		}
+		s.Append(Message{Role: role, Content: c})
+	}
+	path := s.Path()
+	s.Compact("S1", path[2].ID, "m")
+	s.Compact("S2", path[4].ID, "m")
+
+	if c, covered := s.Compacted(); c == nil || c.Summary != "S2" || covered != 5 {
+		t.Errorf("Compacted = %+v, %d; want S2 covering 5", c, covered)
+	}
+	want := []string{"system:sys", "assistant:" + SummaryPrefix + "S2", "user:q3", "assistant:a3"}
+	if got := conversationContents(s.Conversation()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Conversation = %q, want %q", got, want)
+	}
+
+	// 从 a1 分叉的分支只被第一份摘要覆盖
+	if _, err := s.Fork(2); err != nil {
+		t.Fatal(err)
+	}
+	s.Append(Message{Role: client.RoleUser, Content: "other"})
+	want = []string{"system:sys", "assistant:" + SummaryPrefix + "S1", "user:other"}
+	if got := conversationContents(s.Conversation()); !reflect.DeepEqual(got, want) {
+		t.Errorf("forked Conversation = %q, want %q", got, want)
+	}
+
+	// 截断到摘要覆盖范围之前，失效的摘要被删除
+	s.Truncate(1)
+	if err := s.Checkout(MainBranch); err != nil {
+		t.Fatal(err)
+	}
+	s.Truncate(2)
+	if len(s.Compactions) != 0 {
+		t.Errorf("Compactions after truncating = %+v, want none", s.Compactions)
+	}
+}
+
+func TestSetSystem(t *testing.T) {
+	s := New("m", "", "")
+	s.Append(Message{Role: client.RoleUser, Content: "q"})
+	s.SetSystem("coder", "you are a coder")
+	if got, want := contents(s.Path()), []string{"system:you are a coder", "user:q"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("path = %q, want %q", got, want)
+	}
+	s.SetSystem("writer", "you are a writer")
+	if got := contents(s.Path()); got[0] != "system:you are a writer" || len(s.Messages) != 2 || s.Role != "writer" {
+		t.Errorf("path = %q, messages = %d, role = %q", got, len(s.Messages), s.Role)
+	}
+}
+
+func TestTitleFrom(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"  hello  ", "hello"},
+		{"first line\nsecond", "first line"},
+		{strings.Repeat("长", 45), strings.Repeat("长", 40) + "…"},
+	}
+	for _, tt := range tests {
+		if got := titleFrom(tt.text); got != tt.want {
+			t.Errorf("titleFrom(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
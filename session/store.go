@@ -0,0 +1,197 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"Qwen-cli/config"
+)
+
+// ErrNotFound 表示会话不存在
+var ErrNotFound = errors.New("session not found")
+
+// Store 管理保存在目录中的会话文件，每个会话一个 <id>.json
+type Store struct {
+	Dir string
+
+	// Transcripts 是 chat_auto_*.md 等 Markdown 对话记录所在的目录，搜索时一并索引，为空时不索引
+	Transcripts string
+}
+
+// DefaultStore 返回配置目录下 sessions 子目录的存储，对话记录保存在配置目录中
+func DefaultStore() *Store {
+	dir := config.GetConfigDir()
+	return &Store{Dir: filepath.Join(dir, "sessions"), Transcripts: dir}
+}
+
+func (st *Store) path(id string) string {
+	return filepath.Join(st.Dir, id+".json")
+}
+
+// Save 写入会话并更新搜索索引。先写临时文件再重命名，避免中途退出留下损坏的文件。
+// 索引更新失败不影响保存，下次搜索时会自动补齐
+func (st *Store) Save(s *Session) error {
+	if !validID(s.ID) {
+		return fmt.Errorf("%w: %q", ErrInvalidID, s.ID)
+	}
+	if err := os.MkdirAll(st.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(st.Dir, s.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create session file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), st.path(s.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save session file: %w", err)
+	}
+
+	if info, err := os.Stat(st.path(s.ID)); err == nil {
+		_ = st.updateIndex(s, info.ModTime())
+	}
+	return nil
+}
+
+// ErrInvalidID 表示会话 ID 为空或包含路径分隔符、..，不能用于拼接会话文件路径
+var ErrInvalidID = errors.New("invalid session id")
+
+// validID 判断 ID 是否非空且只指向会话目录中的文件。空 ID 会作为前缀匹配所有会话
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && !strings.Contains(id, "..") && !strings.ContainsRune(id, 0)
+}
+
+// Resolve 将完整 ID 或唯一前缀解析为会话 ID
+func (st *Store) Resolve(id string) (string, error) {
+	if !validID(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	if _, err := os.Stat(st.path(id)); err == nil {
+		return id, nil
+	}
+
+	ids, err := st.ids()
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, candidate := range ids {
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous session id %s: matches %s", id, strings.Join(matches, ", "))
+	}
+}
+
+// Load 读取会话，id 可以是唯一前缀
+func (st *Store) Load(id string) (*Session, error) {
+	resolved, err := st.Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(st.path(resolved))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", resolved, err)
+	}
+	s.migrate()
+	return &s, nil
+}
+
+// List 返回所有会话，按最后更新时间倒序排列。无法解析的文件会被跳过
+func (st *Store) List() ([]*Session, error) {
+	ids, err := st.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	for _, id := range ids {
+		s, err := st.Load(id)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Latest 返回最近更新的会话
+func (st *Store) Latest() (*Session, error) {
+	sessions, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+// Delete 删除会话，id 可以是唯一前缀
+func (st *Store) Delete(id string) (string, error) {
+	resolved, err := st.Resolve(id)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(st.path(resolved)); err != nil {
+		return "", fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	_ = st.removeFromIndex(resolved)
+	return resolved, nil
+}
+
+// ids 返回目录中所有会话 ID
+func (st *Store) ids() ([]string, error) {
+	entries, err := os.ReadDir(st.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || name == "index.json" {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	return ids, nil
+}
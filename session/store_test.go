@@ -0,0 +1,61 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	st := newTestStore(t)
+	for _, id := range []string{"20250101-120000-aaaa", "20250101-120000-abcd", "20250102-090000-ffff"} {
+		if err := st.Save(newTestSession(id, "m", "q", "a", time.Now())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr error // nil 且 want 为空表示歧义错误
+	}{
+		{"20250101-120000-aaaa", "20250101-120000-aaaa", nil},
+		{"20250102", "20250102-090000-ffff", nil},
+		{"20250101-120000-ab", "20250101-120000-abcd", nil},
+		{"20250101", "", nil},
+		{"2024", "", ErrNotFound},
+		{"", "", ErrInvalidID},
+		{"../sessions/20250102-090000-ffff", "", ErrInvalidID},
+		{"..", "", ErrInvalidID},
+		{"a/b", "", ErrInvalidID},
+		{`a\b`, "", ErrInvalidID},
+		{"a\x00b", "", ErrInvalidID},
+	}
+	for _, tt := range tests {
+		got, err := st.Resolve(tt.id)
+		switch {
+		case tt.want != "":
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve(%q) = %q, %v; want %q", tt.id, got, err, tt.want)
+			}
+		case tt.wantErr != nil:
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Resolve(%q) = %q, %v; want %v", tt.id, got, err, tt.wantErr)
+			}
+		default:
+			if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidID) {
+				t.Errorf("Resolve(%q) = %q, %v; want an ambiguity error", tt.id, got, err)
+			}
+		}
+	}
+}
+
+func TestSaveRejectsInvalidID(t *testing.T) {
+	st := newTestStore(t)
+	for _, id := range []string{"", "../x", "a/b"} {
+		s := newTestSession(id, "m", "q", "a", time.Now())
+		if err := st.Save(s); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Save with id %q error = %v, want ErrInvalidID", id, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"Qwen-cli/client"
+)
+
+// transcriptPatterns 是 Transcripts 目录中会被索引的 Markdown 对话记录：
+// chat 的自动记录和 /save、/save -all 导出的文件
+var transcriptPatterns = []string{"chat_auto_*.md", "full_conversation_*.md", "last_response_*.md"}
+
+// transcriptHeadings 将对话记录中的标题映射为消息角色，空字符串表示不索引该段
+var transcriptHeadings = map[string]string{
+	"## 👤 用户":      client.RoleUser,
+	"## 🤖 AI助手":    client.RoleAssistant,
+	"## user":      client.RoleUser,
+	"## assistant": client.RoleAssistant,
+	"## system":    "",
+	"# 最后一次AI回复":   client.RoleAssistant,
+}
+
+// reasoningBlock 匹配自动记录中折叠的思考过程
+var reasoningBlock = regexp.MustCompile(`(?s)<details>\s*<summary>💭 思考过程</summary>.*?</details>`)
+
+// ParseTranscript 解析 chat 自动记录或 /save 导出的 Markdown 文件，返回只包含用户和助手消息的会话。
+// 会话 ID 和标题为文件名；文件头部没有开始时间时以 modTime 作为消息时间
+func ParseTranscript(path string, data []byte, modTime time.Time) *Session {
+	name := filepath.Base(path)
+	s := &Session{ID: name, Title: name, CreatedAt: modTime, UpdatedAt: modTime}
+
+	var role string
+	var body []string
+	inMessage := false
+	flush := func() {
+		if inMessage && role != "" {
+			content := reasoningBlock.ReplaceAllString(strings.Join(body, "\n"), "")
+			content = strings.TrimSpace(content)
+			for strings.HasSuffix(content, "---") {
+				content = strings.TrimSpace(strings.TrimSuffix(content, "---"))
+			}
+			if content != "" {
+				s.Messages = append(s.Messages, Message{
+					ID:        fmt.Sprintf("m%d", len(s.Messages)+1),
+					Role:      role,
+					Content:   content,
+					CreatedAt: s.CreatedAt,
+				})
+			}
+		}
+		body = body[:0]
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if r, ok := transcriptHeadings[strings.TrimSpace(line)]; ok {
+			flush()
+			role, inMessage = r, true
+			continue
+		}
+		if !inMessage {
+			// 自动记录的头部：开始时间和模型
+			if value, ok := strings.CutPrefix(line, "开始时间: "); ok {
+				if t, err := time.ParseInLocation("2006-01-02 15:04:05", strings.TrimSpace(value), time.Local); err == nil {
+					s.CreatedAt = t
+				}
+			} else if value, ok := strings.CutPrefix(line, "模型: "); ok {
+				s.Model = strings.TrimSpace(value)
+			}
+			continue
+		}
+		// 自动记录的结尾：结束时间
+		if strings.HasPrefix(line, "结束时间: ") {
+			flush()
+			inMessage = false
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return s
+}
+
+// loadTranscript 读取并解析对话记录文件
+func loadTranscript(path string) (*Session, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return ParseTranscript(path, data, info.ModTime()), info.ModTime(), nil
+}
+
+// transcriptFiles 返回 Transcripts 目录中匹配 transcriptPatterns 的文件
+func (st *Store) transcriptFiles() []string {
+	if st.Transcripts == "" {
+		return nil
+	}
+	var files []string
+	for _, pattern := range transcriptPatterns {
+		matches, _ := filepath.Glob(filepath.Join(st.Transcripts, pattern))
+		files = append(files, matches...)
+	}
+	return files
+}
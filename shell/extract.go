@@ -0,0 +1,300 @@
+package shell
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"Qwen-cli/safety"
+)
+
+// Proposal 是模型按约定的 JSON 格式给出的命令：
+//
+//	{"command": "ls -la", "explanation": "列出当前目录的文件", "risk": "low"}
+//
+// Command 为空表示模型没有给出命令（如需求不明确），Explanation 是给用户的说明或提问
+type Proposal struct {
+	Command     string `json:"command"`
+	Explanation string `json:"explanation,omitempty"`
+	Risk        string `json:"risk,omitempty"` // low、medium 或 high，为空表示未评估
+}
+
+// JSON 返回 Proposal 的 JSON 表示，用于写回对话历史，使模型保持同样的回复格式
+func (p Proposal) JSON() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+// ErrMalformed 表示回复不符合约定的 JSON 格式
+var ErrMalformed = errors.New("回复不是有效的命令 JSON")
+
+// ParseProposal 解析模型的 JSON 回复并校验字段。JSON 外包围的代码块或说明文字会被忽略，
+// 命令中残留的代码块标记和 "$ " 提示符会被去掉。格式不符合时返回包装 ErrMalformed 的错误
+func ParseProposal(reply string) (Proposal, error) {
+	start := strings.IndexByte(reply, '{')
+	if start < 0 {
+		return Proposal{}, fmt.Errorf("%w：没有找到 JSON 对象", ErrMalformed)
+	}
+	var raw struct {
+		Command     *string `json:"command"`
+		Explanation string  `json:"explanation"`
+		Risk        string  `json:"risk"`
+	}
+	// Decoder 读完第一个 JSON 值即停止，忽略其后的内容
+	if err := json.NewDecoder(strings.NewReader(reply[start:])).Decode(&raw); err != nil {
+		return Proposal{}, fmt.Errorf("%w：%v", ErrMalformed, err)
+	}
+	if raw.Command == nil {
+		return Proposal{}, fmt.Errorf("%w：缺少 command 字段", ErrMalformed)
+	}
+
+	p := Proposal{
+		Command:     strings.TrimSpace(*raw.Command),
+		Explanation: strings.TrimSpace(raw.Explanation),
+		Risk:        strings.ToLower(strings.TrimSpace(raw.Risk)),
+	}
+	if p.Risk != "" {
+		if _, err := safety.ParseLevel(p.Risk); err != nil {
+			return Proposal{}, fmt.Errorf("%w：%v", ErrMalformed, err)
+		}
+	}
+	// 模型有时仍会在字段中使用代码块或提示符
+	if _, prompted := stripPrompt(p.Command); prompted || fencePattern.MatchString(p.Command) {
+		if candidates := Extract(p.Command); len(candidates) == 1 {
+			p.Command = candidates[0]
+		}
+	}
+	return p, nil
+}
+
+// fencePattern 匹配代码块的起止行，捕获语言标记
+var fencePattern = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+-]*)")
+
+// inlineCodePattern 匹配行内代码
+var inlineCodePattern = regexp.MustCompile("`([^`\n]+)`")
+
+// shellLanguages 是可以作为命令执行的代码块语言，空字符串表示未标注语言
+var shellLanguages = map[string]bool{
+	"": true, "bash": true, "sh": true, "shell": true, "zsh": true, "fish": true,
+	"console": true, "shell-session": true, "terminal": true,
+	"powershell": true, "ps1": true, "pwsh": true, "cmd": true, "bat": true, "batch": true,
+}
+
+// Extract 从不符合 JSON 格式的回复中提取候选命令，按出现顺序返回且不重复：
+//   - 有 shell 代码块时，每个代码块是一个候选
+//   - 否则以 "$ " 提示符开头的每一行是一个候选
+//   - 否则单独成行、能完整解析且每条命令（包括管道、&& 和 ; 连接的命令）的命令名都是已知程序的每一行是一个候选
+//   - 否则使用看起来像命令的行内代码
+//
+// 说明文字不会被当作命令，也不会与命令合并；没有可信的命令时返回空，由调用方询问用户。
+// 候选中的 "$ "、"PS C:\> " 等提示符和以提示符区分的命令输出都会被去掉
+func Extract(reply string) []string {
+	lines := strings.Split(strings.ReplaceAll(reply, "\r\n", "\n"), "\n")
+
+	blocks, text := splitFences(lines)
+	var candidates []string
+	for _, block := range blocks {
+		candidates = appendUnique(candidates, promptCommands(block, true)...)
+	}
+	if len(candidates) > 0 {
+		return candidates
+	}
+
+	if commands := promptCommands(text, false); len(commands) > 0 {
+		return appendUnique(nil, commands...)
+	}
+
+	for _, line := range text {
+		if isCommandLine(line) {
+			candidates = appendUnique(candidates, strings.TrimSpace(line))
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates
+	}
+
+	for _, line := range text {
+		for _, m := range inlineCodePattern.FindAllStringSubmatch(line, -1) {
+			if code := strings.TrimSpace(m[1]); looksLikeCommand(code) {
+				candidates = appendUnique(candidates, code)
+			}
+		}
+	}
+	return candidates
+}
+
+// splitFences 将各行分为 shell 代码块和代码块之外的文字。其他语言的代码块被丢弃，
+// 未闭合的代码块延续到回复结尾
+func splitFences(lines []string) (blocks [][]string, text []string) {
+	for i := 0; i < len(lines); i++ {
+		m := fencePattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			text = append(text, lines[i])
+			continue
+		}
+		fence, lang := m[1], strings.ToLower(m[2])
+		var block []string
+		for i++; i < len(lines); i++ {
+			if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				break
+			}
+			block = append(block, lines[i])
+		}
+		if shellLanguages[lang] {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, text
+}
+
+// promptCommands 提取以提示符开头的命令，以反斜杠结尾的行与下一行合并，其余行视为命令输出。
+// 没有提示符时，block 为 true 则整个代码块作为一条命令，否则返回空
+func promptCommands(lines []string, block bool) []string {
+	var commands []string
+	continued := false
+	for _, line := range lines {
+		if continued {
+			// 续行可能带有 "> " 提示符
+			commands[len(commands)-1] += "\n" + strings.TrimPrefix(strings.TrimLeft(line, " \t"), "> ")
+		} else if command, ok := stripPrompt(line); ok {
+			commands = append(commands, command)
+		} else {
+			continue
+		}
+		continued = strings.HasSuffix(strings.TrimRight(line, " \t"), "\\")
+	}
+	if len(commands) == 0 && block {
+		if command := strings.TrimSpace(strings.Join(lines, "\n")); command != "" {
+			return []string{command}
+		}
+	}
+	for i := range commands {
+		commands[i] = strings.TrimSpace(commands[i])
+	}
+	return commands
+}
+
+// psPromptPattern 匹配 PowerShell 提示符，如 "PS C:\Users\me> "
+var psPromptPattern = regexp.MustCompile(`^PS [^>]*> `)
+
+// stripPrompt 去掉行首的 "$ " 或 PowerShell 提示符，ok 表示该行带有提示符
+func stripPrompt(line string) (string, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if rest, ok := strings.CutPrefix(trimmed, "$ "); ok {
+		return rest, true
+	}
+	if loc := psPromptPattern.FindStringIndex(trimmed); loc != nil {
+		return trimmed[loc[1]:], true
+	}
+	return "", false
+}
+
+// isCommandLine 判断代码块之外、没有提示符的一行是否是命令：能完整解析，
+// 管道和 &&、; 连接的每条命令的命令名都是已知的程序，且不是说明文字
+func isCommandLine(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" || isProse(line) || strings.ContainsRune(line, '`') {
+		return false
+	}
+	commands, err := safety.Parse(line)
+	if err != nil || len(commands) == 0 {
+		return false
+	}
+	for _, c := range commands {
+		if len(c.Args) == 0 || !knownCommand(c.Args[0]) {
+			return false
+		}
+	}
+	// 以句号、问号等结尾的是句子，如 "make sure the file exists."；"." 和 ".." 等路径除外
+	args := commands[len(commands)-1].Args
+	last := args[len(args)-1]
+	if strings.Trim(last, "./") != "" && strings.ContainsAny(last[len(last)-1:], ".!?") {
+		return false
+	}
+	return true
+}
+
+// looksLikeCommand 判断文本能否解析为命令，且第一条命令的命令名是已知的程序
+func looksLikeCommand(text string) bool {
+	commands, err := safety.Parse(text)
+	if err != nil || len(commands) == 0 || len(commands[0].Args) == 0 {
+		return false
+	}
+	return knownCommand(commands[0].Args[0])
+}
+
+// cmdletPattern 匹配 PowerShell 的 Verb-Noun 命令，如 Get-ChildItem
+var cmdletPattern = regexp.MustCompile(`^[A-Z][a-z]+-[A-Z][A-Za-z]+$`)
+
+// commonCommands 是常见的命令和 shell 内建命令，本机没有安装时也视为命令
+var commonCommands = func() map[string]bool {
+	names := strings.Fields(`
+		. alias apt apt-get awk basename bash brew bun cargo cat cd chmod chown cmake code cp crontab curl cut
+		date dd df diff dig docker du echo env export fd file find free gcc gh git go gradle grep gunzip gzip
+		head helm hostname htop id ifconfig ip java javac journalctl jq kill kubectl less ln locate ls lsblk lsof
+		make man mkdir mv mvn nano netstat nginx node npm npx nslookup pacman pip pip3 pkill pnpm podman
+		powershell printf ps pwd pwsh python python3 rg rm rmdir rsync ruby rustc scp sed service sh source ss ssh
+		stat sudo systemctl tail tar tee top touch tr tree type uname unzip uptime vi vim wc wget whereis
+		which whoami xargs yarn yum zip zsh
+		cls copy del dir findstr ipconfig move netsh robocopy sc tasklist taskkill where winget
+	`)
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return m
+}()
+
+// lookPath 查找 PATH 中的程序，测试中替换以免结果依赖本机安装的程序
+var lookPath = exec.LookPath
+
+// knownCommand 判断命令名是否是常见命令、PowerShell 命令或 PATH 中的程序
+func knownCommand(name string) bool {
+	if commonCommands[name] || cmdletPattern.MatchString(name) {
+		return true
+	}
+	// 大写开头的单词多半是句子的开头，如 "Make sure ..."
+	first, _ := utf8.DecodeRuneInString(name)
+	if first >= utf8.RuneSelf || unicode.IsUpper(first) {
+		return false
+	}
+	_, err := lookPath(name)
+	return err == nil
+}
+
+// isProse 判断一行是否是说明文字：以中文等非 ASCII 文字或列表标记开头，或以冒号、句号结尾
+func isProse(line string) bool {
+	line = strings.TrimSpace(line)
+	first, _ := utf8.DecodeRuneInString(line)
+	if first >= utf8.RuneSelf && (unicode.IsLetter(first) || unicode.IsPunct(first) || unicode.IsSymbol(first)) {
+		return true
+	}
+	for _, marker := range []string{"- ", "* ", "Note:"} {
+		if strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+	for _, suffix := range []string{":", "：", "。"} {
+		if strings.HasSuffix(line, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique 追加 values 中非空且尚未出现的值
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if v == "" || slices.Contains(list, v) {
+			continue
+		}
+		list = append(list, v)
+	}
+	return list
+}
@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// stubLookPath 让 knownCommand 只认为 installed 中的程序在 PATH 中，测试结果不依赖本机环境
+func stubLookPath(t *testing.T, installed ...string) {
+	t.Helper()
+	original := lookPath
+	t.Cleanup(func() { lookPath = original })
+	lookPath = func(name string) (string, error) {
+		if slices.Contains(installed, name) {
+			return "/usr/local/bin/" + name, nil
+		}
+		return "", exec.ErrNotFound
+	}
+}
+
+func TestExtract(t *testing.T) {
+	stubLookPath(t, "mytool")
+	tests := []struct {
+		name  string
+		reply string
+		want  []string
+	}{
+		// 英文说明
+		{"english sentence before", "Sure, here is the command.\nls -la", []string{"ls -la"}},
+		{"english phrase before", "Here is the command you need\nls -la", []string{"ls -la"}},
+		{"english around", "You can run the following\n\nls -la\n\nThis lists all files.", []string{"ls -la"}},
+		{"english inline code", "Run `ls -la` to list files.", []string{"ls -la"}},
+		{"english inline flag only", "Use the `-r` flag to recurse.", nil},
+		{"english sentence with command word", "make sure the file exists.", nil},
+		{"english only", "I am not sure what you mean, could you clarify?", nil},
+		{"english two commands", "First update the index\napt update\nThen upgrade\napt upgrade -y", []string{"apt update", "apt upgrade -y"}},
+
+		// 中文说明
+		{"chinese before", "可以使用下面的命令：\ndu -sh *", []string{"du -sh *"}},
+		{"chinese around", "查看磁盘占用\ndf -h\n这会列出所有挂载点。", []string{"df -h"}},
+		{"chinese inline code", "运行 `git status` 查看修改。", []string{"git status"}},
+		{"chinese only", "请说明您要删除哪个目录。", nil},
+
+		// 代码块
+		{"fenced bash", "Use this:\n```bash\nls -la\n```\nDone.", []string{"ls -la"}},
+		{"fenced multi-line", "```sh\ncd /tmp &&\n  ls\n```", []string{"cd /tmp &&\n  ls"}},
+		{"fenced two blocks", "```\nmake\n```\nor\n```\nmake install\n```", []string{"make", "make install"}},
+		{"fenced other language ignored", "```python\nprint(1)\n```\n`python3 app.py`", []string{"python3 app.py"}},
+		{"fenced unclosed", "```bash\nuname -a", []string{"uname -a"}},
+		{"fenced with prompts and output", "```console\n$ echo hi\nhi\n$ pwd\n/home\n```", []string{"echo hi", "pwd"}},
+		{"fenced duplicate", "```\nls\n```\n```\nls\n```", []string{"ls"}},
+
+		// 提示符
+		{"dollar prompt", "Try:\n$ docker ps -a\nCONTAINER ID ...", []string{"docker ps -a"}},
+		{"dollar continuation", "$ docker run \\\n>   -it ubuntu", []string{"docker run \\\n  -it ubuntu"}},
+		{"powershell prompt", `PS C:\Users\me> Get-ChildItem -Force`, []string{"Get-ChildItem -Force"}},
+
+		// 不会把多行说明合并成一条命令
+		{"no join", "ls -la\nThis lists all files\npwd", []string{"ls -la", "pwd"}},
+		{"pipeline", "ps aux | grep nginx", []string{"ps aux | grep nginx"}},
+		{"and chain", "Run this:\ncd /tmp && ls -la\nDone.", []string{"cd /tmp && ls -la"}},
+		{"semicolon chain", "apt update; apt upgrade -y", []string{"apt update; apt upgrade -y"}},
+		{"chain with unknown command", "ls | frobnicate", nil},
+		{"chain ending in sentence", "ps aux | grep nginx.", nil},
+
+		// PATH 中的程序
+		{"installed program", "mytool --version", []string{"mytool --version"}},
+		{"missing program", "othertool --version", nil},
+		{"installed program capitalized", "Mytool runs the build", nil},
+		{"backticks never executed", "Run `rm -rf build` then `make`", []string{"rm -rf build", "make"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract(tt.reply)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract(%q) = %q, want %q", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProposal(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  Proposal
+	}{
+		{"plain", `{"command": "ls -la", "explanation": "列出文件", "risk": "low"}`,
+			Proposal{Command: "ls -la", Explanation: "列出文件", Risk: "low"}},
+		{"fenced json", "```json\n{\"command\": \"pwd\"}\n```", Proposal{Command: "pwd"}},
+		{"text around", "Here you go: {\"command\": \"df -h\", \"risk\": \"LOW\"} hope it helps",
+			Proposal{Command: "df -h", Risk: "low"}},
+		{"empty command", `{"command": "", "explanation": "请说明要删除哪个目录"}`,
+			Proposal{Explanation: "请说明要删除哪个目录"}},
+		{"prompt in command", `{"command": "$ git status"}`, Proposal{Command: "git status"}},
+		{"heredoc kept", `{"command": "cat <<EOF > a.txt\n$ literal\nEOF"}`,
+			Proposal{Command: "cat <<EOF > a.txt\n$ literal\nEOF"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProposal(tt.reply)
+			if err != nil {
+				t.Fatalf("ParseProposal(%q) error: %v", tt.reply, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseProposal(%q) = %+v, want %+v", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProposalMalformed(t *testing.T) {
+	for _, reply := range []string{
+		"ls -la",
+		`{"explanation": "no command"}`,
+		`{"command": "ls", "risk": "critical"}`,
+		`{"command": "ls"`,
+	} {
+		if _, err := ParseProposal(reply); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseProposal(%q) error = %v, want ErrMalformed", reply, err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+
+package shell
+
+import (
+	"os"
+	"os/exec"
+)
+
+// killGroupOnCancel 在其他系统上使用默认行为，ctx 结束时只终止命令本身
+func killGroupOnCancel(cmd *exec.Cmd) {}
+
+// newProcessGroup 在其他系统上不做任何处理
+func newProcessGroup(cmd *exec.Cmd, tty *os.File) (restore func()) {
+	return func() {}
+}
+
+// killGroupIfSignaled 在其他系统上不做任何处理
+func killGroupIfSignaled(cmd *exec.Cmd) {}
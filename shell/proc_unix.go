@@ -0,0 +1,59 @@
+//go:build linux || darwin
+
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// killGroupOnCancel 让 ctx 结束时先向命令的整个进程组发送 SIGTERM，
+// killGrace 后仍未退出的进程收到 SIGKILL。命令必须是进程组的组长（Setpgid 或 Setsid）
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+			return err
+		}
+		time.AfterFunc(killGrace, func() {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		return nil
+	}
+	// 子进程退出后仍占用输出管道的后台进程不再等待
+	cmd.WaitDelay = killGrace + time.Second
+}
+
+// newProcessGroup 让命令在新的进程组中运行。tty 是终端时该进程组成为终端的前台进程组，
+// 命令可以读取键盘输入，Ctrl-C 由终端直接发给命令；命令结束后调用返回的函数恢复前台进程组
+func newProcessGroup(cmd *exec.Cmd, tty *os.File) (restore func()) {
+	if tty == nil || !term.IsTerminal(int(tty.Fd())) {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		return func() {}
+	}
+	fd := int(tty.Fd())
+	cmd.SysProcAttr = &syscall.SysProcAttr{Foreground: true, Ctty: fd}
+	return func() {
+		// 后台进程组设置前台进程组会收到 SIGTTOU，期间忽略该信号
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, syscall.Getpgrp())
+	}
+}
+
+// killGroupIfSignaled 在命令被信号终止（如用户按下 Ctrl-C）后终止进程组中剩余的进程，
+// 避免命令在后台启动的进程继续运行
+func killGroupIfSignaled(cmd *exec.Cmd) {
+	if cmd.ProcessState == nil {
+		return
+	}
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package shell
+
+import "os/exec"
+
+// runPTY 在不支持伪终端的系统上总是返回 errNoPTY，由调用方退回管道方式
+func runPTY(cmd *exec.Cmd) (string, error) {
+	return "", errNoPTY
+}
@@ -0,0 +1,135 @@
+//go:build linux || darwin
+
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// drainTimeout 是命令退出后继续读取伪终端剩余输出的最长时间。
+// 命令留下的后台进程仍占用伪终端时，不再等待它们退出
+const drainTimeout = 200 * time.Millisecond
+
+// runPTY 在伪终端中运行 cmd，输出实时写到标准输出，键盘输入转发给命令，返回全部输出
+func runPTY(cmd *exec.Cmd) (string, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errNoPTY, err)
+	}
+	defer master.Close()
+	resize(master)
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	// 命令在新会话中运行，伪终端是它的控制终端
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	err = cmd.Start()
+	slave.Close()
+	if err != nil {
+		return "", err
+	}
+
+	// 当前终端切换到原始模式，按键（包括 Ctrl-C）原样交给伪终端处理
+	if state, err := term.MakeRaw(int(os.Stdin.Fd())); err == nil {
+		defer term.Restore(int(os.Stdin.Fd()), state)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go forwardInput(master, done)
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for {
+			select {
+			case <-winch:
+				resize(master)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var output bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		// 所有进程关闭伪终端后读取返回 EIO，视为正常结束
+		io.Copy(io.MultiWriter(os.Stdout, &output), master)
+		close(copied)
+	}()
+
+	err = cmd.Wait()
+	killGroupIfSignaled(cmd)
+	select {
+	case <-copied:
+	case <-time.After(drainTimeout):
+		master.SetReadDeadline(time.Now())
+		<-copied
+	}
+	return output.String(), err
+}
+
+// forwardInput 将键盘输入转发到伪终端，直到 done 关闭。
+// 使用 poll 等待输入，命令结束后不会吞掉用户下一次输入的内容
+func forwardInput(master *os.File, done <-chan struct{}) {
+	fd := int(os.Stdin.Fd())
+	buf := make([]byte, 1024)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, 100)
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		n, err = unix.Read(fd, buf)
+		if n <= 0 || err != nil {
+			return
+		}
+		if _, err := master.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// resize 将伪终端的窗口大小设为当前终端的大小
+func resize(master *os.File) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return
+	}
+	control(master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, ws)
+	})
+}
+
+// control 在文件描述符上执行 f。不使用 Fd()，避免把文件切换为阻塞模式，
+// 否则命令退出后无法用读取超时结束对伪终端的读取
+func control(f *os.File, fn func(fd int) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := conn.Control(func(fd uintptr) { ferr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return ferr
+}
@@ -0,0 +1,176 @@
+// Package shell 从模型的回复中提取系统命令并执行，在实时显示输出的同时保存输出内容。
+//
+// 当前进程的标准输入和标准输出都是终端时，命令在伪终端（PTY）中运行，带颜色的输出、
+// 进度条和需要用户输入的交互式程序都能正常工作，键盘输入原样转发给命令；
+// 否则通过管道运行，标准输出和标准错误分别显示和保存。
+//
+// 在 Linux 和 macOS 上命令运行在独立的进程组中，超时或被取消时整个进程组（包括命令启动的子进程）都会被终止。
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Result 是命令的执行结果
+type Result struct {
+	Output   string // 标准输出，在伪终端中运行时也包含标准错误，已去掉颜色等控制序列
+	Stderr   string
+	PTY      bool // 是否在伪终端中运行
+	Duration time.Duration
+}
+
+// Options 控制命令的输入和输出
+type Options struct {
+	// Stdout 和 Stderr 用于实时显示输出，为 nil 时只保存不显示
+	Stdout, Stderr io.Writer
+	// Terminal 为 true 时尝试在伪终端中运行，输出显示在当前终端，键盘输入转发给命令。
+	// 系统不支持伪终端时退回管道方式
+	Terminal bool
+	// Stdin 是通过管道运行时命令的标准输入，为 nil 时命令没有输入。
+	// Stdin 是终端时命令的进程组成为终端的前台进程组，Ctrl-C 由终端直接发给命令
+	Stdin *os.File
+	// Timeout 是命令的最长运行时间，为 0 时不限制
+	Timeout time.Duration
+}
+
+var (
+	// ErrTimeout 表示命令超过 Options.Timeout 后被终止
+	ErrTimeout = errors.New("命令执行超时")
+	// ErrInterrupted 表示命令因 ctx 被取消（如用户按下 Ctrl-C）而被终止
+	ErrInterrupted = errors.New("命令已中断")
+)
+
+// errNoPTY 表示无法创建伪终端
+var errNoPTY = errors.New("无法创建伪终端")
+
+// killGrace 是终止命令时发送 SIGTERM 后等待进程退出的时间，之后发送 SIGKILL
+const killGrace = 2 * time.Second
+
+// Command 返回在系统 shell 中执行 line 的命令：Windows 使用 cmd /C，其他系统使用 sh -c。
+// ctx 结束时终止命令所在的整个进程组
+func Command(ctx context.Context, line string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", line)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", line)
+	}
+	killGroupOnCancel(cmd)
+	return cmd
+}
+
+// Run 执行命令，等待命令结束后返回保存的输出。命令以非零状态退出时同时返回结果和错误，
+// 超时或 ctx 被取消时返回 ErrTimeout 或 ErrInterrupted
+func Run(ctx context.Context, line string, opts Options) (Result, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	var result Result
+	err := errNoPTY
+	if opts.Terminal {
+		var output string
+		output, err = runPTY(Command(ctx, line))
+		result = Result{Output: Clean(output), PTY: true}
+	}
+	if errors.Is(err, errNoPTY) {
+		result, err = runPipe(ctx, line, opts)
+	}
+	result.Duration = time.Since(started)
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("%w（%s），已终止", ErrTimeout, opts.Timeout)
+	case errors.Is(ctx.Err(), context.Canceled):
+		err = ErrInterrupted
+	}
+	return result, err
+}
+
+// runPipe 通过管道运行命令，输出同时写入 opts.Stdout、opts.Stderr 和缓冲区
+func runPipe(ctx context.Context, line string, opts Options) (Result, error) {
+	cmd := Command(ctx, line)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = tee(&stdout, opts.Stdout)
+	cmd.Stderr = tee(&stderr, opts.Stderr)
+	if opts.Stdin != nil {
+		cmd.Stdin = opts.Stdin
+	}
+	restore := newProcessGroup(cmd, opts.Stdin)
+	err := cmd.Run()
+	restore()
+	killGroupIfSignaled(cmd)
+	return Result{
+		Output: Clean(stdout.String()),
+		Stderr: Clean(stderr.String()),
+	}, err
+}
+
+// tee 在 display 不为空时同时写入 buf 和 display
+func tee(buf *bytes.Buffer, display io.Writer) io.Writer {
+	if display == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, display)
+}
+
+// escapePattern 匹配终端控制序列：CSI（颜色、光标移动）、OSC（窗口标题、超链接）和字符集切换
+var escapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[()][0-9A-Za-z]|\x1b[=>78]`)
+
+// Clean 去掉输出中的终端控制序列，并按回车符只保留每行最后一次覆盖的内容（如进度条），
+// 使保存的输出与屏幕上最终看到的一致
+func Clean(s string) string {
+	s = escapePattern.ReplaceAllString(s, "")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if j := strings.LastIndexByte(line, '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Truncate 将超过 limit 字节的输出缩短为开头和结尾两部分，中间替换为说明省略字节数的标记。
+// 切分位置尽量落在换行处，且不会切断 UTF-8 字符。limit 不大于 0 时不截断
+func Truncate(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	half := limit / 2
+
+	head := s[:half]
+	for len(head) > 0 && !utf8.RuneStart(s[len(head)]) {
+		head = head[:len(head)-1]
+	}
+	if i := strings.LastIndexByte(head, '\n'); i >= len(head)/2 {
+		head = head[:i+1]
+	}
+
+	start := len(s) - half
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	tail := s[start:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)/2 {
+		tail = tail[i+1:]
+	}
+
+	omitted := len(s) - len(head) - len(tail)
+	return fmt.Sprintf("%s\n... [已省略 %d 字节] ...\n%s", strings.TrimSuffix(head, "\n"), omitted, tail)
+}
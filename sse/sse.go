@@ -0,0 +1,143 @@
+// Package sse 实现 Server-Sent Events 流的解码。
+//
+// 解码遵循 WHATWG HTML 规范中的 event stream 解析规则：
+// 支持 CRLF、LF、CR 三种换行，行长度不受限制，忽略流开头的 UTF-8 BOM，多行 data 以换行拼接，
+// 以冒号开头的注释行（心跳）会被忽略，遇到空行时分发事件，流结束时未以空行结束的事件被丢弃。
+package sse
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultEventType 是未指定 event 字段时的事件类型
+const DefaultEventType = "message"
+
+// Event 表示一个完整的 SSE 事件
+type Event struct {
+	ID    string // 最近一次收到的 id 字段
+	Type  string // event 字段，默认为 "message"
+	Data  string // 所有 data 行以换行拼接后的内容
+	Retry int    // retry 字段（毫秒），未设置时为 0
+}
+
+// Decoder 从输入流中逐个读取 SSE 事件
+type Decoder struct {
+	r       *bufio.Reader
+	started bool // 已检查过流开头的 BOM
+	skipLF  bool // 上一行以 CR 结尾，若下一个字节是 LF 则跳过
+	lastID  string
+}
+
+// NewDecoder 创建读取 r 的解码器
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Next 返回下一个事件，流结束时返回 io.EOF。
+// 流在事件中途结束时（最后一个事件之后没有空行），按规范丢弃该事件并返回 io.EOF
+func (d *Decoder) Next() (Event, error) {
+	var (
+		data      strings.Builder
+		hasData   bool
+		eventType string
+		retry     int
+	)
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		// 空行：分发事件
+		if line == "" {
+			if !hasData {
+				eventType = ""
+				retry = 0
+				continue
+			}
+			return d.event(eventType, data.String(), retry), nil
+		}
+
+		// 注释行，通常是心跳
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+
+		switch field {
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			eventType = value
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastID = value
+			}
+		case "retry":
+			// 只接受由 ASCII 数字组成的值
+			if value != "" && strings.Trim(value, "0123456789") == "" {
+				if n, err := strconv.Atoi(value); err == nil {
+					retry = n
+				}
+			}
+		}
+	}
+}
+
+func (d *Decoder) event(eventType, data string, retry int) Event {
+	if eventType == "" {
+		eventType = DefaultEventType
+	}
+	return Event{
+		ID:    d.lastID,
+		Type:  eventType,
+		Data:  data,
+		Retry: retry,
+	}
+}
+
+// readLine 读取一行（不含换行符），不限制行长度。流在行中途结束时丢弃不完整的行并返回 io.EOF
+func (d *Decoder) readLine() (string, error) {
+	if !d.started {
+		d.started = true
+		if bom, err := d.r.Peek(3); err == nil && string(bom) == "\uFEFF" {
+			d.r.Discard(3)
+		}
+	}
+
+	var line []byte
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		if d.skipLF {
+			d.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+
+		switch b {
+		case '\n':
+			return string(line), nil
+		case '\r':
+			d.skipLF = true
+			return string(line), nil
+		}
+		line = append(line, b)
+	}
+}
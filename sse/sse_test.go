@@ -0,0 +1,97 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// decodeAll 读取所有事件，直到返回错误
+func decodeAll(r io.Reader) ([]Event, error) {
+	d := NewDecoder(r)
+	var events []Event
+	for {
+		event, err := d.Next()
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	long := strings.Repeat("x", 100000)
+	tests := []struct {
+		name  string
+		input string
+		want  []Event
+	}{
+		{"lf", "data: hello\n\n", []Event{{Type: "message", Data: "hello"}}},
+		{"crlf", "data: hello\r\n\r\ndata: world\r\n\r\n", []Event{{Type: "message", Data: "hello"}, {Type: "message", Data: "world"}}},
+		{"cr", "data: hello\r\rdata: world\r\r", []Event{{Type: "message", Data: "hello"}, {Type: "message", Data: "world"}}},
+		{"mixed line endings", "data: a\rdata: b\r\ndata: c\n\r\n", []Event{{Type: "message", Data: "a\nb\nc"}}},
+		{"multi-line data", "data: first\ndata: second\ndata:\ndata: third\n\n", []Event{{Type: "message", Data: "first\nsecond\n\nthird"}}},
+		{"only one leading space removed", "data:  two spaces\ndata:none\n\n", []Event{{Type: "message", Data: " two spaces\nnone"}}},
+		{"field without colon", "data\n\n", []Event{{Type: "message", Data: ""}}},
+		{"comments", ": heartbeat\n:\ndata: x\n: inside\n\n", []Event{{Type: "message", Data: "x"}}},
+		{"event type", "event: error\ndata: {}\n\ndata: next\n\n", []Event{{Type: "error", Data: "{}"}, {Type: "message", Data: "next"}}},
+		{"event type reset without data", "event: ping\n\ndata: x\n\n", []Event{{Type: "message", Data: "x"}}},
+		{"id persists", "id: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\n", []Event{
+			{ID: "1", Type: "message", Data: "a"},
+			{ID: "1", Type: "message", Data: "b"},
+			{ID: "", Type: "message", Data: "c"},
+		}},
+		{"id with null ignored", "id: 1\ndata: a\n\nid: 2\x003\ndata: b\n\n", []Event{
+			{ID: "1", Type: "message", Data: "a"},
+			{ID: "1", Type: "message", Data: "b"},
+		}},
+		{"retry", "retry: 3000\ndata: a\n\nretry: 1.5\ndata: b\n\nretry: -1\ndata: c\n\nretry: +5\ndata: d\n\n", []Event{
+			{Type: "message", Data: "a", Retry: 3000},
+			{Type: "message", Data: "b"},
+			{Type: "message", Data: "c"},
+			{Type: "message", Data: "d"},
+		}},
+		{"unknown fields ignored", "foo: bar\ndata: x\n\n", []Event{{Type: "message", Data: "x"}}},
+		{"bom", "\uFEFFdata: x\n\n", []Event{{Type: "message", Data: "x"}}},
+		{"bom only at start", "data: x\n\n\uFEFFdata: y\n\n", []Event{{Type: "message", Data: "x"}}},
+		{"long line", "data: " + long + "\n\n", []Event{{Type: "message", Data: long}}},
+		{"blank lines between events", "\n\n\ndata: x\n\n\n\n", []Event{{Type: "message", Data: "x"}}},
+		{"truncated final event", "data: a\n\ndata: b\n", []Event{{Type: "message", Data: "a"}}},
+		{"truncated final line", "data: a\n\ndata: b", []Event{{Type: "message", Data: "a"}}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		for _, reader := range []struct {
+			name string
+			wrap func(io.Reader) io.Reader
+		}{
+			{"", func(r io.Reader) io.Reader { return r }},
+			{"/one byte", iotest.OneByteReader},
+		} {
+			t.Run(tt.name+reader.name, func(t *testing.T) {
+				got, err := decodeAll(reader.wrap(strings.NewReader(tt.input)))
+				if err != io.EOF {
+					t.Errorf("error = %v, want io.EOF", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("events = %+v\nwant %+v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDecoderReadError(t *testing.T) {
+	boom := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("data: a\n\ndata: b\n"), iotest.ErrReader(boom))
+	got, err := decodeAll(r)
+	if !errors.Is(err, boom) {
+		t.Errorf("error = %v, want %v", err, boom)
+	}
+	if want := []Event{{Type: "message", Data: "a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+}
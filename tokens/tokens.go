@@ -0,0 +1,111 @@
+// Package tokens 估算消息的 token 数并按上下文窗口裁剪对话历史。
+//
+// 估算基于 Qwen 分词器的统计规律，而不是内嵌完整词表：常用汉字约 1 个 token，
+// 常见英文单词 1 个 token、长单词约每 6 个字母 1 个 token，数字逐位切分，标点单独成 token。
+// 结果略偏保守，足以判断是否接近上下文上限。
+package tokens
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"Qwen-cli/client"
+)
+
+const (
+	// messageOverhead 是 ChatML 格式中每条消息的固定开销：<|im_start|>role\n ... <|im_end|>\n
+	messageOverhead = 4
+	// replyOverhead 是提示模型开始回复的 <|im_start|>assistant\n
+	replyOverhead = 3
+	// ImageTokens 是一张图片的估算 token 数。Qwen-VL 按 28x28 像素一个 token 计算，
+	// 默认最多缩放到 1280 个 token，这里取常见截图的大致值
+	ImageTokens = 1024
+)
+
+// Count 估算文本的 token 数
+func Count(text string) int {
+	n, word, space := 0, 0, 0
+	flush := func() {
+		n += (word + 5) / 6
+		word = 0
+		// 单个空格并入后面的词，连续空白（缩进、空行）单独计为一个 token
+		if space > 1 {
+			n++
+		}
+		space = 0
+	}
+
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			if space > 0 {
+				flush()
+			}
+			word++
+		case unicode.IsSpace(r):
+			if word > 0 {
+				flush()
+			}
+			space++
+		case unicode.IsDigit(r):
+			flush()
+			n++
+		case r < utf8.RuneSelf || unicode.IsPunct(r):
+			flush()
+			n++
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			flush()
+			n++
+		default:
+			// 其他文字和符号按 UTF-8 字节数粗略估算
+			flush()
+			n += (utf8.RuneLen(r) + 1) / 2
+		}
+	}
+	flush()
+	return n
+}
+
+// CountMessage 估算单条消息的 token 数，包含格式开销
+func CountMessage(msg client.Message) int {
+	return messageOverhead + Count(msg.Role) + Count(msg.Content) + len(msg.Images)*ImageTokens
+}
+
+// CountMessages 估算作为请求发送的整个对话的 token 数
+func CountMessages(messages []client.Message) int {
+	n := replyOverhead
+	for _, msg := range messages {
+		n += CountMessage(msg)
+	}
+	return n
+}
+
+// Trim 从最早的对话开始删除消息，直到估算的 token 数不超过 budget。
+// 开头的系统提示词和最后一条消息总是保留，删除后的历史总以用户消息开头，
+// 避免留下没有提问的孤立回复。返回裁剪后的新切片和删除的消息数
+func Trim(messages []client.Message, budget int) ([]client.Message, int) {
+	total := CountMessages(messages)
+	if total <= budget {
+		return messages, 0
+	}
+
+	head := 0
+	if len(messages) > 0 && messages[0].Role == client.RoleSystem {
+		head = 1
+	}
+
+	start := head
+	for start < len(messages)-1 && total > budget {
+		total -= CountMessage(messages[start])
+		start++
+		for start < len(messages)-1 && messages[start].Role != client.RoleUser {
+			total -= CountMessage(messages[start])
+			start++
+		}
+	}
+
+	trimmed := make([]client.Message, 0, head+len(messages)-start)
+	trimmed = append(trimmed, messages[:head]...)
+	trimmed = append(trimmed, messages[start:]...)
+	return trimmed, start - head
+}